@@ -0,0 +1,107 @@
+// migrate 管理 database 配置指向的数据库的表结构：
+//
+//	go run ./cmd/migrate [-dry-run] status
+//	go run ./cmd/migrate [-dry-run] up [version]
+//	go run ./cmd/migrate [-dry-run] down [steps]
+//
+// up 执行到指定版本（默认最新），down 回滚最近的 steps 个迁移（默认 1 个），
+// -dry-run 只输出将要执行的 SQL
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/ai-companion/backend/global"
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "只输出将要执行的 SQL，不修改数据库")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate [-dry-run] status | up [version] | down [steps]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), flag.Arg(1), *dryRun); err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(command, arg string, dryRun bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.Connect(&global.Cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = db.Close() }()
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	migrator.DryRun = dryRun
+
+	var n int64
+	if arg != "" {
+		if n, err = strconv.ParseInt(arg, 10, 64); err != nil || n < 0 {
+			return fmt.Errorf("invalid argument %q", arg)
+		}
+	}
+	switch command {
+	case "status":
+		return status(ctx, migrator)
+	case "up":
+		applied, err := migrator.Up(ctx, n)
+		report(applied, "applied", dryRun)
+		return err
+	case "down":
+		if arg == "" {
+			n = 1
+		}
+		reverted, err := migrator.Down(ctx, int(n))
+		report(reverted, "reverted", dryRun)
+		return err
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func status(ctx context.Context, migrator *database.Migrator) error {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		state := "pending"
+		if !s.AppliedAt.IsZero() {
+			state = "applied at " + s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-40s %s\n", s.Migration, state)
+	}
+	return nil
+}
+
+func report(migrations []database.Migration, action string, dryRun bool) {
+	if dryRun {
+		fmt.Printf("-- dry run: %d migration(s) would be %s\n", len(migrations), action)
+		return
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %s\n", action, m)
+	}
+	if len(migrations) == 0 {
+		fmt.Println("nothing to do")
+	}
+}
@@ -19,7 +19,7 @@ func main() {
 	router := gin.Default()
 
 	// 设置路由
-	routes.SetupRouters(router)
+	cleanup := routes.SetupRouters(router)
 	// 打印启动信息
 	fmt.Printf("🚀 AI Companion Server starting on port %s\n", global.Cfg.Server.Port)
 	// 创建HTTP服务器
@@ -40,4 +40,5 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	_ = srv.Shutdown(context.Background())
+	cleanup()
 }
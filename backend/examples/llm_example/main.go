@@ -12,14 +12,17 @@ func main() {
 	cfg := config.Load()
 	fmt.Println("config: ", cfg.LLM.Model)
 
-	handle := llm.CreateLLM(cfg.LLM.Provider, map[string]string{"model": cfg.LLM.Model, "baseUrl": cfg.LLM.BaseUrl})
+	handle := llm.CreateLLM(&cfg.LLM)
 	ctx := context.Background()
-	req := &llm.ChatRequest{Message: "你好啊 ，今天星期几"}
+	req := &llm.ChatRequest{Messages: []llm.Message{
+		{Role: llm.RoleUser, Content: "你好啊 ，今天星期几"},
+	}}
 	res, err := handle.GenerateChat(ctx, req)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
-	fmt.Println(res.Object)
+	fmt.Println(res.Content())
+	fmt.Printf("usage: %+v\n", res.Usage)
 
 }
@@ -2,13 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
-	"github.com/ai-companion/backend/global"
 	"github.com/ai-companion/backend/internal/common"
 	"github.com/ai-companion/backend/internal/domain/chat_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
 	"github.com/ai-companion/backend/internal/service/chat"
 	"github.com/gin-gonic/gin"
 )
@@ -28,9 +29,10 @@ func (h *ChatHandler) Chat(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, common.NewRequestError())
 		return
 	}
-	reply, err := h.chatService.ProcessMessage(c, &req)
+	req.UserID = requestUserID(c, req.UserID)
+	reply, err := h.chatService.ProcessMessage(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, common.NewInternalError())
+		c.JSON(conversationErrorResponse(err))
 		return
 	}
 	c.JSON(http.StatusOK, common.NewSuccess(reply))
@@ -42,6 +44,14 @@ func (h *ChatHandler) ChatStream(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, common.NewRequestError())
 		return
 	}
+	req.UserID = requestUserID(c, req.UserID)
+	stream, err := h.chatService.ProcessStreamMessage(c.Request.Context(), &req)
+	if err != nil {
+		// 尚未开始推流，与非流式接口一样返回对应的状态码与统一响应
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+
 	// 设置SSE响应头
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
@@ -51,40 +61,79 @@ func (h *ChatHandler) ChatStream(c *gin.Context) {
 	notify := c.Request.Context().Done()
 
 	var chatRes chat_domain.Response
-	chatRes.MessageID = global.UUID.String()
 	chatRes.Timestamp = time.Now().Unix()
-
-	stream, err := h.chatService.ProcessStreamMessage(c, &req)
-	if err != nil {
-		//c.JSON(http.StatusInternalServerError, common.NewInternalError())
-		fmt.Printf("Failed to process stream message: %s", err.Error())
-		chatRes.Reply = "Failed to process stream message: " + err.Error()
-		c.SSEvent("error", chatRes)
-		return
-	}
-
+	chatRes.MessageID, chatRes.ConversationID = stream.MessageID, stream.ConversationID
 	sendSSEEvent(c, "star", chatRes)
 
 	for {
 		select {
-		case chunk, ok := <-stream:
+		case chunk, ok := <-stream.Chunks:
 			if !ok {
 				// 流关闭了
 				sendSSEEvent(c, "end", nil)
 				return
 			}
-			chatRes.Reply = chunk.Message
-			sendSSEEvent(c, "message", chatRes)
+			if chunk.Error != nil {
+				// 已经开始推流，状态码无法再修改，以 error 事件推送与非流式接口相同的错误码与信息
+				_, res := conversationErrorResponse(chunk.Error)
+				sendSSEEvent(c, "error", res)
+				return
+			}
+			if chunk.Reasoning != "" {
+				chatRes.Reply, chatRes.Reasoning = "", chunk.Reasoning
+				sendSSEEvent(c, "reasoning", chatRes)
+				chatRes.Reasoning = ""
+			}
+			if chunk.Message != "" {
+				chatRes.Reply = chunk.Message
+				sendSSEEvent(c, "message", chatRes)
+			}
+			if chunk.Done {
+				// 结束块携带停止原因与用量
+				chatRes.Reply = ""
+				chatRes.FinishReason = chunk.FinishReason
+				chatRes.Usage = chat.ToDomainUsage(chunk.Usage)
+				sendSSEEvent(c, "end", chatRes)
+				return
+			}
 		case <-notify:
 			return
 		}
 	}
 }
 
+// Models 列出可选的模型配置
+func (h *ChatHandler) Models(c *gin.Context) {
+	c.JSON(http.StatusOK, common.NewSuccess(h.chatService.ListModels()))
+}
+
+// errorResponse 将服务层错误转换为 HTTP 状态码与统一响应
+func errorResponse(err error) (int, *common.Response) {
+	var validationErr *llm.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusBadRequest, common.NewError(common.CodeBadRequest, validationErr.Error())
+	}
+	// 上游限流、服务不可用或熔断时，告知客户端稍后重试而不是笼统的 500
+	if errors.Is(err, llm.ErrCircuitOpen) {
+		return http.StatusServiceUnavailable, common.NewError(common.CodeServiceError, common.MsgServiceError)
+	}
+	var upstreamErr *llm.UpstreamError
+	if errors.As(err, &upstreamErr) {
+		if upstreamErr.StatusCode == http.StatusTooManyRequests {
+			return http.StatusTooManyRequests, common.NewError(common.CodeRateLimit, common.MsgRateLimit)
+		}
+		if upstreamErr.Retryable {
+			return http.StatusBadGateway, common.NewError(common.CodeServiceError, common.MsgServiceError)
+		}
+	}
+	return http.StatusInternalServerError, common.NewInternalError()
+}
+
 func sendSSEEvent(c *gin.Context, eventType string, data interface{}) {
 	var dataStr string
 	if data == nil {
 		c.SSEvent(eventType, nil)
+		return
 	}
 	marshal, err := json.Marshal(data)
 	if err != nil {
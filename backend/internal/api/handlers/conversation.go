@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ai-companion/backend/internal/common"
+	"github.com/ai-companion/backend/internal/domain/chat_domain"
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/service/chat"
+	"github.com/gin-gonic/gin"
+)
+
+type ConversationHandler struct {
+	chatService *chat.Service
+}
+
+func NewConversationHandler(chatService *chat.Service) *ConversationHandler {
+	return &ConversationHandler{chatService: chatService}
+}
+
+// Create 新建会话
+func (h *ConversationHandler) Create(c *gin.Context) {
+	var req conversation_domain.CreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	conv, err := h.chatService.CreateConversation(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(conv))
+}
+
+// List 分页列出用户的会话
+func (h *ConversationHandler) List(c *gin.Context) {
+	var req conversation_domain.ListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	convs, total, err := h.chatService.ListConversations(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewPageResponse(convs, req.Page, req.Size, total))
+}
+
+// Get 返回一个会话
+func (h *ConversationHandler) Get(c *gin.Context) {
+	var req conversation_domain.OwnerRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	conv, err := h.chatService.GetConversation(c.Request.Context(), requestUserID(c, req.UserID), c.Param("id"))
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(conv))
+}
+
+// Update 重命名或归档会话
+func (h *ConversationHandler) Update(c *gin.Context) {
+	var req conversation_domain.UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	conv, err := h.chatService.UpdateConversation(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(conv))
+}
+
+// Delete 删除会话及其消息
+func (h *ConversationHandler) Delete(c *gin.Context) {
+	var req conversation_domain.OwnerRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	if err := h.chatService.DeleteConversation(c.Request.Context(), requestUserID(c, req.UserID), c.Param("id")); err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(nil))
+}
+
+// Messages 按时间顺序分页列出会话中的消息
+func (h *ConversationHandler) Messages(c *gin.Context) {
+	var req conversation_domain.ListMessagesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	messages, total, err := h.chatService.ListMessages(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewPageResponse(messages, req.Page, req.Size, total))
+}
+
+// Branch 返回会话当前分支上的消息及各自的兄弟消息
+func (h *ConversationHandler) Branch(c *gin.Context) {
+	var req conversation_domain.OwnerRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	branch, err := h.chatService.Branch(c.Request.Context(), requestUserID(c, req.UserID), c.Param("id"))
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(branch))
+}
+
+// SwitchBranch 切换到包含指定消息的分支
+func (h *ConversationHandler) SwitchBranch(c *gin.Context) {
+	var req conversation_domain.SwitchBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	branch, err := h.chatService.SwitchBranch(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(branch))
+}
+
+// Regenerate 重新生成一条回复，原回复保留为兄弟分支
+func (h *ConversationHandler) Regenerate(c *gin.Context) {
+	var req chat_domain.RegenerateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	reply, err := h.chatService.Regenerate(c.Request.Context(), c.Param("id"), c.Param("messageId"), &req)
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(reply))
+}
+
+// Edit 修改一条用户消息并生成回复，原消息保留为兄弟分支
+func (h *ConversationHandler) Edit(c *gin.Context) {
+	var req chat_domain.EditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	reply, err := h.chatService.EditMessage(c.Request.Context(), c.Param("id"), c.Param("messageId"), &req)
+	if err != nil {
+		c.JSON(conversationErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(reply))
+}
+
+func conversationErrorResponse(err error) (int, *common.Response) {
+	if errors.Is(err, conversation_domain.ErrNotFound) {
+		return http.StatusNotFound, common.NewError(common.CodeNotFound, common.MsgNotFound)
+	}
+	if errors.Is(err, chat.ErrInvalidBranch) {
+		return http.StatusBadRequest, common.NewError(common.CodeBadRequest, err.Error())
+	}
+	if errors.Is(err, chat.ErrStorageUnavailable) {
+		return http.StatusServiceUnavailable, common.NewError(common.CodeServiceError, common.MsgServiceError)
+	}
+	return errorResponse(err)
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ai-companion/backend/global"
+	"github.com/ai-companion/backend/internal/common"
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/service/chat"
+	"github.com/gin-gonic/gin"
+)
+
+// newConversationRouter 以 driver 指定的数据库创建聊天服务，并按正式路由注册会话接口
+func newConversationRouter(t *testing.T, driver string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	saved := global.Cfg.Database
+	global.Cfg.Database = config.DatabaseConfig{Driver: driver, Path: filepath.Join(t.TempDir(), "test.db"), AutoMigrate: true}
+	service := chat.NewService()
+	global.Cfg.Database = saved
+	t.Cleanup(func() { _ = service.Close() })
+
+	h := NewConversationHandler(service)
+	r := gin.New()
+	conversations := r.Group("/api/conversations")
+	conversations.POST("", h.Create)
+	conversations.GET("", h.List)
+	conversations.GET("/:id", h.Get)
+	conversations.PATCH("/:id", h.Update)
+	conversations.DELETE("/:id", h.Delete)
+	conversations.GET("/:id/messages", h.Messages)
+	conversations.POST("/:id/messages/:messageId/regenerate", h.Regenerate)
+	conversations.POST("/:id/messages/:messageId/edit", h.Edit)
+	conversations.GET("/:id/branch", h.Branch)
+	conversations.PUT("/:id/branch", h.SwitchBranch)
+	return r
+}
+
+// serve 发送请求，cookies 为随请求携带的 cookie
+func serve(r *gin.Engine, method, url, body string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// createTestConversation 新建会话并返回其ID
+func createTestConversation(t *testing.T, r *gin.Engine, body string, cookies ...*http.Cookie) string {
+	t.Helper()
+	w := serve(r, http.MethodPost, "/api/conversations", body, cookies...)
+	var res struct {
+		Data conversation_domain.Conversation `json:"data"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &res) != nil || res.Data.ID == "" {
+		t.Fatalf("create conversation: %d %s", w.Code, w.Body.String())
+	}
+	return res.Data.ID
+}
+
+func TestConversationErrorResponse(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{conversation_domain.ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("load: %w", conversation_domain.ErrNotFound), http.StatusNotFound},
+		{chat.ErrInvalidBranch, http.StatusBadRequest},
+		{chat.ErrStorageUnavailable, http.StatusServiceUnavailable},
+		{&llm.ValidationError{Param: llm.ParamModel, Reason: "unknown"}, http.StatusBadRequest},
+		{errors.New("disk full"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		code, res := conversationErrorResponse(tt.err)
+		if code != tt.code || res.Code == common.CodeSuccess {
+			t.Errorf("conversationErrorResponse(%v) = %d, %+v; want %d", tt.err, code, res, tt.code)
+		}
+	}
+}
+
+func TestConversationHandlers(t *testing.T) {
+	r := newConversationRouter(t, "")
+	id := createTestConversation(t, r, `{"userId":"u1","title":"周末计划"}`)
+	url := "/api/conversations/" + id
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{"get", http.MethodGet, url + "?userId=u1", "", http.StatusOK},
+		// 其他用户的会话与不存在的会话同样返回 404
+		{"get by other user", http.MethodGet, url + "?userId=u2", "", http.StatusNotFound},
+		{"get missing", http.MethodGet, "/api/conversations/missing?userId=u1", "", http.StatusNotFound},
+		{"messages by other user", http.MethodGet, url + "/messages?userId=u2", "", http.StatusNotFound},
+		{"rename by other user", http.MethodPatch, url, `{"userId":"u2","title":"新标题"}`, http.StatusNotFound},
+		{"delete by other user", http.MethodDelete, url + "?userId=u2", "", http.StatusNotFound},
+		{"empty title", http.MethodPatch, url, `{"userId":"u1","title":""}`, http.StatusBadRequest},
+		{"title too long", http.MethodPatch, url, `{"userId":"u1","title":"` + strings.Repeat("长", 201) + `"}`, http.StatusBadRequest},
+		{"create with title too long", http.MethodPost, "/api/conversations", `{"title":"` + strings.Repeat("长", 201) + `"}`, http.StatusBadRequest},
+		{"malformed body", http.MethodPatch, url, `{"title":`, http.StatusBadRequest},
+		{"rename", http.MethodPatch, url, `{"userId":"u1","title":"新标题","archived":true}`, http.StatusOK},
+		{"delete", http.MethodDelete, url + "?userId=u1", "", http.StatusOK},
+		{"get after delete", http.MethodGet, url + "?userId=u1", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := serve(r, tt.method, tt.url, tt.body)
+		if w.Code != tt.code {
+			t.Errorf("%s: %s %s = %d %s, want %d", tt.name, tt.method, tt.url, w.Code, w.Body.String(), tt.code)
+		}
+	}
+}
+
+func TestBranchHandlers(t *testing.T) {
+	r := newConversationRouter(t, "")
+	url := "/api/conversations/" + createTestConversation(t, r, `{"userId":"u1"}`)
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		code   int
+	}{
+		{"branch", http.MethodGet, url + "/branch?userId=u1", "", http.StatusOK},
+		{"branch by other user", http.MethodGet, url + "/branch?userId=u2", "", http.StatusNotFound},
+		// 未携带 userId 时按浏览器会话用户查找
+		{"branch of session user", http.MethodGet, url + "/branch", "", http.StatusNotFound},
+		{"switch to unknown message", http.MethodPut, url + "/branch", `{"userId":"u1","messageId":"missing"}`, http.StatusNotFound},
+		{"switch without message", http.MethodPut, url + "/branch", `{"userId":"u1"}`, http.StatusBadRequest},
+		{"switch by other user", http.MethodPut, url + "/branch", `{"userId":"u2","messageId":"missing"}`, http.StatusNotFound},
+		{"edit without message", http.MethodPost, url + "/messages/m1/edit", `{"userId":"u1"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := serve(r, tt.method, tt.url, tt.body)
+		if w.Code != tt.code {
+			t.Errorf("%s: %s %s = %d %s, want %d", tt.name, tt.method, tt.url, w.Code, w.Body.String(), tt.code)
+		}
+	}
+}
+
+func TestConversationSessionUser(t *testing.T) {
+	r := newConversationRouter(t, "")
+	// 未携带 userId 时以浏览器会话区分用户
+	w := serve(r, http.MethodPost, "/api/conversations", `{"title":"匿名会话"}`)
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != sessionCookie {
+		t.Fatalf("create without userId: %d, cookies %v", w.Code, cookies)
+	}
+	var created struct {
+		Data conversation_domain.Conversation `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Data.UserID != sessionUserPrefix+cookies[0].Value {
+		t.Errorf("owner = %q, want session user", created.Data.UserID)
+	}
+
+	total := func(cookies ...*http.Cookie) int64 {
+		t.Helper()
+		var page common.PageResponse
+		w := serve(r, http.MethodGet, "/api/conversations", "", cookies...)
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &page) != nil {
+			t.Fatalf("list: %d %s", w.Code, w.Body.String())
+		}
+		return page.Total
+	}
+	if n := total(cookies[0]); n != 1 {
+		t.Errorf("same session sees %d conversations, want 1", n)
+	}
+	if n := total(); n != 0 {
+		t.Errorf("new session sees %d conversations, want 0", n)
+	}
+	if w := serve(r, http.MethodGet, "/api/conversations/"+created.Data.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("get from another session = %d, want 404", w.Code)
+	}
+}
+
+func TestConversationPaging(t *testing.T) {
+	r := newConversationRouter(t, "")
+	var id string
+	for i := range 3 {
+		id = createTestConversation(t, r, fmt.Sprintf(`{"userId":"u1","title":"会话%d"}`, i))
+	}
+	tests := []struct {
+		query   string
+		code    int
+		page    int
+		size    int
+		items   int
+		hasMore bool
+	}{
+		{"", http.StatusOK, 1, conversation_domain.DefaultPageSize, 3, false},
+		{"page=1&size=2", http.StatusOK, 1, 2, 2, true},
+		{"page=2&size=2", http.StatusOK, 2, 2, 1, false},
+		{"page=5&size=2", http.StatusOK, 5, 2, 0, false},
+		{"size=100", http.StatusOK, 1, 100, 3, false},
+		// 0 视为未填写
+		{"page=0&size=0", http.StatusOK, 1, conversation_domain.DefaultPageSize, 3, false},
+		{"size=101", http.StatusBadRequest, 0, 0, 0, false},
+		{"size=-1", http.StatusBadRequest, 0, 0, 0, false},
+		{"page=-1", http.StatusBadRequest, 0, 0, 0, false},
+		{"page=abc", http.StatusBadRequest, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		for _, url := range []string{"/api/conversations?userId=u1&", "/api/conversations/" + id + "/messages?userId=u1&"} {
+			w := serve(r, http.MethodGet, url+tt.query, "")
+			if w.Code != tt.code {
+				t.Errorf("GET %s = %d %s, want %d", url+tt.query, w.Code, w.Body.String(), tt.code)
+				continue
+			}
+			if tt.code != http.StatusOK {
+				continue
+			}
+			var page struct {
+				common.PageResponse
+				Data []json.RawMessage `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+				t.Fatal(err)
+			}
+			// 新会话没有消息
+			items, hasMore := tt.items, tt.hasMore
+			if strings.Contains(url, "/messages") {
+				items, hasMore = 0, false
+			}
+			if page.Page != tt.page || page.Size != tt.size || len(page.Data) != items || page.HasMore != hasMore || page.Data == nil {
+				t.Errorf("GET %s = page %d size %d items %d hasMore %v", url+tt.query, page.Page, page.Size, len(page.Data), page.HasMore)
+			}
+		}
+	}
+}
+
+func TestConversationStorageUnavailable(t *testing.T) {
+	r := newConversationRouter(t, "unsupported")
+	for _, req := range []struct{ method, url, body string }{
+		{http.MethodPost, "/api/conversations", `{"userId":"u1"}`},
+		{http.MethodGet, "/api/conversations?userId=u1", ""},
+		{http.MethodGet, "/api/conversations/c1?userId=u1", ""},
+		{http.MethodDelete, "/api/conversations/c1?userId=u1", ""},
+	} {
+		w := serve(r, req.method, req.url, req.body)
+		var res common.Response
+		json.Unmarshal(w.Body.Bytes(), &res)
+		if w.Code != http.StatusServiceUnavailable || res.Code != common.CodeServiceError {
+			t.Errorf("%s %s = %d %s, want 503", req.method, req.url, w.Code, w.Body.String())
+		}
+	}
+}
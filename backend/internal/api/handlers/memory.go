@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ai-companion/backend/internal/common"
+	"github.com/ai-companion/backend/internal/domain/memory_domain"
+	"github.com/ai-companion/backend/internal/service/memory"
+	"github.com/gin-gonic/gin"
+)
+
+type MemoryHandler struct {
+	memoryService *memory.Service
+}
+
+func NewMemoryHandler(memoryService *memory.Service) *MemoryHandler {
+	return &MemoryHandler{memoryService: memoryService}
+}
+
+// List 列出用户的全部记忆
+func (h *MemoryHandler) List(c *gin.Context) {
+	var req memory_domain.ListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(h.memoryService.List(requestUserID(c, req.UserID))))
+}
+
+// Update 修改一条记忆
+func (h *MemoryHandler) Update(c *gin.Context) {
+	var req memory_domain.UpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	fact, err := h.memoryService.Update(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		c.JSON(memoryErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(fact))
+}
+
+// Delete 删除一条记忆
+func (h *MemoryHandler) Delete(c *gin.Context) {
+	var req memory_domain.DeleteRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	if err := h.memoryService.Delete(requestUserID(c, req.UserID), c.Param("id")); err != nil {
+		c.JSON(memoryErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewSuccess(nil))
+}
+
+func memoryErrorResponse(err error) (int, *common.Response) {
+	if errors.Is(err, memory.ErrNotFound) {
+		return http.StatusNotFound, common.NewError(common.CodeNotFound, common.MsgNotFound)
+	}
+	return errorResponse(err)
+}
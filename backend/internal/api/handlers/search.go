@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ai-companion/backend/internal/common"
+	"github.com/ai-companion/backend/internal/domain/search_domain"
+	"github.com/ai-companion/backend/internal/service/chat"
+	"github.com/ai-companion/backend/internal/service/search"
+	"github.com/gin-gonic/gin"
+)
+
+type SearchHandler struct {
+	chatService *chat.Service
+}
+
+func NewSearchHandler(chatService *chat.Service) *SearchHandler {
+	return &SearchHandler{chatService: chatService}
+}
+
+// Search 在用户的聊天记录中全文搜索，按相关度分页返回命中的消息与高亮片段
+func (h *SearchHandler) Search(c *gin.Context) {
+	var req search_domain.Request
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, common.NewRequestError())
+		return
+	}
+	req.UserID = requestUserID(c, req.UserID)
+	results, total, err := h.chatService.SearchMessages(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(searchErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, common.NewPageResponse(results, req.Page, req.Size, total))
+}
+
+func searchErrorResponse(err error) (int, *common.Response) {
+	if errors.Is(err, search_domain.ErrInvalidTimeRange) {
+		return http.StatusBadRequest, common.NewError(common.CodeBadRequest, err.Error())
+	}
+	if errors.Is(err, search.ErrUnavailable) {
+		return http.StatusServiceUnavailable, common.NewError(common.CodeServiceError, common.MsgServiceError)
+	}
+	return errorResponse(err)
+}
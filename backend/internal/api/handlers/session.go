@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	// sessionCookie 未携带用户ID的请求以该 cookie 中的浏览器会话标识区分用户
+	sessionCookie = "companion_session"
+	// sessionMaxAge 浏览器会话标识的有效期（秒）
+	sessionMaxAge = 30 * 24 * 3600
+	// sessionUserPrefix 浏览器会话用户的ID前缀，与登录用户的ID区分
+	sessionUserPrefix = "session:"
+)
+
+// requestUserID 返回请求所属的用户：携带 userId 时直接使用，否则以浏览器会话作为匿名用户，
+// 没有会话标识时生成一个并写入 cookie，避免所有匿名请求共用同一份会话与记忆
+func requestUserID(c *gin.Context, userID string) string {
+	if userID != "" {
+		return userID
+	}
+	session, err := c.Cookie(sessionCookie)
+	if err != nil || uuid.Validate(session) != nil {
+		session = uuid.NewString()
+		c.SetCookie(sessionCookie, session, sessionMaxAge, "/", "", false, true)
+	}
+	return sessionUserPrefix + session
+}
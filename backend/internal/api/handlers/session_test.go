@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestUserID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const session = "0f8fad5b-d9cb-469f-a165-70867728950e"
+	tests := []struct {
+		name      string
+		userID    string
+		cookie    string
+		want      string
+		setCookie bool
+	}{
+		{"explicit user", "u1", session, "u1", false},
+		{"existing session", "", session, sessionUserPrefix + session, false},
+		{"new session", "", "", "", true},
+		{"invalid session", "", "../../etc", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				c.Request.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.cookie})
+			}
+			got := requestUserID(c, tt.userID)
+
+			cookies := w.Result().Cookies()
+			if !tt.setCookie {
+				if got != tt.want || len(cookies) != 0 {
+					t.Errorf("requestUserID = %q, cookies = %v; want %q without new cookie", got, cookies, tt.want)
+				}
+				return
+			}
+			if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+				t.Fatalf("cookies = %v", cookies)
+			}
+			if got != sessionUserPrefix+cookies[0].Value || got == sessionUserPrefix+tt.cookie {
+				t.Errorf("requestUserID = %q, cookie = %q", got, cookies[0].Value)
+			}
+			if !strings.HasPrefix(got, sessionUserPrefix) {
+				t.Errorf("requestUserID = %q, want session user", got)
+			}
+		})
+	}
+
+	// 不同浏览器会话得到不同的匿名用户
+	a, b := httptest.NewRecorder(), httptest.NewRecorder()
+	ca, _ := gin.CreateTestContext(a)
+	cb, _ := gin.CreateTestContext(b)
+	ca.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	cb.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if requestUserID(ca, "") == requestUserID(cb, "") {
+		t.Error("two new sessions share a user")
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"time"
 
 	"github.com/ai-companion/backend/internal/api/handlers"
+	"github.com/ai-companion/backend/internal/pkg/logger"
 	"github.com/ai-companion/backend/internal/service/chat"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouters(router *gin.Engine) {
+// SetupRouters 注册全部路由，返回的 cleanup 用于在服务退出时释放资源
+func SetupRouters(router *gin.Engine) (cleanup func()) {
 
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173", "http://127.0.0.1:5173"},
@@ -26,10 +28,41 @@ func SetupRouters(router *gin.Engine) {
 		// 创建聊天服务和处理器
 		chatService := chat.NewService()
 		chatHandler := handlers.NewChatHandler(chatService)
+		cleanup = func() {
+			if err := chatService.Close(); err != nil {
+				logger.Errorf("close chat service error: %s", err.Error())
+			}
+		}
 
 		// 聊天相关路由
 		api.POST("/chat", chatHandler.Chat)
 		api.GET("/chatStream", chatHandler.ChatStream)
+		api.GET("/models", chatHandler.Models)
+
+		// 长期记忆
+		memoryHandler := handlers.NewMemoryHandler(chatService.Memory())
+		memories := api.Group("/memories")
+		memories.GET("", memoryHandler.List)
+		memories.PUT("/:id", memoryHandler.Update)
+		memories.DELETE("/:id", memoryHandler.Delete)
+
+		// 会话管理
+		conversationHandler := handlers.NewConversationHandler(chatService)
+		conversations := api.Group("/conversations")
+		conversations.POST("", conversationHandler.Create)
+		conversations.GET("", conversationHandler.List)
+		conversations.GET("/:id", conversationHandler.Get)
+		conversations.PATCH("/:id", conversationHandler.Update)
+		conversations.DELETE("/:id", conversationHandler.Delete)
+		conversations.GET("/:id/messages", conversationHandler.Messages)
+		conversations.POST("/:id/messages/:messageId/regenerate", conversationHandler.Regenerate)
+		conversations.POST("/:id/messages/:messageId/edit", conversationHandler.Edit)
+		conversations.GET("/:id/branch", conversationHandler.Branch)
+		conversations.PUT("/:id/branch", conversationHandler.SwitchBranch)
+
+		// 聊天记录搜索
+		searchHandler := handlers.NewSearchHandler(chatService)
+		api.GET("/search", searchHandler.Search)
 	}
 
 	// 根路径
@@ -38,4 +71,5 @@ func SetupRouters(router *gin.Engine) {
 			"message": "Hello Go",
 		})
 	})
+	return cleanup
 }
@@ -3,12 +3,91 @@ package chat_domain
 // Request 聊天请求结构
 type Request struct {
 	Message string `json:"message" binding:"required" form:"message"`
-	UserID  string `json:"userId,omitempty" form:"userId"`
+	// ConversationID 追加到指定的会话，为空时使用用户最近的未归档会话，没有时新建
+	ConversationID string `json:"conversationId,omitempty" form:"conversationId"`
+	Options
+}
+
+// RegenerateRequest 重新生成一条回复，原回复作为兄弟分支保留
+type RegenerateRequest struct {
+	Options
+}
+
+// EditRequest 修改一条用户消息：在原消息旁产生新的分支并生成回复，原消息保留
+type EditRequest struct {
+	Message string `json:"message" binding:"required"`
+	Options
+}
+
+// Options 生成回复的选项
+type Options struct {
+	UserID string `json:"userId,omitempty" form:"userId"`
+	// Model 选择具名的模型配置，为空时使用默认模型
+	Model string `json:"model,omitempty" form:"model"`
+	// SystemPrompt 完整替换本次请求的系统提示词
+	SystemPrompt string `json:"systemPrompt,omitempty" form:"systemPrompt"`
+	// Persona 覆盖配置中人设的部分字段，未填写的字段沿用配置
+	Persona *Persona `json:"persona,omitempty"`
+	// Timezone 用户所在的 IANA 时区，如 Asia/Shanghai，供时间相关的工具使用，为空时使用 tools.timezone
+	Timezone string `json:"timezone,omitempty" form:"timezone"`
+
+	// 可选的生成参数，未填写时使用 llm 配置中的默认值
+	Temperature      *float64 `json:"temperature,omitempty" form:"temperature"`
+	TopP             *float64 `json:"topP,omitempty" form:"topP"`
+	MaxTokens        *int     `json:"maxTokens,omitempty" form:"maxTokens"`
+	Stop             []string `json:"stop,omitempty" form:"stop"`
+	Seed             *int     `json:"seed,omitempty" form:"seed"`
+	PresencePenalty  *float64 `json:"presencePenalty,omitempty" form:"presencePenalty"`
+	FrequencyPenalty *float64 `json:"frequencyPenalty,omitempty" form:"frequencyPenalty"`
+}
+
+// Persona 人设覆盖
+type Persona struct {
+	Name            string     `json:"name,omitempty"`
+	Personality     string     `json:"personality,omitempty"`
+	SpeakingStyle   string     `json:"speakingStyle,omitempty"`
+	ExampleDialogue []Dialogue `json:"exampleDialogue,omitempty"`
+	Greeting        string     `json:"greeting,omitempty"`
+	Instructions    string     `json:"instructions,omitempty"`
+	// EnabledTools、DisabledTools 覆盖人设允许与禁止使用的工具
+	EnabledTools  []string `json:"enabledTools,omitempty"`
+	DisabledTools []string `json:"disabledTools,omitempty"`
+}
+
+// Dialogue 一轮示例对话
+type Dialogue struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
 }
 
 // Response 聊天响应结构
 type Response struct {
 	Reply     string `json:"reply"`
 	MessageID string `json:"messageId"`
-	Timestamp int64  `json:"timestamp"`
+	// ConversationID 本轮对话所在的会话
+	ConversationID string `json:"conversationId,omitempty"`
+	Timestamp      int64  `json:"timestamp"`
+	// 以下字段仅在完整回复（非流式响应、流式的 end 事件）中返回
+	Model        string `json:"model,omitempty"`
+	FinishReason string `json:"finishReason,omitempty"`
+	Usage        *Usage `json:"usage,omitempty"`
+	// Reasoning 推理模型的思考过程，仅在 reasoning.mode 为 forward 时返回；流式响应中以 reasoning 事件单独推送
+	Reasoning string `json:"reasoning,omitempty"`
+}
+
+// Usage token 用量
+type Usage struct {
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+	TotalTokens      int `json:"totalTokens"`
+}
+
+// ModelInfo 可选的模型配置
+type ModelInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Provider    string `json:"provider"`
+	Model       string `json:"model"`
+	Default     bool   `json:"default"`
+	Available   bool   `json:"available"`
 }
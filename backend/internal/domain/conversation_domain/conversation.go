@@ -0,0 +1,100 @@
+package conversation_domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound 会话或消息不存在
+var ErrNotFound = errors.New("record not found")
+
+// Conversation 一个会话
+type Conversation struct {
+	ID     string `json:"id"`
+	UserID string `json:"userId"`
+	Title  string `json:"title"`
+	// Summary 较早轮次的滚动摘要
+	Summary   string    `json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// ArchivedAt 归档时间，未归档时为空
+	ArchivedAt *time.Time `json:"archivedAt,omitempty"`
+	// ActiveMessageID 当前分支末尾的消息
+	ActiveMessageID string `json:"activeMessageId,omitempty"`
+}
+
+// Message 会话中的一条消息。消息按 ParentID 组成树，重新生成回复或编辑消息时产生兄弟节点
+type Message struct {
+	ID             string `json:"id"`
+	ConversationID string `json:"conversationId"`
+	// ParentID 上一条消息，会话的第一条消息为空
+	ParentID string `json:"parentId,omitempty"`
+	Role     string `json:"role"`
+	Content  string `json:"content"`
+	// 以下字段仅 assistant 消息填写
+	Model            string    `json:"model,omitempty"`
+	FinishReason     string    `json:"finishReason,omitempty"`
+	PromptTokens     int       `json:"promptTokens,omitempty"`
+	CompletionTokens int       `json:"completionTokens,omitempty"`
+	TotalTokens      int       `json:"totalTokens,omitempty"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// MessageNode 消息在树中的位置，用于计算分支
+type MessageNode struct {
+	ID       string
+	ParentID string
+	// Seq 消息在会话内的写入顺序，创建时间相同的兄弟消息按它排列
+	Seq       int64
+	CreatedAt time.Time
+}
+
+// BranchMessage 当前分支上的一条消息，SiblingIDs 为同一父消息下的全部消息（含自身），按写入顺序排列
+type BranchMessage struct {
+	*Message
+	SiblingIDs []string `json:"siblingIds"`
+}
+
+// ListQuery 列出用户会话的条件
+type ListQuery struct {
+	UserID string
+	// Archived 为 true 时只列出已归档的会话，否则只列出未归档的会话
+	Archived bool
+	Offset   int
+	Limit    int
+}
+
+// ConversationRepository 会话的存储
+type ConversationRepository interface {
+	Create(ctx context.Context, conv *Conversation) error
+	Get(ctx context.Context, id string) (*Conversation, error)
+	// Latest 返回用户最近更新的未归档会话，没有时返回 ErrNotFound
+	Latest(ctx context.Context, userID string) (*Conversation, error)
+	// List 按更新时间从新到旧返回一页会话及符合条件的总数
+	List(ctx context.Context, query ListQuery) ([]*Conversation, int64, error)
+	Rename(ctx context.Context, id, title string) error
+	// SetActive 把当前分支切换到以 messageID 结尾的分支
+	SetActive(ctx context.Context, id, messageID string) error
+	// SetArchived 设置归档时间，archivedAt 为空时取消归档
+	SetArchived(ctx context.Context, id string, archivedAt *time.Time) error
+	UpdateSummary(ctx context.Context, id, summary string) error
+	// Delete 删除会话及其全部消息
+	Delete(ctx context.Context, id string) error
+}
+
+// MessageRepository 消息的存储
+type MessageRepository interface {
+	// Append 在一个事务中追加消息，刷新会话的更新时间并把当前分支指向最后一条消息
+	Append(ctx context.Context, messages ...*Message) error
+	Get(ctx context.Context, id string) (*Message, error)
+	// GetMany 返回 ids 中存在的消息，顺序不定
+	GetMany(ctx context.Context, ids []string) ([]*Message, error)
+	// Path 返回从会话开头到 leafID 的分支上最近的至多 limit 条消息，按时间顺序排列；limit 不大于 0 时返回整条分支
+	Path(ctx context.Context, leafID string, limit int) ([]*Message, error)
+	// Nodes 按写入顺序返回会话中全部消息在树中的位置
+	Nodes(ctx context.Context, conversationID string) ([]*MessageNode, error)
+	// List 按写入顺序返回会话中的一页消息及消息总数
+	List(ctx context.Context, conversationID string, offset, limit int) ([]*Message, int64, error)
+}
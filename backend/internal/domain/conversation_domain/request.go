@@ -0,0 +1,66 @@
+package conversation_domain
+
+// 分页参数
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// PageRequest 页码从 1 开始，未填写时返回第一页
+type PageRequest struct {
+	Page int `form:"page" binding:"omitempty,min=1"`
+	Size int `form:"size" binding:"omitempty,min=1,max=100"`
+}
+
+// Normalize 补全未填写的分页参数
+func (p *PageRequest) Normalize() {
+	if p.Page <= 0 {
+		p.Page = 1
+	}
+	if p.Size <= 0 {
+		p.Size = DefaultPageSize
+	}
+	p.Size = min(p.Size, MaxPageSize)
+}
+
+// Offset 返回当前页之前的记录数
+func (p *PageRequest) Offset() int {
+	return (p.Page - 1) * p.Size
+}
+
+// CreateRequest 新建会话。UserID 为空时属于浏览器会话用户，标题为空时以第一条消息的开头作为标题
+type CreateRequest struct {
+	UserID string `json:"userId,omitempty"`
+	Title  string `json:"title,omitempty" binding:"max=200"`
+}
+
+// ListRequest 列出用户的会话
+type ListRequest struct {
+	UserID   string `form:"userId"`
+	Archived bool   `form:"archived"`
+	PageRequest
+}
+
+// UpdateRequest 重命名或归档会话，未填写的字段保持不变
+type UpdateRequest struct {
+	UserID   string  `json:"userId,omitempty"`
+	Title    *string `json:"title,omitempty" binding:"omitempty,min=1,max=200"`
+	Archived *bool   `json:"archived,omitempty"`
+}
+
+// OwnerRequest 操作单个会话时用于校验归属
+type OwnerRequest struct {
+	UserID string `form:"userId"`
+}
+
+// ListMessagesRequest 按时间顺序分页列出会话中的消息
+type ListMessagesRequest struct {
+	UserID string `form:"userId"`
+	PageRequest
+}
+
+// SwitchBranchRequest 切换到包含 MessageID 的分支，分支末尾为其下最新的后续消息
+type SwitchBranchRequest struct {
+	UserID    string `json:"userId,omitempty"`
+	MessageID string `json:"messageId" binding:"required"`
+}
@@ -0,0 +1,32 @@
+package memory_domain
+
+import "time"
+
+// Fact 记住的一条关于用户的长期事实
+type Fact struct {
+	ID       string `json:"id"`
+	UserID   string `json:"userId"`
+	Content  string `json:"content"`
+	Category string `json:"category"`
+	// Source 来源：extracted(从对话中提取)/manual(手动编辑)
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ListRequest 列出用户的记忆。UserID 为空时使用浏览器会话用户
+type ListRequest struct {
+	UserID string `form:"userId"`
+}
+
+// UpdateRequest 修改一条记忆
+type UpdateRequest struct {
+	UserID   string `json:"userId,omitempty"`
+	Content  string `json:"content" binding:"required"`
+	Category string `json:"category,omitempty"`
+}
+
+// DeleteRequest 删除一条记忆
+type DeleteRequest struct {
+	UserID string `form:"userId"`
+}
@@ -0,0 +1,69 @@
+package search_domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+)
+
+// ErrInvalidTimeRange from 或 to 无法解析，或 from 晚于 to
+var ErrInvalidTimeRange = errors.New("invalid time range")
+
+// dateLayout 只填写日期时按服务所在时区的整天计算
+const dateLayout = "2006-01-02"
+
+// Request 在用户的聊天记录中搜索。from、to 为 RFC3339 时间或 2006-01-02 格式的日期，日期范围包含 to 当天。
+// UserID 为空时使用浏览器会话用户
+type Request struct {
+	UserID         string `form:"userId"`
+	Query          string `form:"q" binding:"required,max=200"`
+	ConversationID string `form:"conversationId"`
+	Role           string `form:"role" binding:"omitempty,oneof=user assistant"`
+	From           string `form:"from"`
+	To             string `form:"to"`
+	conversation_domain.PageRequest
+}
+
+// TimeRange 解析时间范围，返回的 to 不含在范围内，未填写的一端为零值
+func (r *Request) TimeRange() (from, to time.Time, err error) {
+	if from, _, err = parseTime(r.From); err != nil {
+		return
+	}
+	var date bool
+	if to, date, err = parseTime(r.To); err != nil {
+		return
+	}
+	if date {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		err = ErrInvalidTimeRange
+	}
+	return
+}
+
+// parseTime 解析 RFC3339 时间或日期，date 表示只填写了日期
+func parseTime(value string) (t time.Time, date bool, err error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if t, err = time.ParseInLocation(dateLayout, value, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err = time.Parse(time.RFC3339, value); err != nil {
+		return time.Time{}, false, ErrInvalidTimeRange
+	}
+	return t, false, nil
+}
+
+// Result 命中的一条消息。Snippet 为消息中命中附近的片段，命中的部分以 <mark> 标记，其余文本已经过 HTML 转义
+type Result struct {
+	MessageID         string    `json:"messageId"`
+	ConversationID    string    `json:"conversationId"`
+	ConversationTitle string    `json:"conversationTitle"`
+	Role              string    `json:"role"`
+	Snippet           string    `json:"snippet"`
+	Score             float64   `json:"score"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// 支持的数据库
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+const defaultSQLitePath = "./data/companion.db"
+
+// migrateTimeout 启动时检查与执行迁移的超时
+const migrateTimeout = 2 * time.Minute
+
+// DB 数据库连接，屏蔽 SQLite 与 Postgres 在占位符等方面的差异
+type DB struct {
+	*sql.DB
+	Driver string
+}
+
+// Open 按配置打开数据库并检查表结构：开启 autoMigrate 时执行未执行的迁移，否则表结构不是最新时返回错误
+func Open(cfg *config.DatabaseConfig) (*DB, error) {
+	db, err := Connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.checkSchema(cfg.AutoMigrate); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Connect 按配置连接数据库，不检查表结构。未配置 driver 时使用内嵌的 SQLite
+func Connect(cfg *config.DatabaseConfig) (*DB, error) {
+	driver := cfg.Driver
+	if driver == "" {
+		driver = DriverSQLite
+	}
+	var db *sql.DB
+	var err error
+	switch driver {
+	case DriverSQLite:
+		db, err = openSQLite(cfg.Path)
+	case DriverPostgres:
+		db, err = sql.Open("pgx", postgresDSN(cfg))
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("connect %s: %w", driver, err)
+	}
+	return &DB{DB: db, Driver: driver}, nil
+}
+
+func (db *DB) checkSchema(autoMigrate bool) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), migrateTimeout)
+	defer cancel()
+	if !autoMigrate {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("database schema is %d migration(s) behind version %d, run `migrate up` or enable database.autoMigrate",
+				len(pending), migrator.Latest())
+		}
+		return nil
+	}
+	applied, err := migrator.Up(ctx, 0)
+	for _, m := range applied {
+		logger.Info("database migration applied: " + m.String())
+	}
+	if err != nil {
+		return fmt.Errorf("migrate database: %w", err)
+	}
+	return nil
+}
+
+func openSQLite(path string) (*sql.DB, error) {
+	if path == "" {
+		path = defaultSQLitePath
+	}
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	// WAL 允许读写并发，busy_timeout 让并发写入排队而不是直接失败
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if path == ":memory:" {
+		// 每个连接各自拥有一份内存数据库，只能使用单个连接
+		db.SetMaxOpenConns(1)
+	}
+	return db, nil
+}
+
+func postgresDSN(cfg *config.DatabaseConfig) string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.User, cfg.Password),
+		Host:   cfg.Host,
+		Path:   "/" + cfg.DBName,
+	}
+	if cfg.Port != 0 {
+		u.Host += ":" + strconv.Itoa(cfg.Port)
+	}
+	if cfg.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {cfg.SSLMode}}.Encode()
+	}
+	return u.String()
+}
+
+// Rebind 把查询中的 ? 占位符转换为当前数据库的写法
+func (db *DB) Rebind(query string) string {
+	if db.Driver != DriverPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,381 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// migrationFiles 内嵌的迁移脚本，文件名为 <版本>_<名称>.<up|down>.sql；
+// 只适用于一种数据库的脚本写作 <版本>_<名称>.<sqlite|postgres>.<up|down>.sql，优先于通用脚本
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+?)(?:\.(sqlite|postgres))?\.(up|down)\.sql$`)
+
+// 迁移的默认参数
+const (
+	defaultLockTimeout = 30 * time.Second
+	// staleLockAge SQLite 的锁超过该时长仍未释放时视为持有者已退出
+	staleLockAge = 10 * time.Minute
+	// postgresLockKey Postgres advisory lock 的键
+	postgresLockKey = 7_316_220_122
+)
+
+// ErrLocked 等待其他进程的迁移超时
+var ErrLocked = errors.New("migrations are locked by another process")
+
+// Migration 一个版本的表结构变更
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus 迁移的执行情况，AppliedAt 为零值时表示尚未执行
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time
+}
+
+// Migrator 按版本执行内嵌的迁移。执行记录保存在 schema_migrations 表中，
+// 同一数据库上的多个执行者通过锁串行执行，每个版本在单独的事务中执行
+type Migrator struct {
+	db         *DB
+	migrations []Migration
+	// DryRun 只把将要执行的 SQL 输出到 Out，不修改数据库
+	DryRun bool
+	Out    io.Writer
+	// LockTimeout 等待其他执行者释放锁的时长
+	LockTimeout time.Duration
+}
+
+func NewMigrator(db *DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles, db.Driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, Out: os.Stdout, LockTimeout: defaultLockTimeout}, nil
+}
+
+// loadMigrations 读取 migrations 目录下的脚本，按版本排列
+func loadMigrations(fsys fs.FS, driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	// 通用脚本先读，数据库专用的脚本覆盖之
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Count(a.Name(), ".") - strings.Count(b.Name(), ".")
+	})
+	for _, entry := range entries {
+		match := migrationName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+		if match[3] != "" && match[3] != driver {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d has two names: %s, %s", version, m.Name, match[2])
+		}
+		data, err := fs.ReadFile(fsys, path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if match[4] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	slices.SortFunc(migrations, func(a, b Migration) int { return int(a.Version - b.Version) })
+	return migrations, nil
+}
+
+// Latest 返回最新的迁移版本
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status 返回全部迁移的执行情况
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		res = append(res, MigrationStatus{Migration: mig, AppliedAt: applied[mig.Version]})
+	}
+	return res, nil
+}
+
+// Pending 返回尚未执行的迁移
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	return m.pending(applied, 0), nil
+}
+
+// Up 依次执行版本不超过 target 的未执行迁移，target 不大于 0 时执行到最新版本。返回已执行（dry-run 时为将要执行）的迁移
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	return m.run(ctx, true, func(applied map[int64]time.Time) ([]Migration, error) {
+		return m.pending(applied, target), nil
+	})
+}
+
+// Down 按版本从新到旧回滚 steps 个已执行的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	return m.run(ctx, false, func(applied map[int64]time.Time) ([]Migration, error) {
+		var res []Migration
+		for i := len(m.migrations) - 1; i >= 0 && len(res) < steps; i-- {
+			mig := m.migrations[i]
+			if applied[mig.Version].IsZero() {
+				continue
+			}
+			if strings.TrimSpace(mig.Down) == "" {
+				return nil, fmt.Errorf("migration %s cannot be rolled back", mig)
+			}
+			res = append(res, mig)
+		}
+		return res, nil
+	})
+}
+
+func (m *Migrator) pending(applied map[int64]time.Time, target int64) []Migration {
+	var res []Migration
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if applied[mig.Version].IsZero() {
+			res = append(res, mig)
+		}
+	}
+	return res
+}
+
+// run 在持有锁的连接上按 plan 的结果逐个执行迁移，up 为 false 时执行回滚
+func (m *Migrator) run(ctx context.Context, up bool, plan func(applied map[int64]time.Time) ([]Migration, error)) ([]Migration, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if m.DryRun {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		steps, err := plan(applied)
+		if err != nil {
+			return nil, err
+		}
+		for _, mig := range steps {
+			fmt.Fprintf(m.Out, "-- %s %s\n%s\n", direction(up), mig, strings.TrimSpace(script(mig, up)))
+		}
+		return steps, nil
+	}
+
+	if err := m.createTables(ctx, conn); err != nil {
+		return nil, err
+	}
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	// 拿到锁之后再读取执行记录，其他执行者已完成的迁移不会重复执行
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := plan(applied)
+	if err != nil {
+		return nil, err
+	}
+	for i, mig := range steps {
+		if err := m.apply(ctx, conn, mig, up); err != nil {
+			return steps[:i], fmt.Errorf("%s %s: %w", direction(up), mig, err)
+		}
+	}
+	return steps, nil
+}
+
+// apply 在一个事务中执行迁移脚本并更新执行记录
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if sqlText := script(mig, up); strings.TrimSpace(sqlText) != "" {
+		if _, err := tx.ExecContext(ctx, sqlText); err != nil {
+			return err
+		}
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, m.db.Rebind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+			mig.Version, mig.Name, time.Now().UnixMilli())
+	} else {
+		_, err = tx.ExecContext(ctx, m.db.Rebind("DELETE FROM schema_migrations WHERE version = ?"), mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// applied 读取执行记录，记录表不存在时视为尚未执行任何迁移
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int64]time.Time, error) {
+	res := make(map[int64]time.Time)
+	var exists bool
+	var err error
+	if m.db.Driver == DriverPostgres {
+		err = q.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists)
+	} else {
+		err = q.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&exists)
+	}
+	if err != nil || !exists {
+		return res, err
+	}
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var version, at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		res[version] = time.UnixMilli(at)
+	}
+	return res, rows.Err()
+}
+
+func (m *Migrator) createTables(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       VARCHAR(255) NOT NULL,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil || m.db.Driver == DriverPostgres {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+		id        INTEGER PRIMARY KEY CHECK (id = 1),
+		owner     VARCHAR(64) NOT NULL,
+		locked_at BIGINT NOT NULL
+	)`)
+	return err
+}
+
+// lock 获取迁移锁，返回释放函数。Postgres 使用会话级的 advisory lock，连接断开时自动释放；
+// SQLite 在锁表中写入唯一的一行，持有过久的锁视为执行者已退出
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (func(), error) {
+	owner := uuid.NewString()
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		acquired, err := m.tryLock(ctx, conn, owner)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return func() { m.unlock(conn, owner) }, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func (m *Migrator) tryLock(ctx context.Context, conn *sql.Conn, owner string) (bool, error) {
+	if m.db.Driver == DriverPostgres {
+		var acquired bool
+		err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", postgresLockKey).Scan(&acquired)
+		return acquired, err
+	}
+	now := time.Now()
+	if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE locked_at < ?", now.Add(-staleLockAge).UnixMilli()); err != nil {
+		return false, err
+	}
+	res, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, ?, ?) ON CONFLICT DO NOTHING",
+		owner, now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (m *Migrator) unlock(conn *sql.Conn, owner string) {
+	// 调用方的 ctx 可能已经取消，释放锁不受其影响
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if m.db.Driver == DriverPostgres {
+		_, _ = conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", postgresLockKey)
+		return
+	}
+	_, _ = conn.ExecContext(ctx, "DELETE FROM schema_migrations_lock WHERE id = 1 AND owner = ?", owner)
+}
+
+func script(mig Migration, up bool) string {
+	if up {
+		return mig.Up
+	}
+	return mig.Down
+}
+
+func direction(up bool) string {
+	if up {
+		return "up"
+	}
+	return "down"
+}
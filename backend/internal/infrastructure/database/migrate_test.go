@@ -0,0 +1,199 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Connect(&config.DatabaseConfig{Driver: DriverSQLite, Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func newTestMigrator(t *testing.T, db *DB) *Migrator {
+	t.Helper()
+	m, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	return m
+}
+
+func tableExists(t *testing.T, db *DB, name string) bool {
+	t.Helper()
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func appliedVersions(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	status, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res []int64
+	for _, s := range status {
+		if !s.AppliedAt.IsZero() {
+			res = append(res, s.Version)
+		}
+	}
+	return res
+}
+
+func TestMigrateUpDownUp(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m := newTestMigrator(t, db)
+	total := len(m.migrations)
+
+	applied, err := m.Up(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != total {
+		t.Fatalf("applied %d migrations, want %d", len(applied), total)
+	}
+	if got := appliedVersions(t, m); len(got) != total || got[len(got)-1] != m.Latest() {
+		t.Errorf("recorded versions = %v", got)
+	}
+	for _, table := range []string{"conversations", "messages", "schema_migrations"} {
+		if !tableExists(t, db, table) {
+			t.Errorf("table %s does not exist after up", table)
+		}
+	}
+	if pending, _ := m.Pending(ctx); len(pending) != 0 {
+		t.Errorf("pending after up = %v", pending)
+	}
+	// 再次执行时没有需要执行的迁移
+	if again, err := m.Up(ctx, 0); err != nil || len(again) != 0 {
+		t.Errorf("second up = %v, %v", again, err)
+	}
+
+	// 回滚一步只撤销最新的版本
+	rolled, err := m.Down(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rolled) != 1 || rolled[0].Version != m.Latest() {
+		t.Errorf("down 1 = %v", rolled)
+	}
+	if got := appliedVersions(t, m); len(got) != total-1 {
+		t.Errorf("recorded versions after down 1 = %v", got)
+	}
+
+	if _, err := m.Down(ctx, total); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedVersions(t, m); len(got) != 0 {
+		t.Errorf("recorded versions after down all = %v", got)
+	}
+	for _, table := range []string{"conversations", "messages"} {
+		if tableExists(t, db, table) {
+			t.Errorf("table %s still exists after down", table)
+		}
+	}
+
+	applied, err = m.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("up after down: %v", err)
+	}
+	if len(applied) != total || len(appliedVersions(t, m)) != total {
+		t.Errorf("up after down applied %d of %d", len(applied), total)
+	}
+}
+
+func TestMigrateTarget(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMigrator(t, openTestDB(t))
+	first := m.migrations[0].Version
+	applied, err := m.Up(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 1 || applied[0].Version != first {
+		t.Errorf("up to %d = %v", first, applied)
+	}
+	if pending, _ := m.Pending(ctx); len(pending) != len(m.migrations)-1 {
+		t.Errorf("pending = %v", pending)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	m := newTestMigrator(t, db)
+	var out bytes.Buffer
+	m.DryRun, m.Out = true, &out
+	steps, err := m.Up(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != len(m.migrations) || !strings.Contains(out.String(), "-- up "+m.migrations[0].String()) {
+		t.Errorf("steps = %v, output = %q", steps, out.String())
+	}
+	if tableExists(t, db, "schema_migrations") || tableExists(t, db, "conversations") {
+		t.Error("dry run modified the database")
+	}
+}
+
+func TestMigrateLock(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	holder := newTestMigrator(t, db)
+
+	// 第一个执行者持有锁
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := holder.createTables(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := holder.lock(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := newTestMigrator(t, db)
+	other.LockTimeout = 100 * time.Millisecond
+	if _, err := other.Up(ctx, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("up while locked: err = %v, want ErrLocked", err)
+	}
+	if got := appliedVersions(t, other); len(got) != 0 {
+		t.Errorf("migrations applied while locked: %v", got)
+	}
+
+	unlock()
+	if applied, err := other.Up(ctx, 0); err != nil || len(applied) != len(other.migrations) {
+		t.Errorf("up after unlock = %v, %v", applied, err)
+	}
+
+	// 持有者退出后遗留的过期锁会被清理
+	if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, 'gone', ?)",
+		time.Now().Add(-staleLockAge-time.Minute).UnixMilli()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Down(ctx, 1); err != nil {
+		t.Errorf("down with a stale lock: %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+-- 会话与消息表。时间以 Unix 毫秒保存，便于两种数据库按范围比较
+CREATE TABLE IF NOT EXISTS conversations (
+    id         VARCHAR(64) PRIMARY KEY,
+    user_id    VARCHAR(128) NOT NULL,
+    title      TEXT NOT NULL DEFAULT '',
+    summary    TEXT NOT NULL DEFAULT '',
+    created_at BIGINT NOT NULL,
+    updated_at BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversations_user ON conversations (user_id, updated_at);
+
+CREATE TABLE IF NOT EXISTS messages (
+    id                VARCHAR(64) PRIMARY KEY,
+    conversation_id   VARCHAR(64) NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+    role              VARCHAR(16) NOT NULL,
+    content           TEXT NOT NULL,
+    model             VARCHAR(128) NOT NULL DEFAULT '',
+    finish_reason     VARCHAR(32) NOT NULL DEFAULT '',
+    prompt_tokens     INTEGER NOT NULL DEFAULT 0,
+    completion_tokens INTEGER NOT NULL DEFAULT 0,
+    total_tokens      INTEGER NOT NULL DEFAULT 0,
+    created_at        BIGINT NOT NULL,
+    updated_at        BIGINT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages (conversation_id, created_at);
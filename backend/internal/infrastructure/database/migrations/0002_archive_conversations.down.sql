@@ -0,0 +1 @@
+ALTER TABLE conversations DROP COLUMN archived_at;
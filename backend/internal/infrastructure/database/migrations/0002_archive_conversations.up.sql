@@ -0,0 +1,2 @@
+-- 归档时间，0 表示未归档
+ALTER TABLE conversations ADD COLUMN archived_at BIGINT NOT NULL DEFAULT 0;
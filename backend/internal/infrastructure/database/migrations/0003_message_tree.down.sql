@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_parent;
+ALTER TABLE conversations DROP COLUMN active_message_id;
+ALTER TABLE messages DROP COLUMN parent_id;
@@ -0,0 +1,22 @@
+-- 消息组成树：parent_id 为上一条消息，会话根部的消息为空；
+-- active_message_id 指向当前分支的末尾，发送给模型的历史沿它向上回溯
+ALTER TABLE messages ADD COLUMN parent_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE conversations ADD COLUMN active_message_id VARCHAR(64) NOT NULL DEFAULT '';
+
+-- 已有的会话都是单链，按时间顺序串起来
+UPDATE messages SET parent_id = COALESCE((
+    SELECT p.id FROM messages p
+    WHERE p.conversation_id = messages.conversation_id
+      AND (p.created_at < messages.created_at OR (p.created_at = messages.created_at AND p.id < messages.id))
+    ORDER BY p.created_at DESC, p.id DESC
+    LIMIT 1
+), '');
+
+UPDATE conversations SET active_message_id = COALESCE((
+    SELECT m.id FROM messages m
+    WHERE m.conversation_id = conversations.id
+    ORDER BY m.created_at DESC, m.id DESC
+    LIMIT 1
+), '');
+
+CREATE INDEX IF NOT EXISTS idx_messages_parent ON messages (conversation_id, parent_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_seq;
+ALTER TABLE messages DROP COLUMN seq;
+DROP SEQUENCE IF EXISTS messages_seq;
@@ -0,0 +1,13 @@
+-- seq 为消息的写入顺序（全局递增），同一毫秒内写入的兄弟消息也能稳定排序
+CREATE SEQUENCE IF NOT EXISTS messages_seq;
+ALTER TABLE messages ADD COLUMN seq BIGINT NOT NULL DEFAULT 0;
+
+UPDATE messages SET seq = o.n
+FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) AS n FROM messages) o
+WHERE messages.id = o.id;
+
+SELECT setval('messages_seq', COALESCE((SELECT MAX(seq) FROM messages), 0) + 1, false);
+ALTER TABLE messages ALTER COLUMN seq SET DEFAULT nextval('messages_seq');
+ALTER SEQUENCE messages_seq OWNED BY messages.seq;
+
+CREATE INDEX IF NOT EXISTS idx_messages_seq ON messages (conversation_id, seq);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS messages_seq;
+DROP INDEX IF EXISTS idx_messages_seq;
+ALTER TABLE messages DROP COLUMN seq;
@@ -0,0 +1,16 @@
+-- seq 为消息在会话内的写入顺序，同一毫秒内写入的兄弟消息也能稳定排序
+ALTER TABLE messages ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+
+UPDATE messages SET seq = o.n
+FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY created_at, id) AS n FROM messages) o
+WHERE messages.id = o.id;
+
+CREATE INDEX IF NOT EXISTS idx_messages_seq ON messages (conversation_id, seq);
+
+-- SQLite 的写入是串行的，取会话内当前最大值加一即可保证递增
+CREATE TRIGGER IF NOT EXISTS messages_seq AFTER INSERT ON messages WHEN NEW.seq = 0
+BEGIN
+    UPDATE messages SET seq = (
+        SELECT COALESCE(MAX(seq), 0) + 1 FROM messages WHERE conversation_id = NEW.conversation_id
+    ) WHERE rowid = NEW.rowid;
+END;
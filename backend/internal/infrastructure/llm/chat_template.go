@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChatTemplate 将多轮对话渲染成纯文本 prompt 的聊天模板，供只提供 /completion 接口的推理服务使用
+type ChatTemplate struct {
+	Name string
+	// BOS 整段 prompt 的起始标记
+	BOS string
+	// 各角色消息的前后缀
+	SystemPrefix    string
+	SystemSuffix    string
+	UserPrefix      string
+	UserSuffix      string
+	AssistantPrefix string
+	AssistantSuffix string
+	ToolPrefix      string
+	ToolSuffix      string
+	// StopWords 模板自身的结束标记，生成时需作为停止词
+	StopWords []string
+}
+
+var chatTemplates = map[string]*ChatTemplate{
+	"chatml": {
+		Name:            "chatml",
+		SystemPrefix:    "<|im_start|>system\n",
+		SystemSuffix:    "<|im_end|>\n",
+		UserPrefix:      "<|im_start|>user\n",
+		UserSuffix:      "<|im_end|>\n",
+		AssistantPrefix: "<|im_start|>assistant\n",
+		AssistantSuffix: "<|im_end|>\n",
+		ToolPrefix:      "<|im_start|>tool\n",
+		ToolSuffix:      "<|im_end|>\n",
+		StopWords:       []string{"<|im_end|>", "<|im_start|>"},
+	},
+	"qwen": {
+		Name:            "qwen",
+		SystemPrefix:    "<|im_start|>system\n",
+		SystemSuffix:    "<|im_end|>\n",
+		UserPrefix:      "<|im_start|>user\n",
+		UserSuffix:      "<|im_end|>\n",
+		AssistantPrefix: "<|im_start|>assistant\n",
+		AssistantSuffix: "<|im_end|>\n",
+		ToolPrefix:      "<|im_start|>user\n<tool_response>\n",
+		ToolSuffix:      "\n</tool_response><|im_end|>\n",
+		StopWords:       []string{"<|im_end|>", "<|endoftext|>"},
+	},
+	"llama3": {
+		Name:            "llama3",
+		BOS:             "<|begin_of_text|>",
+		SystemPrefix:    "<|start_header_id|>system<|end_header_id|>\n\n",
+		SystemSuffix:    "<|eot_id|>",
+		UserPrefix:      "<|start_header_id|>user<|end_header_id|>\n\n",
+		UserSuffix:      "<|eot_id|>",
+		AssistantPrefix: "<|start_header_id|>assistant<|end_header_id|>\n\n",
+		AssistantSuffix: "<|eot_id|>",
+		ToolPrefix:      "<|start_header_id|>ipython<|end_header_id|>\n\n",
+		ToolSuffix:      "<|eot_id|>",
+		StopWords:       []string{"<|eot_id|>", "<|end_of_text|>"},
+	},
+	"alpaca": {
+		Name:            "alpaca",
+		SystemPrefix:    "",
+		SystemSuffix:    "\n\n",
+		UserPrefix:      "### Instruction:\n",
+		UserSuffix:      "\n\n",
+		AssistantPrefix: "### Response:\n",
+		AssistantSuffix: "\n\n",
+		ToolPrefix:      "### Input:\n",
+		ToolSuffix:      "\n\n",
+		StopWords:       []string{"### Instruction:", "### Input:"},
+	},
+}
+
+// templateAliases 模板名称的常见别名
+var templateAliases = map[string]string{
+	"llama-3":   "llama3",
+	"llama3.1":  "llama3",
+	"llama-3.1": "llama3",
+	"qwen2":     "qwen",
+	"qwen2.5":   "qwen",
+	"chat_ml":   "chatml",
+}
+
+// GetChatTemplate 按名称查找聊天模板，名称为空时使用 chatml
+func GetChatTemplate(name string) (*ChatTemplate, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		key = "chatml"
+	}
+	if alias, ok := templateAliases[key]; ok {
+		key = alias
+	}
+	tpl, ok := chatTemplates[key]
+	if !ok {
+		return nil, fmt.Errorf("unsupported chat template: %s", name)
+	}
+	return tpl, nil
+}
+
+// Render 渲染完整对话，并在末尾追加 assistant 前缀引导模型续写
+func (t *ChatTemplate) Render(messages []Message) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(t.BOS)
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			sb.WriteString(t.SystemPrefix + msg.Content + t.SystemSuffix)
+		case RoleUser:
+			sb.WriteString(t.UserPrefix + msg.Content + t.UserSuffix)
+		case RoleAssistant:
+			sb.WriteString(t.AssistantPrefix + msg.Content + t.AssistantSuffix)
+		case RoleTool:
+			sb.WriteString(t.ToolPrefix + msg.Content + t.ToolSuffix)
+		default:
+			return "", fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+	}
+	sb.WriteString(t.AssistantPrefix)
+	return sb.String(), nil
+}
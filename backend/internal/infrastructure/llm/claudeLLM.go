@@ -2,38 +2,224 @@ package llm
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
 	"github.com/ai-companion/backend/internal/pkg/config"
 	"github.com/ai-companion/backend/internal/pkg/logger"
+	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/anthropic"
 )
 
 type ClaudeLLM struct {
-	llm *anthropic.LLM
+	llm      *anthropic.LLM
+	model    string
+	provider string
+	defaults GenerateOptions
 }
 
 func NewClaudeLLM(cfg *config.LLMConfig) *ClaudeLLM {
-	llm, err := anthropic.New(
+	opts := []anthropic.Option{
 		anthropic.WithModel(cfg.Model),
 		anthropic.WithToken(cfg.Token),
-	)
+		anthropic.WithHTTPClient(newHTTPClient()),
+	}
+	if cfg.BaseUrl != "" {
+		opts = append(opts, anthropic.WithBaseURL(cfg.BaseUrl))
+	}
+	llm, err := anthropic.New(opts...)
 	if err != nil {
 		logger.Errorf("new anthropic claude llm error:%s", err.Error())
 		return nil
 	}
 	return &ClaudeLLM{
-		llm: llm,
+		llm:      llm,
+		model:    cfg.Model,
+		provider: cfg.Provider,
+		defaults: defaultOptions(cfg),
 	}
 }
 
 func (o *ClaudeLLM) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	return nil, nil
+	messages, err := toClaudeMessageContents(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := o.options(req)
+	if err != nil {
+		return nil, err
+	}
+	res, err := o.llm.GenerateContent(ctx, messages, opts.callOptions()...)
+	if err != nil {
+		logger.Errorf("claude generate chat error : %s", err.Error())
+		return nil, err
+	}
+	if len(res.Choices) == 0 {
+		return nil, errors.New("empty response from claude")
+	}
+
+	text, finishReason, usage := claudeResult(res)
+	return &ChatResponse{
+		ID:      newResponseID(),
+		Object:  chatCompletionObject,
+		Created: time.Now(),
+		Model:   o.model,
+		Choices: []Choice{{
+			Message:      text,
+			FinishReason: finishReason,
+		}},
+		Usage: usage,
+	}, nil
 }
 
 func (o *ClaudeLLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-chan *StreamChunk, error) {
-	return nil, nil
+	messages, err := toClaudeMessageContents(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := o.options(req)
+	if err != nil {
+		return nil, err
+	}
+	resChan := make(chan *StreamChunk, 10)
+	go func() {
+		defer close(resChan)
+
+		id := newResponseID()
+		res, err := o.llm.GenerateContent(
+			ctx,
+			messages,
+			append(opts.callOptions(), llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
+				if len(chunk) == 0 {
+					return nil
+				}
+				select {
+				case resChan <- &StreamChunk{
+					ID:      id,
+					Message: string(chunk),
+				}:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				return nil
+			}))...,
+		)
+		if err != nil {
+			logger.Errorf("claude generate stream error : %s", err.Error())
+			select {
+			case resChan <- &StreamChunk{
+				ID:    id,
+				Error: err,
+				Done:  true,
+			}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		// 流结束后补发一条携带停止原因与用量的结束块
+		_, finishReason, usage := claudeResult(res)
+		select {
+		case resChan <- &StreamChunk{
+			ID:           id,
+			Done:         true,
+			FinishReason: finishReason,
+			Usage:        &usage,
+		}:
+		case <-ctx.Done():
+		}
+	}()
+	return resChan, nil
 }
 
 func (o *ClaudeLLM) ValidateConfig() error {
-	return nil
+	if o.llm == nil {
+		return errors.New("claude llm is not initialized")
+	}
+	return o.defaults.validate(o.provider, claudeParams)
+}
+
+// claudeResult 合并 Anthropic 返回的多个内容块，提取文本、停止原因和用量
+func claudeResult(res *llms.ContentResponse) (string, string, Usage) {
+	if res == nil {
+		return "", "", Usage{}
+	}
+	var text strings.Builder
+	var finishReason string
+	var usage Usage
+	for _, choice := range res.Choices {
+		if choice == nil {
+			continue
+		}
+		text.WriteString(choice.Content)
+		if finishReason == "" {
+			finishReason = claudeFinishReason(choice.StopReason)
+		}
+		if usage.TotalTokens == 0 {
+			usage = usageFromGenerationInfo(choice.GenerationInfo)
+		}
+	}
+	return text.String(), finishReason, usage
+}
+
+// claudeFinishReason 把 Anthropic 的 stop_reason 转换为与其他后端一致的停止原因
+func claudeFinishReason(stopReason string) string {
+	switch stopReason {
+	case "", "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return FinishReasonToolCalls
+	default:
+		return stopReason
+	}
+}
+
+// toClaudeMessageContents Anthropic 的 system 提示词通过独立字段传递，且要求 user/assistant 交替出现、以 user 开头：
+// 所有 system 消息合并为一条放在最前，相邻的同角色文本消息合并为一条，开头的 assistant 消息（如开场白）并入 system
+func toClaudeMessageContents(messages []Message) ([]llms.MessageContent, error) {
+	var systemParts []string
+	merged := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			if msg.Content != "" {
+				systemParts = append(systemParts, msg.Content)
+			}
+			continue
+		}
+		if len(merged) == 0 && msg.Role == RoleAssistant {
+			systemParts = append(systemParts, "[assistant]: "+msg.Content)
+			continue
+		}
+		last := len(merged) - 1
+		if last >= 0 && msg.Role != RoleTool && merged[last].Role == msg.Role {
+			merged[last].Content += "\n\n" + msg.Content
+			continue
+		}
+		merged = append(merged, msg)
+	}
+	if len(systemParts) > 0 {
+		merged = append([]Message{{
+			Role:    RoleSystem,
+			Content: strings.Join(systemParts, "\n\n"),
+		}}, merged...)
+	}
+	return toMessageContents(merged)
+}
+
+// claudeParams ClaudeLLM 支持的生成参数，Anthropic 的 temperature 取值为 0 到 1
+var claudeParams = paramSpec{params: []string{ParamTemperature, ParamTopP, ParamMaxTokens, ParamStop}, maxTemperature: 1}
+
+// options 合并默认生成参数并校验
+func (o *ClaudeLLM) options(req *ChatRequest) (GenerateOptions, error) {
+	opts := req.Options.withDefaults(o.defaults)
+	if err := validateTools(o.provider, req.Tools, false); err != nil {
+		return opts, err
+	}
+	if err := validateResponseFormat(o.provider, req.ResponseFormat, false); err != nil {
+		return opts, err
+	}
+	return opts, opts.validate(o.provider, claudeParams)
 }
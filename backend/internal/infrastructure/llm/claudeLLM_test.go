@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// messagesStub 模拟 Anthropic Messages API，记录最近一次请求体
+type messagesStub struct {
+	calls      atomic.Int32
+	lastBody   map[string]any
+	stopReason string
+	events     []string
+}
+
+func (s *messagesStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.calls.Add(1)
+	if r.URL.Path != "/messages" {
+		http.NotFound(w, r)
+		return
+	}
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.lastBody = body
+	if stream, _ := body["stream"].(bool); stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range s.events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", e)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test",
+		"content":[{"type":"text","text":"你好，"},{"type":"text","text":"我在。"}],
+		"stop_reason":%q,"usage":{"input_tokens":12,"output_tokens":5}}`, s.stopReason)
+}
+
+func newTestClaude(t *testing.T, stub *messagesStub) *ClaudeLLM {
+	t.Helper()
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	llm := NewClaudeLLM(&config.LLMConfig{Provider: "claude", Model: "claude-test", Token: "test", BaseUrl: srv.URL})
+	if llm == nil {
+		t.Fatal("NewClaudeLLM returned nil")
+	}
+	return llm
+}
+
+func TestClaudeGenerateChat(t *testing.T) {
+	tests := []struct {
+		stopReason string
+		want       string
+	}{
+		{"end_turn", "stop"},
+		{"stop_sequence", "stop"},
+		{"max_tokens", "length"},
+		{"tool_use", FinishReasonToolCalls},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stopReason, func(t *testing.T) {
+			stub := &messagesStub{stopReason: tt.stopReason}
+			llm := newTestClaude(t, stub)
+			res, err := llm.GenerateChat(context.Background(), &ChatRequest{Messages: []Message{
+				{Role: RoleSystem, Content: "你是助手"},
+				{Role: RoleAssistant, Content: "欢迎"},
+				{Role: RoleUser, Content: "在吗"},
+				{Role: RoleUser, Content: "你好"},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.Content(); got != "你好，我在。" {
+				t.Errorf("content = %q", got)
+			}
+			if got := res.Choices[0].FinishReason; got != tt.want {
+				t.Errorf("finish reason = %q, want %q", got, tt.want)
+			}
+			if res.Usage != (Usage{PromptTokens: 12, CompletionTokens: 5, TotalTokens: 17}) {
+				t.Errorf("usage = %+v", res.Usage)
+			}
+
+			// system 提示词与开场白通过 system 字段传递，相邻的 user 消息合并
+			if got := fmt.Sprint(stub.lastBody["system"]); got != "你是助手\n\n[assistant]: 欢迎" {
+				t.Errorf("system = %q", got)
+			}
+			messages, _ := stub.lastBody["messages"].([]any)
+			if len(messages) != 1 {
+				t.Fatalf("messages = %v", stub.lastBody["messages"])
+			}
+			if msg := messages[0].(map[string]any); msg["role"] != "user" || !strings.Contains(fmt.Sprint(msg["content"]), "在吗\n\n你好") {
+				t.Errorf("message = %v", msg)
+			}
+		})
+	}
+}
+
+func TestClaudeGenerateStream(t *testing.T) {
+	stub := &messagesStub{events: []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","usage":{"input_tokens":9,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"ping"}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"你好"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"，世界"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":4}}`,
+		`{"type":"message_stop"}`,
+	}}
+	llm := newTestClaude(t, stub)
+	chunks, err := llm.GenerateStream(context.Background(), &ChatRequest{Messages: []Message{
+		{Role: RoleSystem, Content: "你是助手"},
+		{Role: RoleUser, Content: "你好"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	var last *StreamChunk
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			t.Fatal(chunk.Error)
+		}
+		text.WriteString(chunk.Message)
+		last = chunk
+	}
+	if text.String() != "你好，世界" {
+		t.Errorf("streamed text = %q", text.String())
+	}
+	if last == nil || !last.Done {
+		t.Fatalf("last chunk = %+v, want done", last)
+	}
+	if last.FinishReason != "length" {
+		t.Errorf("finish reason = %q, want length", last.FinishReason)
+	}
+	if last.Usage == nil || *last.Usage != (Usage{PromptTokens: 9, CompletionTokens: 4, TotalTokens: 13}) {
+		t.Errorf("usage = %+v", last.Usage)
+	}
+	if stub.lastBody["system"] != "你是助手" || stub.lastBody["stream"] != true {
+		t.Errorf("request = %v", stub.lastBody)
+	}
+}
+
+func TestClaudeTemperatureRange(t *testing.T) {
+	stub := &messagesStub{stopReason: "end_turn"}
+	llm := newTestClaude(t, stub)
+	temperature := 1.5
+	_, err := llm.GenerateChat(context.Background(), &ChatRequest{
+		Messages: []Message{{Role: RoleUser, Content: "你好"}},
+		Options:  GenerateOptions{Temperature: &temperature},
+	})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Param != ParamTemperature {
+		t.Fatalf("err = %v, want temperature ValidationError", err)
+	}
+	if n := stub.calls.Load(); n != 0 {
+		t.Errorf("upstream called %d times, want 0", n)
+	}
+
+	// 同样的取值在支持 0 到 2 的后端上合法
+	if err := (GenerateOptions{Temperature: &temperature}).validate("openai", openAIParams); err != nil {
+		t.Errorf("openai temperature 1.5: %v", err)
+	}
+}
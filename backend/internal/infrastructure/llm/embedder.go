@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+const (
+	hashEmbedderProvider = "hash_embedder"
+	defaultBatchSize     = 64
+)
+
+// dimensionProbe 探测向量维度时使用的文本
+const dimensionProbe = "dimension"
+
+// CreateEmbedder 根据配置创建向量化后端，未配置 provider 时返回 nil
+func CreateEmbedder(cfg *config.EmbeddingConfig) Embedder {
+	if cfg.Provider == "" {
+		return nil
+	}
+	logger.Info("initialize embedder")
+	if slices.Contains(openAIMap, cfg.Provider) {
+		if e := NewOpenAIEmbedder(cfg); e != nil {
+			return e
+		}
+		return nil
+	}
+	if cfg.Provider == "ollama_llm" {
+		if e := NewOllamaEmbedder(cfg); e != nil {
+			return e
+		}
+		return nil
+	}
+	// 不依赖模型的哈希向量，用于离线开发与测试
+	if cfg.Provider == hashEmbedderProvider {
+		return NewHashEmbedder(cfg)
+	}
+	logger.Errorf("unsupported embedding provider:%s", cfg.Provider)
+	return nil
+}
+
+// LangchainEmbedder 通过 langchaingo 的 EmbedderClient 调用向量模型，负责分批与维度探测
+type LangchainEmbedder struct {
+	embedder *embeddings.EmbedderImpl
+	provider string
+
+	mu        sync.Mutex
+	dimension int
+}
+
+func NewOpenAIEmbedder(cfg *config.EmbeddingConfig) *LangchainEmbedder {
+	opts := []openai.Option{
+		openai.WithToken(cfg.Token),
+		openai.WithEmbeddingModel(cfg.Model),
+		openai.WithHTTPClient(newHTTPClient()),
+	}
+	if cfg.BaseUrl != "" {
+		opts = append(opts, openai.WithBaseURL(cfg.BaseUrl))
+	}
+	client, err := openai.New(opts...)
+	if err != nil {
+		logger.Errorf("connection openAI embedding error : %s", err.Error())
+		return nil
+	}
+	return newLangchainEmbedder(client, cfg)
+}
+
+func NewOllamaEmbedder(cfg *config.EmbeddingConfig) *LangchainEmbedder {
+	opts := []ollama.Option{
+		ollama.WithModel(cfg.Model),
+		ollama.WithHTTPClient(newHTTPClient()),
+	}
+	if cfg.BaseUrl != "" {
+		opts = append(opts, ollama.WithServerURL(cfg.BaseUrl))
+	}
+	client, err := ollama.New(opts...)
+	if err != nil {
+		logger.Errorf("connection ollama embedding error : %s", err.Error())
+		return nil
+	}
+	return newLangchainEmbedder(client, cfg)
+}
+
+func newLangchainEmbedder(client embeddings.EmbedderClient, cfg *config.EmbeddingConfig) *LangchainEmbedder {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	embedder, err := embeddings.NewEmbedder(client,
+		embeddings.WithBatchSize(batchSize),
+		// 保留换行，langchaingo 默认会原地修改调用方传入的切片
+		embeddings.WithStripNewLines(false),
+	)
+	if err != nil {
+		logger.Errorf("create embedder error : %s", err.Error())
+		return nil
+	}
+	return &LangchainEmbedder{embedder: embedder, provider: cfg.Provider, dimension: cfg.Dimension}
+}
+
+func (e *LangchainEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	vectors, err := e.embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d texts", e.provider, len(vectors), len(texts))
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, v := range vectors {
+		if e.dimension == 0 {
+			e.dimension = len(v)
+		}
+		if len(v) != e.dimension {
+			return nil, fmt.Errorf("%s returned embedding of dimension %d, expected %d", e.provider, len(v), e.dimension)
+		}
+	}
+	return vectors, nil
+}
+
+func (e *LangchainEmbedder) Dimension(ctx context.Context) (int, error) {
+	e.mu.Lock()
+	dimension := e.dimension
+	e.mu.Unlock()
+	if dimension > 0 {
+		return dimension, nil
+	}
+	vectors, err := e.Embed(ctx, []string{dimensionProbe})
+	if err != nil {
+		return 0, fmt.Errorf("probe embedding dimension: %w", err)
+	}
+	return len(vectors[0]), nil
+}
@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	ctx := context.Background()
+	e := NewHashEmbedder(&config.EmbeddingConfig{Provider: hashEmbedderProvider})
+	if d, _ := e.Dimension(ctx); d != defaultHashDimension {
+		t.Errorf("default dimension = %d, want %d", d, defaultHashDimension)
+	}
+
+	texts := []string{"我喜欢猫", "我喜欢猫", "I like cats", "今天天气不错", ""}
+	first, err := e.Embed(ctx, texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := e.Embed(ctx, texts)
+	for i := range texts {
+		if len(first[i]) != defaultHashDimension {
+			t.Errorf("len(vector[%d]) = %d", i, len(first[i]))
+		}
+		if !slices.Equal(first[i], second[i]) {
+			t.Errorf("vector for %q is not deterministic", texts[i])
+		}
+	}
+	if !slices.Equal(first[0], first[1]) {
+		t.Error("equal texts in one batch got different vectors")
+	}
+	if norm := cosine(first[0], first[0]); math.Abs(norm-1) > 1e-6 {
+		t.Errorf("vector is not normalized: |v|² = %f", norm)
+	}
+	if cosine(first[0], first[3]) >= cosine(first[0], first[1]) {
+		t.Error("different texts are as similar as equal texts")
+	}
+	if cosine(first[4], first[4]) != 0 {
+		t.Error("empty text should embed to a zero vector")
+	}
+
+	e = NewHashEmbedder(&config.EmbeddingConfig{Provider: hashEmbedderProvider, Dimension: 32})
+	vectors, _ := e.Embed(ctx, []string{"你好"})
+	if d, _ := e.Dimension(ctx); d != 32 || len(vectors[0]) != 32 {
+		t.Errorf("dimension = %d, len = %d, want 32", d, len(vectors[0]))
+	}
+}
+
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+// embeddingsStub 模拟 OpenAI 兼容的 /embeddings 接口，记录每次请求的文本数。
+// 返回的向量第一维为文本内容解析出的数字，便于检查结果顺序
+type embeddingsStub struct {
+	dimension int
+
+	mu      sync.Mutex
+	batches []int
+}
+
+func (s *embeddingsStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Input []string `json:"input"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	s.batches = append(s.batches, len(req.Input))
+	s.mu.Unlock()
+	type item struct {
+		Embedding []float32 `json:"embedding"`
+		Index     int       `json:"index"`
+	}
+	data := make([]item, 0, len(req.Input))
+	for i, text := range req.Input {
+		v := make([]float32, s.dimension)
+		n, _ := strconv.Atoi(text)
+		v[0] = float32(n)
+		data = append(data, item{Embedding: v, Index: i})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": data})
+}
+
+func newTestEmbedder(t *testing.T, stub *embeddingsStub, batchSize, dimension int) *LangchainEmbedder {
+	t.Helper()
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	e := NewOpenAIEmbedder(&config.EmbeddingConfig{
+		Provider:  "openai",
+		Model:     "embed-test",
+		BaseUrl:   srv.URL,
+		Token:     "test",
+		BatchSize: batchSize,
+		Dimension: dimension,
+	})
+	if e == nil {
+		t.Fatal("NewOpenAIEmbedder returned nil")
+	}
+	return e
+}
+
+func TestEmbedderBatching(t *testing.T) {
+	tests := []struct {
+		texts, batchSize int
+		want             []int
+	}{
+		{0, 3, nil},
+		{1, 3, []int{1}},
+		{3, 3, []int{3}},
+		{4, 3, []int{3, 1}},
+		{7, 3, []int{3, 3, 1}},
+		{defaultBatchSize + 1, 0, []int{defaultBatchSize, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.texts)+"/"+strconv.Itoa(tt.batchSize), func(t *testing.T) {
+			stub := &embeddingsStub{dimension: 4}
+			e := newTestEmbedder(t, stub, tt.batchSize, 0)
+			texts := make([]string, tt.texts)
+			for i := range texts {
+				texts[i] = strconv.Itoa(i)
+			}
+			vectors, err := e.Embed(context.Background(), texts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(vectors) != tt.texts {
+				t.Fatalf("got %d vectors, want %d", len(vectors), tt.texts)
+			}
+			for i, v := range vectors {
+				if int(v[0]) != i {
+					t.Fatalf("vector %d belongs to text %d", i, int(v[0]))
+				}
+			}
+			if len(stub.batches) != len(tt.want) {
+				t.Fatalf("batches = %v, want %v", stub.batches, tt.want)
+			}
+			for i := range tt.want {
+				if stub.batches[i] != tt.want[i] {
+					t.Errorf("batches = %v, want %v", stub.batches, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbedderDimension(t *testing.T) {
+	ctx := context.Background()
+
+	// 配置了维度时不请求后端
+	stub := &embeddingsStub{dimension: 8}
+	e := newTestEmbedder(t, stub, 0, 8)
+	if d, err := e.Dimension(ctx); err != nil || d != 8 {
+		t.Errorf("dimension = %d, %v", d, err)
+	}
+	if len(stub.batches) != 0 {
+		t.Errorf("configured dimension probed the backend %d times", len(stub.batches))
+	}
+
+	// 未配置时探测一次并缓存
+	stub = &embeddingsStub{dimension: 6}
+	e = newTestEmbedder(t, stub, 0, 0)
+	for range 2 {
+		if d, err := e.Dimension(ctx); err != nil || d != 6 {
+			t.Errorf("dimension = %d, %v", d, err)
+		}
+	}
+	if len(stub.batches) != 1 {
+		t.Errorf("probed %d times, want 1", len(stub.batches))
+	}
+
+	// 后端返回的维度与配置不一致时报错
+	stub = &embeddingsStub{dimension: 6}
+	e = newTestEmbedder(t, stub, 0, 8)
+	if _, err := e.Embed(ctx, []string{"1"}); err == nil {
+		t.Error("dimension mismatch: want error")
+	}
+}
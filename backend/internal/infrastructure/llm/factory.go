@@ -13,20 +13,61 @@ var openAIMap = []string{"openai_compatible_llm",
 
 func CreateLLM(cfg *config.LLMConfig) Handle {
 	logger.Info("initialize llm")
+	h := newHandle(cfg)
+	if h == nil {
+		return nil
+	}
+	// 分离推理模型输出中的思考过程；failover 的各个后端已分别处理
+	if cfg.Provider != failoverProvider {
+		h = NewReasoningLLM(h, cfg)
+	}
+	// 配置了重试或熔断时在外层包装 RetryLLM
+	if cfg.Retry.MaxAttempts > 1 || cfg.Retry.BreakerThreshold > 0 {
+		return NewRetryLLM(h, cfg)
+	}
+	return h
+}
+
+func newHandle(cfg *config.LLMConfig) Handle {
 	if slices.Contains(openAIMap, cfg.Provider) {
-		return NewOpenAILLM(cfg)
+		if h := NewOpenAILLM(cfg); h != nil {
+			return h
+		}
+		return nil
 	}
 	// 通过Http发送请求
 	if cfg.Provider == "stateless_llm_with_template" {
+		if h := NewStatelessLLM(cfg); h != nil {
+			return h
+		}
 		return nil
 	}
 	if cfg.Provider == "ollama_llm" {
-		return NewOllamaLLM(cfg)
+		if h := NewOllamaLLM(cfg); h != nil {
+			return h
+		}
+		return nil
 	}
 	if cfg.Provider == "claude_llm" {
-		return NewClaudeLLM(cfg)
+		if h := NewClaudeLLM(cfg); h != nil {
+			return h
+		}
+		return nil
+	}
+	// 按顺序尝试多个后端
+	if cfg.Provider == failoverProvider {
+		if h := NewFailoverLLM(cfg); h != nil {
+			return h
+		}
+		return nil
+	}
+	// 离线开发与测试用的模拟模型
+	if cfg.Provider == "mock_llm" {
+		if h := NewMockLLM(cfg); h != nil {
+			return h
+		}
+		return nil
 	}
 	logger.Errorf("unsupported llm provider:%s", cfg.Provider)
 	return nil
-
 }
@@ -0,0 +1,277 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+const (
+	defaultFailoverCooldown = 30 * time.Second
+	defaultFailureThreshold = 1
+	failoverProvider        = "failover_llm"
+)
+
+var (
+	errEmptyResponse   = errors.New("empty response")
+	errFirstChunkTimed = errors.New("timed out waiting for the first chunk")
+)
+
+// failoverBackend 故障转移链中的一个后端及其健康状态
+type failoverBackend struct {
+	name          string
+	handle        Handle
+	failures      int
+	cooldownUntil time.Time
+}
+
+// FailoverLLM 按配置顺序依次尝试多个后端，某个后端报错、超时或返回空结果时转到下一个。
+// 失败的后端会进入冷却期，冷却期内排在健康后端之后，全部后端都在冷却时仍会按顺序尝试
+type FailoverLLM struct {
+	backends  []*failoverBackend
+	timeout   time.Duration
+	cooldown  time.Duration
+	threshold int
+
+	mu sync.Mutex
+}
+
+func NewFailoverLLM(cfg *config.LLMConfig) *FailoverLLM {
+	f := &FailoverLLM{
+		timeout:   cfg.Failover.Timeout,
+		cooldown:  cfg.Failover.Cooldown,
+		threshold: cfg.Failover.FailureThreshold,
+	}
+	if f.cooldown <= 0 {
+		f.cooldown = defaultFailoverCooldown
+	}
+	if f.threshold <= 0 {
+		f.threshold = defaultFailureThreshold
+	}
+	for i := range cfg.Chain {
+		item := &cfg.Chain[i]
+		if item.Provider == failoverProvider {
+			logger.Errorf("failover llm chain[%d]: nested %s is not supported", i, failoverProvider)
+			continue
+		}
+		h := CreateLLM(item)
+		if h == nil {
+			logger.Errorf("failover llm chain[%d]: create %s failed, skipped", i, item.Provider)
+			continue
+		}
+		f.backends = append(f.backends, &failoverBackend{
+			name:   fmt.Sprintf("%s(%s)", item.Provider, item.Model),
+			handle: h,
+		})
+	}
+	if len(f.backends) == 0 {
+		logger.Errorf("failover llm requires at least one available backend in chain")
+		return nil
+	}
+	return f
+}
+
+func (f *FailoverLLM) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	var errs []error
+	for i, b := range f.candidates() {
+		attemptCtx, cancel := f.attemptContext(ctx)
+		res, err := b.handle.GenerateChat(attemptCtx, requestFor(b.handle, req))
+		cancel()
+		if err == nil && res.Content() == "" && len(res.ToolCalls()) == 0 {
+			err = errEmptyResponse
+		}
+		if err == nil {
+			f.markSuccess(b, i+1)
+			return res, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		f.markFailure(b, err)
+		errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+	}
+	return nil, exhaustedError(errs)
+}
+
+// GenerateStream 在拿到首个内容块之前可以切换后端，首块发出后的错误直接透传给调用方
+func (f *FailoverLLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-chan *StreamChunk, error) {
+	var errs []error
+	for i, b := range f.candidates() {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		stream, err := b.handle.GenerateStream(attemptCtx, requestFor(b.handle, req))
+		var buffered []*StreamChunk
+		if err == nil {
+			buffered, err = f.firstChunk(attemptCtx, stream)
+			if err != nil {
+				go drain(stream)
+			}
+		}
+		if err == nil {
+			f.markSuccess(b, i+1)
+			return f.forward(ctx, cancel, b, buffered, stream), nil
+		}
+		cancel()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		f.markFailure(b, err)
+		errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+	}
+	return nil, exhaustedError(errs)
+}
+
+func (f *FailoverLLM) ValidateConfig() error {
+	var errs []error
+	for _, b := range f.backends {
+		if err := b.handle.ValidateConfig(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SupportsTools 链中任一后端支持工具调用即可，不支持的后端会去掉工具定义后再请求
+func (f *FailoverLLM) SupportsTools() bool {
+	for _, b := range f.backends {
+		if SupportsTools(b.handle) {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsResponseFormat 链中任一后端支持结构化输出即可，不支持的后端会去掉输出格式后再请求，
+// 由 GenerateStructured 的提示词与校验保证输出格式
+func (f *FailoverLLM) SupportsResponseFormat() bool {
+	for _, b := range f.backends {
+		if SupportsResponseFormat(b.handle) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestFor 为不支持工具调用或结构化输出的后端去掉对应的字段
+func requestFor(h Handle, req *ChatRequest) *ChatRequest {
+	stripTools := len(req.Tools) > 0 && !SupportsTools(h)
+	stripFormat := req.ResponseFormat != nil && !SupportsResponseFormat(h)
+	if !stripTools && !stripFormat {
+		return req
+	}
+	stripped := *req
+	if stripTools {
+		stripped.Tools = nil
+	}
+	if stripFormat {
+		stripped.ResponseFormat = nil
+	}
+	return &stripped
+}
+
+// candidates 返回本次请求的尝试顺序：健康的后端在前，冷却中的后端在后，各自保持配置顺序
+func (f *FailoverLLM) candidates() []*failoverBackend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	healthy := make([]*failoverBackend, 0, len(f.backends))
+	var cooling []*failoverBackend
+	for _, b := range f.backends {
+		if now.Before(b.cooldownUntil) {
+			cooling = append(cooling, b)
+			continue
+		}
+		healthy = append(healthy, b)
+	}
+	return append(healthy, cooling...)
+}
+
+func (f *FailoverLLM) markSuccess(b *failoverBackend, attempt int) {
+	f.mu.Lock()
+	b.failures = 0
+	b.cooldownUntil = time.Time{}
+	f.mu.Unlock()
+	logger.WithFields(map[string]interface{}{
+		"backend": b.name,
+		"attempt": attempt,
+	}).Info("llm request served")
+}
+
+// markFailure 记录失败，参数校验错误与后端健康无关，不计入失败次数
+func (f *FailoverLLM) markFailure(b *failoverBackend, err error) {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		logger.WithField("backend", b.name).Warn("llm backend rejected request: " + err.Error())
+		return
+	}
+	f.mu.Lock()
+	b.failures++
+	if b.failures >= f.threshold {
+		b.cooldownUntil = time.Now().Add(f.cooldown)
+	}
+	failures := b.failures
+	f.mu.Unlock()
+	logger.WithFields(map[string]interface{}{
+		"backend":  b.name,
+		"failures": failures,
+	}).Warn("llm backend failed: " + err.Error())
+}
+
+func (f *FailoverLLM) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.timeout > 0 {
+		return context.WithTimeout(ctx, f.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// firstChunk 等待首个带内容的分块，返回它及之前缓冲的无内容分块（角色、用量等）以便原样转发；
+// 出错、超时或流在输出内容前结束都视为失败
+func (f *FailoverLLM) firstChunk(ctx context.Context, stream <-chan *StreamChunk) ([]*StreamChunk, error) {
+	var timeout <-chan time.Time
+	if f.timeout > 0 {
+		timer := time.NewTimer(f.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var buffered []*StreamChunk
+	for {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				return nil, errEmptyResponse
+			}
+			if chunk.Error != nil {
+				return nil, chunk.Error
+			}
+			buffered = append(buffered, chunk)
+			if chunk.hasContent() || (chunk.Done && len(chunk.ToolCalls) > 0) {
+				return buffered, nil
+			}
+			if chunk.Done {
+				return nil, errEmptyResponse
+			}
+		case <-timeout:
+			return nil, errFirstChunkTimed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// forward 转发选中后端的剩余分块，首块之后的错误仍会计入该后端的健康状态
+func (f *FailoverLLM) forward(ctx context.Context, cancel context.CancelFunc, b *failoverBackend,
+	buffered []*StreamChunk, stream <-chan *StreamChunk) <-chan *StreamChunk {
+	return pipeStream(ctx, cancel, buffered, stream, func(chunk *StreamChunk) {
+		if chunk.Error != nil && ctx.Err() == nil {
+			f.markFailure(b, chunk.Error)
+		}
+	})
+}
+
+func exhaustedError(errs []error) error {
+	return fmt.Errorf("all llm backends failed: %w", errors.Join(errs...))
+}
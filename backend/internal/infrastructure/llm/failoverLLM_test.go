@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// scriptedHandle 按给定分块输出的流式后端
+type scriptedHandle struct {
+	chunks []*StreamChunk
+	err    error
+}
+
+func (h *scriptedHandle) GenerateChat(context.Context, *ChatRequest) (*ChatResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (h *scriptedHandle) GenerateStream(ctx context.Context, _ *ChatRequest) (<-chan *StreamChunk, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+	ch := make(chan *StreamChunk)
+	go func() {
+		defer close(ch)
+		for _, chunk := range h.chunks {
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (h *scriptedHandle) ValidateConfig() error { return nil }
+
+func newTestFailover(handles ...Handle) *FailoverLLM {
+	f := &FailoverLLM{timeout: time.Second, cooldown: time.Minute, threshold: 1}
+	for i, h := range handles {
+		f.backends = append(f.backends, &failoverBackend{name: string(rune('a' + i)), handle: h})
+	}
+	return f
+}
+
+func TestFailoverStreamReplaysLeadingChunks(t *testing.T) {
+	usage := &Usage{PromptTokens: 7}
+	leading := []*StreamChunk{{ID: "r1"}, {ID: "r1", Usage: usage}}
+	primary := &scriptedHandle{chunks: append(leading,
+		&StreamChunk{ID: "r1", Message: "你好"},
+		&StreamChunk{ID: "r1", Done: true, FinishReason: "stop"},
+	)}
+	f := newTestFailover(primary)
+	stream, err := f.GenerateStream(context.Background(), &ChatRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*StreamChunk
+	for chunk := range stream {
+		got = append(got, chunk)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d chunks, want 4: %+v", len(got), got)
+	}
+	if got[0] != leading[0] || got[1] != leading[1] || got[1].Usage != usage {
+		t.Errorf("leading chunks were not replayed: %+v", got[:2])
+	}
+	if got[2].Message != "你好" || !got[3].Done {
+		t.Errorf("chunks = %+v", got[2:])
+	}
+}
+
+func TestFailoverStreamSwitchesBeforeContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		primary *scriptedHandle
+	}{
+		{"open error", &scriptedHandle{err: errors.New("down")}},
+		{"error before content", &scriptedHandle{chunks: []*StreamChunk{{ID: "x"}, {Error: errors.New("boom"), Done: true}}}},
+		{"empty stream", &scriptedHandle{chunks: []*StreamChunk{{ID: "x"}, {Done: true}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secondary := &scriptedHandle{chunks: []*StreamChunk{{ID: "b"}, {ID: "b", Message: "备用"}, {ID: "b", Done: true}}}
+			f := newTestFailover(tt.primary, secondary)
+			stream, err := f.GenerateStream(context.Background(), &ChatRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids, text string
+			for chunk := range stream {
+				ids += chunk.ID
+				text += chunk.Message
+			}
+			// 失败后端缓冲的分块不会转发
+			if ids != "bbb" || text != "备用" {
+				t.Errorf("ids = %q, text = %q", ids, text)
+			}
+			if f.backends[0].failures != 1 {
+				t.Errorf("primary failures = %d, want 1", f.backends[0].failures)
+			}
+		})
+	}
+}
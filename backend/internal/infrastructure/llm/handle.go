@@ -16,8 +16,75 @@ type Handle interface {
 	ValidateConfig() error
 }
 
+// Embedder 文本向量化
+type Embedder interface {
+	//Embed 为每段文本生成一个向量，结果与输入一一对应，超过批大小时自动分批请求
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+
+	//Dimension 向量维度，未配置时向后端请求一次以探测
+	Dimension(ctx context.Context) (int, error)
+}
+
+// Role 消息角色
+type Role string
+
+const (
+	RoleSystem    Role = "system"
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+	RoleTool      Role = "tool"
+)
+
+// Message 对话中的一条消息
+type Message struct {
+	Role    Role
+	Content string
+	// ToolCalls 仅 assistant 角色使用，模型在该轮发起的工具调用
+	ToolCalls []ToolCall
+	// ToolCallID 与 Name 仅 tool 角色使用，对应被回复的工具调用ID与工具名
+	ToolCallID string
+	Name       string
+}
+
+// Tool 提供给模型的工具定义
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters 参数的 JSON Schema
+	Parameters map[string]any
+}
+
+// ToolCall 模型发起的一次工具调用
+type ToolCall struct {
+	ID   string
+	Name string
+	// Arguments JSON 格式的调用参数
+	Arguments string
+}
+
+// ToolCallDelta 流式响应中工具调用的增量，Index 相同的增量属于同一次调用，Arguments 按顺序拼接
+type ToolCallDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+}
+
 type ChatRequest struct {
-	Message string
+	// Messages 按时间顺序排列的完整对话，包含 system 提示词与历史轮次
+	Messages []Message
+	// Options 生成参数，未设置的字段使用 LLMConfig 中的默认值
+	Options GenerateOptions
+	// Tools 本次请求允许模型调用的工具，仅实现了 ToolSupporter 的 Handle 支持
+	Tools []Tool
+	// ResponseFormat 要求模型按格式输出，仅实现了 ResponseFormatSupporter 的 Handle 支持，
+	// 其他后端请通过 GenerateStructured 调用
+	ResponseFormat *ResponseFormat
+}
+
+// ToolSupporter 由支持工具调用的 Handle 实现
+type ToolSupporter interface {
+	SupportsTools() bool
 }
 
 type ChatResponse struct {
@@ -33,6 +100,10 @@ type Choice struct {
 	Message      string
 	FinishReason string
 	Delta        string
+	// ToolCalls 模型要求调用的工具，此时 FinishReason 为 tool_calls
+	ToolCalls []ToolCall
+	// Reasoning 推理模型在回答前的思考过程，不包含在 Message 中
+	Reasoning string
 }
 
 type Usage struct {
@@ -42,8 +113,16 @@ type Usage struct {
 }
 
 type StreamChunk struct {
-	ID      string
-	Message string
-	Done    bool
-	Error   error
+	ID string
+	// Message 回答内容，Reasoning 思考过程；一个分块只携带其中一种
+	Message   string
+	Reasoning string
+	Done      bool
+	Error     error
+	// ToolCallDeltas 工具调用的增量
+	ToolCallDeltas []ToolCallDelta
+	// FinishReason、Usage 与完整的 ToolCalls 仅在结束块（Done 为 true）上携带
+	FinishReason string
+	Usage        *Usage
+	ToolCalls    []ToolCall
 }
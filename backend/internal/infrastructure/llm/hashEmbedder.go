@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+const defaultHashDimension = 256
+
+// HashEmbedder 基于特征哈希的确定性向量：英文与数字按词、中日韩文字按单字和相邻两字切分，
+// 每个特征哈希到一个带符号的维度上，最后做 L2 归一化。
+// 相同文本总得到相同向量，字面相近的文本余弦相似度更高，适合离线开发与测试，不具备语义理解能力
+type HashEmbedder struct {
+	dimension int
+}
+
+func NewHashEmbedder(cfg *config.EmbeddingConfig) *HashEmbedder {
+	dimension := cfg.Dimension
+	if dimension <= 0 {
+		dimension = defaultHashDimension
+	}
+	return &HashEmbedder{dimension: dimension}
+}
+
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) Dimension(context.Context) (int, error) {
+	return e.dimension, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	vector := make([]float32, e.dimension)
+	for _, feature := range hashFeatures(text) {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(feature))
+		sum := h.Sum64()
+		// 最高位决定符号，减少不同特征落在同一维度时的相互累加
+		sign := float32(1)
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		vector[sum%uint64(e.dimension)] += sign
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector
+}
+
+// hashFeatures 切分文本特征，忽略大小写、空白与标点
+func hashFeatures(text string) []string {
+	var features []string
+	var word strings.Builder
+	var prev rune
+	flush := func() {
+		if word.Len() > 0 {
+			features = append(features, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flush()
+			features = append(features, string(r))
+			if prev != 0 {
+				features = append(features, string([]rune{prev, r}))
+			}
+			prev = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+		prev = 0
+	}
+	flush()
+	return features
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
@@ -0,0 +1,289 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// SchemaFor 根据 Go 类型生成 JSON Schema。字段名取自 json 标签，没有 omitempty 的字段为必填；
+// 可用 description 标签添加说明，enum 标签以逗号分隔列出可选值
+func SchemaFor(v any) map[string]any {
+	return schemaForType(reflect.TypeOf(v), 0)
+}
+
+// maxSchemaDepth 防止自引用类型导致无限递归
+const maxSchemaDepth = 16
+
+func schemaForType(t reflect.Type, depth int) map[string]any {
+	if t == nil || depth > maxSchemaDepth {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaForType(t.Elem(), depth+1)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem(), depth+1)}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		required := make([]string, 0)
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitempty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			prop := schemaForType(field.Type, depth+1)
+			if desc := field.Tag.Get("description"); desc != "" {
+				prop["description"] = desc
+			}
+			if enum := field.Tag.Get("enum"); enum != "" {
+				prop["enum"] = strings.Split(enum, ",")
+			}
+			properties[name] = prop
+			if !omitempty {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]any{}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, slices.Contains(strings.Split(opts, ","), "omitempty")
+}
+
+// ValidateSchema 按 JSON Schema 校验 json.Unmarshal 得到的值。
+// 支持 type、enum、const、properties、required、additionalProperties、items、
+// minimum、maximum、minLength、maxLength、minItems、maxItems、anyOf，其余关键字忽略
+func ValidateSchema(schema map[string]any, value any) error {
+	return validateValue(schema, value, "$")
+}
+
+func validateValue(schema map[string]any, value any, path string) error {
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, jsonType(value))
+	}
+	if enum, ok := schema["enum"]; ok && !containsValue(enum, value) {
+		return fmt.Errorf("%s: must be one of %v", path, enum)
+	}
+	if c, ok := schema["const"]; ok && !reflect.DeepEqual(normalize(c), value) {
+		return fmt.Errorf("%s: must be %v", path, c)
+	}
+	if anyOf, ok := normalize(schema["anyOf"]).([]any); ok {
+		var errs []string
+		for _, s := range anyOf {
+			sub, _ := s.(map[string]any)
+			err := validateValue(sub, value, path)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err.Error())
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%s: does not match any allowed schema (%s)", path, strings.Join(errs, "; "))
+		}
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		return validateObject(schema, v, path)
+	case []any:
+		if n, ok := number(schema["minItems"]); ok && float64(len(v)) < n {
+			return fmt.Errorf("%s: must have at least %v items", path, n)
+		}
+		if n, ok := number(schema["maxItems"]); ok && float64(len(v)) > n {
+			return fmt.Errorf("%s: must have at most %v items", path, n)
+		}
+		if items, ok := schemaMap(schema["items"]); ok {
+			for i, item := range v {
+				if err := validateValue(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(v))
+		if n, ok := number(schema["minLength"]); ok && length < n {
+			return fmt.Errorf("%s: must be at least %v characters", path, n)
+		}
+		if n, ok := number(schema["maxLength"]); ok && length > n {
+			return fmt.Errorf("%s: must be at most %v characters", path, n)
+		}
+	case float64:
+		if n, ok := number(schema["minimum"]); ok && v < n {
+			return fmt.Errorf("%s: must be >= %v", path, n)
+		}
+		if n, ok := number(schema["maximum"]); ok && v > n {
+			return fmt.Errorf("%s: must be <= %v", path, n)
+		}
+	}
+	return nil
+}
+
+func validateObject(schema map[string]any, obj map[string]any, path string) error {
+	properties, _ := schemaMap(schema["properties"])
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required field %q", path, name)
+		}
+	}
+	// 按字段名排序，保证错误信息稳定
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fieldPath := path + "." + name
+		if prop, ok := schemaMap(properties[name]); ok {
+			if err := validateValue(prop, obj[name], fieldPath); err != nil {
+				return err
+			}
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				return fmt.Errorf("%s: unexpected field", fieldPath)
+			}
+		case map[string]any:
+			if err := validateValue(extra, obj[name], fieldPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(t any, value any) bool {
+	switch t := t.(type) {
+	case string:
+		return matchesTypeName(t, value)
+	case []any:
+		return slices.ContainsFunc(t, func(name any) bool { return matchesType(name, value) })
+	case []string:
+		return slices.ContainsFunc(t, func(name string) bool { return matchesTypeName(name, value) })
+	}
+	return true
+}
+
+func matchesTypeName(name string, value any) bool {
+	switch name {
+	case "integer":
+		f, ok := value.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonType(value) == name
+	}
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func containsValue(enum any, value any) bool {
+	list, ok := normalize(enum).([]any)
+	if !ok {
+		return true
+	}
+	return slices.ContainsFunc(list, func(item any) bool { return reflect.DeepEqual(item, value) })
+}
+
+// normalize 把 Go 代码中构造的 Schema 值（如 []string、int）转换为 json.Unmarshal 的表示，便于比较
+func normalize(v any) any {
+	switch v.(type) {
+	case nil, bool, float64, string, []any, map[string]any:
+		return v
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var res any
+	if err := json.Unmarshal(data, &res); err != nil {
+		return v
+	}
+	return res
+}
+
+func schemaMap(v any) (map[string]any, bool) {
+	m, ok := v.(map[string]any)
+	return m, ok
+}
+
+func stringList(v any) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
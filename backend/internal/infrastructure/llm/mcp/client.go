@@ -0,0 +1,204 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+const defaultTimeout = 30 * time.Second
+
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+)
+
+// transport 一条到 MCP 服务的连接
+type transport interface {
+	// call 发送请求并等待对应的响应结果
+	call(ctx context.Context, req *request) (json.RawMessage, error)
+	notify(ctx context.Context, req *request) error
+	close() error
+}
+
+// Client 一个 MCP 服务的客户端。连接在首次请求时建立，断开后下次请求自动重连
+type Client struct {
+	cfg       config.MCPServerConfig
+	transport string
+	timeout   time.Duration
+
+	mu   sync.Mutex
+	conn transport
+}
+
+func NewClient(cfg *config.MCPServerConfig) (*Client, error) {
+	c := &Client{cfg: *cfg, transport: cfg.Transport, timeout: cfg.Timeout}
+	if c.transport == "" {
+		c.transport = TransportHTTP
+		if cfg.Command != "" {
+			c.transport = TransportStdio
+		}
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultTimeout
+	}
+	switch c.transport {
+	case TransportStdio:
+		if cfg.Command == "" {
+			return nil, fmt.Errorf("mcp server %s: stdio transport requires command", cfg.Name)
+		}
+	case TransportHTTP:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("mcp server %s: http transport requires url", cfg.Name)
+		}
+	default:
+		return nil, fmt.Errorf("mcp server %s: unsupported transport %q", cfg.Name, c.transport)
+	}
+	return c, nil
+}
+
+// Name 服务名称
+func (c *Client) Name() string {
+	return c.cfg.Name
+}
+
+// ListTools 获取服务端提供的全部工具
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var tools []ToolInfo
+	params := listToolsParams{}
+	for {
+		var res listToolsResult
+		if err := c.request(ctx, "tools/list", params, &res); err != nil {
+			return nil, err
+		}
+		tools = append(tools, res.Tools...)
+		if res.NextCursor == "" {
+			return tools, nil
+		}
+		params.Cursor = res.NextCursor
+	}
+}
+
+// CallTool 调用工具并把结果内容拼接为文本，工具报告执行失败时返回错误
+func (c *Client) CallTool(ctx context.Context, name string, arguments json.RawMessage) (string, error) {
+	var res callToolResult
+	if err := c.request(ctx, "tools/call", callToolParams{Name: name, Arguments: arguments}, &res); err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(res.Content))
+	for _, item := range res.Content {
+		parts = append(parts, item.String())
+	}
+	text := strings.Join(parts, "\n")
+	if text == "" && len(res.StructuredContent) > 0 {
+		text = string(res.StructuredContent)
+	}
+	if res.IsError {
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// Close 断开连接，stdio 服务的进程会随之退出
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.close()
+	c.conn = nil
+	return err
+}
+
+func (c *Client) request(ctx context.Context, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return err
+	}
+	raw, err := conn.call(ctx, &request{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		if errors.Is(err, errClosed) || errors.Is(err, errSessionExpired) {
+			c.reset(conn)
+		}
+		return fmt.Errorf("mcp %s %s: %w", c.cfg.Name, method, err)
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("mcp %s %s: decode result: %w", c.cfg.Name, method, err)
+	}
+	return nil
+}
+
+// connection 返回当前连接，没有连接时建立连接并完成初始化握手
+func (c *Client) connection(ctx context.Context) (transport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		return c.conn, nil
+	}
+	var conn transport
+	switch c.transport {
+	case TransportStdio:
+		stdio, err := startStdio(&c.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("mcp %s: %w", c.cfg.Name, err)
+		}
+		conn = stdio
+	default:
+		conn = newHTTPTransport(&c.cfg)
+	}
+	if err := c.initialize(ctx, conn); err != nil {
+		_ = conn.close()
+		return nil, fmt.Errorf("mcp %s: initialize: %w", c.cfg.Name, err)
+	}
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *Client) initialize(ctx context.Context, conn transport) error {
+	raw, err := conn.call(ctx, &request{
+		JSONRPC: "2.0",
+		Method:  "initialize",
+		Params: initializeParams{
+			ProtocolVersion: protocolVersion,
+			Capabilities:    map[string]any{},
+			ClientInfo:      implementation{Name: clientName, Version: clientVersion},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	var res initializeResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return err
+	}
+	if h, ok := conn.(*httpTransport); ok {
+		h.setVersion(res.ProtocolVersion)
+	}
+	logger.WithFields(map[string]interface{}{
+		"server":   c.cfg.Name,
+		"name":     res.ServerInfo.Name,
+		"version":  res.ServerInfo.Version,
+		"protocol": res.ProtocolVersion,
+	}).Info("mcp server connected")
+	return conn.notify(ctx, &request{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// reset 丢弃已断开的连接，下次请求时重新连接
+func (c *Client) reset(conn transport) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == conn {
+		_ = conn.close()
+		c.conn = nil
+	}
+}
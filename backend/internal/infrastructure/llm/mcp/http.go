@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+const (
+	headerSessionID       = "Mcp-Session-Id"
+	headerProtocolVersion = "MCP-Protocol-Version"
+)
+
+// closeTimeout 结束会话的 DELETE 请求的超时
+const closeTimeout = 5 * time.Second
+
+// httpTransport Streamable HTTP 传输：每个消息单独 POST，
+// 服务端以 JSON 或 SSE 流返回响应，会话ID由初始化响应的 Mcp-Session-Id 头下发
+type httpTransport struct {
+	name    string
+	url     string
+	headers map[string]string
+	client  *http.Client
+	nextID  atomic.Int64
+
+	mu        sync.Mutex
+	sessionID string
+	version   string
+}
+
+func newHTTPTransport(cfg *config.MCPServerConfig) *httpTransport {
+	return &httpTransport{
+		name:    cfg.Name,
+		url:     cfg.URL,
+		headers: cfg.Headers,
+		client:  &http.Client{},
+	}
+}
+
+func (t *httpTransport) call(ctx context.Context, req *request) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	req.ID = &id
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var msg *message
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		msg, err = t.readEvents(ctx, resp.Body, id)
+	} else {
+		msg = &message{}
+		err = json.NewDecoder(resp.Body).Decode(msg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read mcp response: %w", err)
+	}
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	return msg.Result, nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, req *request) error {
+	resp, err := t.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// setVersion 初始化完成后记录协商的协议版本，之后的请求都需要携带
+func (t *httpTransport) setVersion(version string) {
+	t.mu.Lock()
+	t.version = version
+	t.mu.Unlock()
+}
+
+func (t *httpTransport) post(ctx context.Context, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(httpReq)
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if sessionID := resp.Header.Get(headerSessionID); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if resp.StatusCode == http.StatusNotFound && httpReq.Header.Get(headerSessionID) != "" {
+			return nil, errSessionExpired
+		}
+		return nil, fmt.Errorf("mcp server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request) {
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sessionID != "" {
+		req.Header.Set(headerSessionID, t.sessionID)
+	}
+	if t.version != "" {
+		req.Header.Set(headerProtocolVersion, t.version)
+	}
+}
+
+// readEvents 从 SSE 流中找到 id 对应的响应，流中服务端发来的请求会另行 POST 回复
+func (t *httpTransport) readEvents(ctx context.Context, body io.Reader, id int64) (*message, error) {
+	want := fmt.Sprint(id)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line != "" {
+			if v, ok := strings.CutPrefix(line, "data:"); ok {
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.WriteString(strings.TrimPrefix(v, " "))
+			}
+			continue
+		}
+		// 空行表示一个事件结束
+		if data.Len() == 0 {
+			continue
+		}
+		var msg message
+		err := json.Unmarshal([]byte(data.String()), &msg)
+		data.Reset()
+		if err != nil {
+			logger.WithField("server", t.name).Warn("mcp: invalid event: " + err.Error())
+			continue
+		}
+		switch {
+		case msg.isResponse():
+			if string(msg.ID) == want {
+				return &msg, nil
+			}
+		case msg.isRequest():
+			if resp, err := t.post(ctx, replyTo(&msg)); err == nil {
+				resp.Body.Close()
+			}
+		default:
+			logger.WithField("server", t.name).Debug("mcp notification: " + msg.Method)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// close 通知服务端结束会话，服务端不支持时忽略错误；最多等待 closeTimeout，避免服务端无响应时阻塞退出
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sessionID := t.sessionID
+	t.mu.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
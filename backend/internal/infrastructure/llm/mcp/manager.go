@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm/tool"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+const (
+	retryInitial = 5 * time.Second
+	retryMax     = 5 * time.Minute
+	// maxToolName 模型接口允许的工具名最大长度
+	maxToolName = 64
+)
+
+// invalidNameChars 模型接口要求工具名只包含字母、数字、下划线与连字符
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Manager 管理配置中的全部 MCP 服务，把发现的工具注册到工具表
+type Manager struct {
+	clients []*Client
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// Start 在后台连接各个服务并注册其工具，连接失败时按指数退避重试，不阻塞服务启动
+func Start(cfg *config.MCPConfig, registry *tool.Registry) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{cancel: cancel}
+	for i := range cfg.Servers {
+		server := &cfg.Servers[i]
+		if server.Disabled {
+			continue
+		}
+		client, err := NewClient(server)
+		if err != nil {
+			logger.Errorf("create mcp client error: %s", err.Error())
+			continue
+		}
+		m.clients = append(m.clients, client)
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.discover(ctx, client, server, registry)
+		}()
+	}
+	return m
+}
+
+// Close 停止重试并断开全部服务
+func (m *Manager) Close() error {
+	m.cancel()
+	m.wg.Wait()
+	var errs []error
+	for _, client := range m.clients {
+		if err := client.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("mcp %s: %w", client.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) discover(ctx context.Context, client *Client, cfg *config.MCPServerConfig, registry *tool.Registry) {
+	wait := retryInitial
+	for {
+		tools, err := client.ListTools(ctx)
+		if err == nil {
+			register(client, cfg, registry, tools)
+			return
+		}
+		logger.WithFields(map[string]interface{}{
+			"server": cfg.Name,
+			"retry":  wait.String(),
+		}).Warn("mcp tool discovery failed: " + err.Error())
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, retryMax)
+	}
+}
+
+// register 以 "<前缀><工具名>" 注册工具，调用时转发给 MCP 服务
+func register(client *Client, cfg *config.MCPServerConfig, registry *tool.Registry, tools []ToolInfo) {
+	prefix := cfg.ToolPrefix
+	if prefix == "" {
+		prefix = cfg.Name + "_"
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	var names []string
+	for _, info := range tools {
+		if len(cfg.Tools) > 0 && !slices.Contains(cfg.Tools, info.Name) {
+			continue
+		}
+		name := toolName(prefix, info.Name)
+		err := registry.Register(&tool.Tool{
+			Name:        name,
+			Description: info.Description,
+			Parameters:  info.InputSchema,
+			Timeout:     timeout,
+			Handler: func(ctx context.Context, arguments json.RawMessage) (string, error) {
+				return client.CallTool(ctx, info.Name, arguments)
+			},
+		})
+		if err != nil {
+			logger.WithField("server", cfg.Name).Warn("register mcp tool error: " + err.Error())
+			continue
+		}
+		names = append(names, name)
+	}
+	logger.WithFields(map[string]interface{}{
+		"server": cfg.Name,
+		"tools":  names,
+	}).Info("mcp tools registered")
+}
+
+// toolName 生成符合模型接口要求的工具名。替换字符或截断会让不同的工具得到相同的名称，
+// 此时在末尾附加原名称的哈希以区分
+func toolName(prefix, name string) string {
+	raw := prefix + name
+	res := invalidNameChars.ReplaceAllString(raw, "_")
+	if res == raw && len(res) <= maxToolName {
+		return res
+	}
+	h := fnv.New32a()
+	h.Write([]byte(raw))
+	suffix := fmt.Sprintf("_%08x", h.Sum32())
+	if len(res) > maxToolName-len(suffix) {
+		res = res[:maxToolName-len(suffix)]
+	}
+	return res + suffix
+}
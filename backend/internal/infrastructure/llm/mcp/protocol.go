@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// protocolVersion 客户端请求的 MCP 协议版本，服务端可以协商为其支持的版本
+const protocolVersion = "2025-03-26"
+
+const (
+	clientName    = "ai-companion"
+	clientVersion = "1.0.0"
+)
+
+// JSON-RPC 错误码
+const (
+	codeMethodNotFound = -32601
+)
+
+var (
+	// errClosed 连接已断开（进程退出、流被关闭），下次请求时会重新连接
+	errClosed = errors.New("mcp connection closed")
+	// errSessionExpired HTTP 服务端不再识别当前会话，需要重新初始化
+	errSessionExpired = errors.New("mcp session expired")
+)
+
+// request JSON-RPC 请求，ID 为 nil 时为通知
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// message 从服务端收到的消息，可能是响应、请求或通知
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+func (m *message) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+func (m *message) isRequest() bool {
+	return m.Method != "" && len(m.ID) > 0 && string(m.ID) != "null"
+}
+
+// response 回复服务端发来的请求
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// replyTo 客户端只需要响应 ping，其余请求（如 sampling）一律返回方法不存在
+func replyTo(msg *message) *response {
+	res := &response{JSONRPC: "2.0", ID: msg.ID}
+	if msg.Method == "ping" {
+		res.Result = struct{}{}
+	} else {
+		res.Error = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	return res
+}
+
+// RPCError 服务端返回的 JSON-RPC 错误
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+type implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type initializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ClientInfo      implementation `json:"clientInfo"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	ServerInfo      implementation `json:"serverInfo"`
+}
+
+// ToolInfo 服务端声明的工具
+type ToolInfo struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	InputSchema map[string]any `json:"inputSchema,omitempty"`
+}
+
+type listToolsParams struct {
+	Cursor string `json:"cursor,omitempty"`
+}
+
+type listToolsResult struct {
+	Tools      []ToolInfo `json:"tools"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+type callToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type callToolResult struct {
+	Content []content `json:"content"`
+	IsError bool      `json:"isError,omitempty"`
+	// StructuredContent 较新的协议版本中可能只返回结构化结果
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+}
+
+// content 工具结果中的一段内容
+type content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	Resource *struct {
+		URI  string `json:"uri"`
+		Text string `json:"text,omitempty"`
+	} `json:"resource,omitempty"`
+}
+
+// String 文本原样返回，模型无法直接使用的图片、音频等内容只保留说明
+func (c *content) String() string {
+	switch c.Type {
+	case "text":
+		return c.Text
+	case "resource":
+		if c.Resource == nil {
+			return "[resource]"
+		}
+		if c.Resource.Text != "" {
+			return c.Resource.Text
+		}
+		return "[resource " + c.Resource.URI + "]"
+	default:
+		if c.MimeType != "" {
+			return "[" + c.Type + " " + c.MimeType + "]"
+		}
+		return "[" + c.Type + "]"
+	}
+}
@@ -0,0 +1,212 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+const (
+	// maxMessageSize 单条 stdio 消息的最大长度
+	maxMessageSize = 16 * 1024 * 1024
+	// drainTimeout 关闭时等待进程退出、输出读完的最长时间
+	drainTimeout = 2 * time.Second
+)
+
+// stdioTransport 启动子进程，通过标准输入输出逐行收发 JSON-RPC 消息，标准错误输出写入日志
+type stdioTransport struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan *message
+	done    chan struct{}
+	err     error
+	// stderrDone 标准错误输出读取完毕，之后才能调用 cmd.Wait
+	stderrDone chan struct{}
+}
+
+func startStdio(cfg *config.MCPServerConfig) (*stdioTransport, error) {
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = append(os.Environ(), cfg.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", cfg.Command, err)
+	}
+	t := &stdioTransport{
+		name:    cfg.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+
+		stderrDone: make(chan struct{}),
+	}
+	go t.logStderr(stderr)
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) call(ctx context.Context, req *request) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	req.ID = &id
+	ch := make(chan *message, 1)
+	t.mu.Lock()
+	if t.err != nil {
+		t.mu.Unlock()
+		return nil, t.err
+	}
+	t.pending[id] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(req); err != nil {
+		return nil, err
+	}
+	select {
+	case msg := <-ch:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	case <-t.done:
+		return nil, t.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(_ context.Context, req *request) error {
+	return t.write(req)
+}
+
+func (t *stdioTransport) write(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", errClosed, err)
+	}
+	return nil
+}
+
+// readLoop 分发服务端消息，直到标准输出关闭（通常是进程退出）
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			logger.WithField("server", t.name).Warn("mcp: invalid message: " + err.Error())
+			continue
+		}
+		switch {
+		case msg.isResponse():
+			t.deliver(&msg)
+		case msg.isRequest():
+			if err := t.write(replyTo(&msg)); err != nil {
+				logger.WithField("server", t.name).Warn("mcp: reply error: " + err.Error())
+			}
+		default:
+			logger.WithField("server", t.name).Debug("mcp notification: " + msg.Method)
+		}
+	}
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	t.mu.Lock()
+	t.err = fmt.Errorf("%w: %s: %v", errClosed, t.name, err)
+	t.mu.Unlock()
+	close(t.done)
+	// Wait 会关闭管道，需等标准错误输出读完，否则可能丢失退出前的输出；
+	// 子进程派生的进程可能一直持有标准错误输出，因此最多等待 drainTimeout
+	select {
+	case <-t.stderrDone:
+	case <-time.After(drainTimeout):
+	}
+	if err := t.cmd.Wait(); err != nil {
+		logger.WithField("server", t.name).Warn("mcp server exited: " + err.Error())
+	}
+}
+
+// deliver 把响应交给等待中的调用方。调用方已经离开，或服务端对同一 id 重复响应时直接丢弃，
+// 保证读取循环不会阻塞
+func (t *stdioTransport) deliver(msg *message) {
+	id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	ch, ok := t.pending[id]
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case ch <- msg:
+	default:
+		logger.WithField("server", t.name).Warn("mcp: duplicate response for id " + string(msg.ID))
+	}
+}
+
+func (t *stdioTransport) logStderr(stderr io.Reader) {
+	defer close(t.stderrDone)
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		logger.WithField("server", t.name).Debug("mcp stderr: " + scanner.Text())
+	}
+}
+
+// close 关闭标准输入让服务端自行退出，超时未退出则强制结束进程
+func (t *stdioTransport) close() error {
+	_ = t.stdin.Close()
+	select {
+	case <-t.done:
+		return nil
+	case <-time.After(drainTimeout):
+	}
+	if err := t.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	select {
+	case <-t.done:
+		return nil
+	case <-time.After(drainTimeout):
+		return fmt.Errorf("mcp server %s: stdout not closed after kill", t.name)
+	}
+}
@@ -0,0 +1,211 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/infrastructure/llm/tool"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// fixtureEnv 设置后测试二进制作为 stdio MCP 服务运行
+const fixtureEnv = "MCP_STDIO_FIXTURE"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fixtureEnv) != "" {
+		serveFixture()
+		os.Exit(0)
+	}
+	// 提前初始化日志，避免多个 goroutine 同时创建默认 Logger
+	_ = logger.InitDefaultLogger(logger.DefaultConfig())
+	os.Exit(m.Run())
+}
+
+// serveFixture 最小的 stdio MCP 服务：提供 echo 与 fail 两个工具，调用工具前先向客户端发送一次 ping
+func serveFixture() {
+	in := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	fmt.Fprintln(os.Stderr, "fixture started")
+	for in.Scan() {
+		var msg message
+		if err := json.Unmarshal(in.Bytes(), &msg); err != nil || !msg.isRequest() {
+			continue
+		}
+		var result any
+		switch msg.Method {
+		case "initialize":
+			result = initializeResult{ProtocolVersion: protocolVersion, ServerInfo: implementation{Name: "fixture", Version: "0.1"}}
+		case "tools/list":
+			result = listToolsResult{Tools: []ToolInfo{
+				{Name: "echo", Description: "回显 text", InputSchema: map[string]any{"type": "object"}},
+				{Name: "fail"},
+			}}
+		case "tools/call":
+			_ = out.Encode(map[string]any{"jsonrpc": "2.0", "id": "ping-1", "method": "ping"})
+			if !in.Scan() || !strings.Contains(in.Text(), `"ping-1"`) {
+				return
+			}
+			var params struct {
+				Name      string `json:"name"`
+				Arguments struct {
+					Text string `json:"text"`
+				} `json:"arguments"`
+			}
+			_ = json.Unmarshal(msg.Params, &params)
+			if params.Name == "fail" {
+				result = map[string]any{"isError": true, "content": []any{map[string]any{"type": "text", "text": "failed on purpose"}}}
+			} else {
+				result = map[string]any{"content": []any{
+					map[string]any{"type": "text", "text": params.Arguments.Text},
+					map[string]any{"type": "image", "mimeType": "image/png", "data": ""},
+				}}
+			}
+		default:
+			_ = out.Encode(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "error": RPCError{Code: codeMethodNotFound, Message: msg.Method}})
+			continue
+		}
+		_ = out.Encode(map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+	}
+}
+
+func fixtureConfig(t *testing.T) *config.MCPServerConfig {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &config.MCPServerConfig{Name: "fixture", Command: exe, Env: []string{fixtureEnv + "=1"}, Timeout: 5 * time.Second}
+}
+
+func TestStdioClient(t *testing.T) {
+	client, err := NewClient(fixtureConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	ctx := context.Background()
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[0].Description != "回显 text" {
+		t.Fatalf("tools = %+v", tools)
+	}
+
+	text, err := client.CallTool(ctx, "echo", json.RawMessage(`{"text":"你好"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "你好\n[image image/png]" {
+		t.Errorf("echo = %q", text)
+	}
+	if _, err := client.CallTool(ctx, "fail", nil); err == nil || err.Error() != "failed on purpose" {
+		t.Errorf("fail err = %v", err)
+	}
+
+	// 进程退出后下次请求重新连接
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListTools(ctx); err != nil {
+		t.Errorf("reconnect: %v", err)
+	}
+}
+
+func TestStdioDeliverDoesNotBlock(t *testing.T) {
+	ch := make(chan *message, 1)
+	tr := &stdioTransport{name: "test", pending: map[int64]chan *message{1: ch}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// 重复的响应、已离开调用方的响应与无法解析的 id 都不阻塞读取
+		tr.deliver(&message{ID: json.RawMessage("1"), Result: json.RawMessage(`"first"`)})
+		tr.deliver(&message{ID: json.RawMessage("1"), Result: json.RawMessage(`"duplicate"`)})
+		tr.deliver(&message{ID: json.RawMessage("2"), Result: json.RawMessage(`"late"`)})
+		tr.deliver(&message{ID: json.RawMessage(`"x"`)})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deliver blocked")
+	}
+	if msg := <-ch; string(msg.Result) != `"first"` {
+		t.Errorf("result = %s, want the first response", msg.Result)
+	}
+}
+
+func TestStartRegistersTools(t *testing.T) {
+	cfg := fixtureConfig(t)
+	registry := tool.NewRegistry()
+	m := Start(&config.MCPConfig{Servers: []config.MCPServerConfig{*cfg}}, registry)
+	defer m.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	var names []string
+	for len(names) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("mcp tools were not registered: %v", names)
+		}
+		time.Sleep(10 * time.Millisecond)
+		names = names[:0]
+		for _, def := range registry.Definitions(nil, nil) {
+			names = append(names, def.Name)
+		}
+	}
+	if strings.Join(names, ",") != "fixture_echo,fixture_fail" {
+		t.Errorf("registered tools = %v", names)
+	}
+	res := registry.Call(context.Background(), llm.ToolCall{ID: "1", Name: "fixture_echo", Arguments: `{"text":"hi"}`})
+	if !strings.HasPrefix(res.Content, "hi") {
+		t.Errorf("echo = %q", res.Content)
+	}
+}
+
+func TestToolName(t *testing.T) {
+	long := strings.Repeat("a", 70)
+	tests := []struct {
+		prefix, name string
+		want         string
+	}{
+		{"srv_", "search", "srv_search"},
+		{"srv_", "get.weather", "srv_get_weather_" + fnvHex("srv_get.weather")},
+		{"srv_", long, "srv_" + long[:51] + "_" + fnvHex("srv_"+long)},
+	}
+	for _, tt := range tests {
+		if got := toolName(tt.prefix, tt.name); got != tt.want {
+			t.Errorf("toolName(%q, %q) = %q, want %q", tt.prefix, tt.name, got, tt.want)
+		}
+	}
+
+	// 替换或截断后相同的名称不再冲突
+	collisions := [][2]string{
+		{"get.weather", "get_weather"},
+		{"get weather", "get.weather"},
+		{long + "x", long + "y"},
+	}
+	for _, c := range collisions {
+		a, b := toolName("srv_", c[0]), toolName("srv_", c[1])
+		if a == b {
+			t.Errorf("%q and %q both map to %q", c[0], c[1], a)
+		}
+		if len(a) > maxToolName || len(b) > maxToolName {
+			t.Errorf("names too long: %q, %q", a, b)
+		}
+	}
+}
+
+func fnvHex(s string) string {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
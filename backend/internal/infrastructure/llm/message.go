@@ -0,0 +1,48 @@
+package llm
+
+import (
+	"fmt"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// toMessageContents 将对话消息列表转换为 langchaingo 的消息格式
+func toMessageContents(messages []Message) ([]llms.MessageContent, error) {
+	contents := make([]llms.MessageContent, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem:
+			contents = append(contents, llms.TextParts(llms.ChatMessageTypeSystem, msg.Content))
+		case RoleUser:
+			contents = append(contents, llms.TextParts(llms.ChatMessageTypeHuman, msg.Content))
+		case RoleAssistant:
+			content := llms.TextParts(llms.ChatMessageTypeAI, msg.Content)
+			if len(msg.ToolCalls) > 0 && msg.Content == "" {
+				content.Parts = nil
+			}
+			for _, call := range msg.ToolCalls {
+				content.Parts = append(content.Parts, llms.ToolCall{
+					ID:   call.ID,
+					Type: "function",
+					FunctionCall: &llms.FunctionCall{
+						Name:      call.Name,
+						Arguments: call.Arguments,
+					},
+				})
+			}
+			contents = append(contents, content)
+		case RoleTool:
+			contents = append(contents, llms.MessageContent{
+				Role: llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{
+					ToolCallID: msg.ToolCallID,
+					Name:       msg.Name,
+					Content:    msg.Content,
+				}},
+			})
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+	}
+	return contents, nil
+}
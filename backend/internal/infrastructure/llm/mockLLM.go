@@ -0,0 +1,409 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+// mock 回复模式
+const (
+	MockModeScript = "script"
+	MockModeEcho   = "echo"
+	MockModeCanned = "canned"
+)
+
+const (
+	defaultMockReply     = "这是一条来自 mock_llm 的回复。"
+	defaultMockChunkSize = 4
+)
+
+// MockFixture mock_llm 的脚本文件
+//
+//	replies:
+//	  - match: "星期几"           # 最后一条用户消息包含该子串即命中，为空时命中任意消息
+//	    reply: "今天是星期五"
+//	  - regex: "^(你好|hello)"    # 正则匹配
+//	    chunks: ["你好", "呀！"]   # 显式指定流式分块
+//	  - match: "报错"
+//	    error: "mock upstream error"
+//	    errorAfterChunks: 2        # 流式输出 2 块后再报错，0 表示首块之前报错
+//	  - match: "星期几"
+//	    toolCalls:                 # 请求携带工具时先发起工具调用
+//	      - name: "get_current_time"
+//	        arguments: "{}"
+//	    reply: "现在是 {{toolResult}}" # 收到工具结果后的回复，{{toolResult}} 替换为工具结果
+type MockFixture struct {
+	Replies []MockReply `mapstructure:"replies"`
+}
+
+// MockReply 一条脚本回复
+type MockReply struct {
+	Match            string         `mapstructure:"match"`
+	Regex            string         `mapstructure:"regex"`
+	Reply            string         `mapstructure:"reply"`
+	Chunks           []string       `mapstructure:"chunks"`
+	Delay            time.Duration  `mapstructure:"delay"`
+	Error            string         `mapstructure:"error"`
+	ErrorAfterChunks int            `mapstructure:"errorAfterChunks"`
+	ToolCalls        []MockToolCall `mapstructure:"toolCalls"`
+
+	re *regexp.Regexp
+}
+
+// MockToolCall 脚本中的一次工具调用
+type MockToolCall struct {
+	Name      string `mapstructure:"name"`
+	Arguments string `mapstructure:"arguments"`
+}
+
+// mockToolResultPlaceholder 回复中替换为工具结果的占位符
+const mockToolResultPlaceholder = "{{toolResult}}"
+
+// MockLLM 不依赖任何外部服务、输出确定的模拟模型
+type MockLLM struct {
+	mode       string
+	model      string
+	reply      string
+	chunkSize  int
+	chunkDelay time.Duration
+	err        string
+	replies    []MockReply
+	defaults   GenerateOptions
+}
+
+func NewMockLLM(cfg *config.LLMConfig) *MockLLM {
+	m := &MockLLM{
+		mode:       cfg.Mock.Mode,
+		model:      cfg.Model,
+		reply:      cfg.Mock.Reply,
+		chunkSize:  cfg.Mock.ChunkSize,
+		chunkDelay: cfg.Mock.ChunkDelay,
+		err:        cfg.Mock.Error,
+		defaults:   defaultOptions(cfg),
+	}
+	if m.mode == "" {
+		m.mode = MockModeCanned
+		if cfg.Mock.Fixture != "" {
+			m.mode = MockModeScript
+		}
+	}
+	if m.model == "" {
+		m.model = "mock"
+	}
+	if m.reply == "" {
+		m.reply = defaultMockReply
+	}
+	if m.chunkSize <= 0 {
+		m.chunkSize = defaultMockChunkSize
+	}
+	if cfg.Mock.Fixture != "" {
+		replies, err := loadMockFixture(cfg.Mock.Fixture)
+		if err != nil {
+			logger.Errorf("load mock llm fixture error:%s", err.Error())
+			return nil
+		}
+		m.replies = replies
+	}
+	return m
+}
+
+// loadMockFixture 通过 viper 读取脚本文件，按扩展名识别 yaml/json
+func loadMockFixture(path string) ([]MockReply, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	var fixture MockFixture
+	if err := v.Unmarshal(&fixture); err != nil {
+		return nil, err
+	}
+	for i := range fixture.Replies {
+		if fixture.Replies[i].Regex == "" {
+			continue
+		}
+		re, err := regexp.Compile(fixture.Replies[i].Regex)
+		if err != nil {
+			return nil, fmt.Errorf("replies[%d].regex: %w", i, err)
+		}
+		fixture.Replies[i].re = re
+	}
+	return fixture.Replies, nil
+}
+
+func (m *MockLLM) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	opts, script, err := m.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	if script.Error != "" {
+		return nil, errors.New(script.Error)
+	}
+	if err := sleepContext(ctx, script.Delay); err != nil {
+		return nil, err
+	}
+	if calls := mockToolCalls(script); len(calls) > 0 {
+		return &ChatResponse{
+			ID:      newResponseID(),
+			Object:  chatCompletionObject,
+			Created: time.Now(),
+			Model:   m.model,
+			Choices: []Choice{{
+				FinishReason: FinishReasonToolCalls,
+				ToolCalls:    calls,
+			}},
+			Usage: mockUsage(req.Messages, ""),
+		}, nil
+	}
+	text, finishReason := applyLimits(strings.Join(script.Chunks, ""), opts)
+	return &ChatResponse{
+		ID:      newResponseID(),
+		Object:  chatCompletionObject,
+		Created: time.Now(),
+		Model:   m.model,
+		Choices: []Choice{{
+			Message:      text,
+			FinishReason: finishReason,
+		}},
+		Usage: mockUsage(req.Messages, text),
+	}, nil
+}
+
+func (m *MockLLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-chan *StreamChunk, error) {
+	opts, script, err := m.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+	resChan := make(chan *StreamChunk, 10)
+	go func() {
+		defer close(resChan)
+
+		id := newResponseID()
+		send := func(chunk *StreamChunk) bool {
+			chunk.ID = id
+			select {
+			case resChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		// cancelled 以 ctx 的错误结束流，调用方据此区分取消与正常结束；调用方不再读取时不阻塞
+		cancelled := func() {
+			select {
+			case resChan <- &StreamChunk{ID: id, Error: ctx.Err(), Done: true}:
+			default:
+			}
+		}
+		if err := sleepContext(ctx, script.Delay); err != nil {
+			cancelled()
+			return
+		}
+		if calls := mockToolCalls(script); len(calls) > 0 {
+			deltas := make([]ToolCallDelta, 0, len(calls))
+			for i, call := range calls {
+				deltas = append(deltas, ToolCallDelta{Index: i, ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+			}
+			if !send(&StreamChunk{ToolCallDeltas: deltas}) {
+				cancelled()
+				return
+			}
+			usage := mockUsage(req.Messages, "")
+			send(&StreamChunk{Done: true, FinishReason: FinishReasonToolCalls, Usage: &usage, ToolCalls: calls})
+			return
+		}
+
+		text, finishReason := applyLimits(strings.Join(script.Chunks, ""), opts)
+		var sent strings.Builder
+		for i, chunk := range script.Chunks {
+			if script.Error != "" && i == script.ErrorAfterChunks {
+				break
+			}
+			// 截断后的文本可能短于原始分块
+			remaining := strings.TrimPrefix(text, sent.String())
+			if remaining == "" {
+				break
+			}
+			if len(chunk) > len(remaining) {
+				chunk = remaining
+			}
+			if i > 0 {
+				if err := sleepContext(ctx, m.chunkDelay); err != nil {
+					cancelled()
+					return
+				}
+			}
+			if !send(&StreamChunk{Message: chunk}) {
+				cancelled()
+				return
+			}
+			sent.WriteString(chunk)
+		}
+		if script.Error != "" {
+			send(&StreamChunk{Error: errors.New(script.Error), Done: true})
+			return
+		}
+		usage := mockUsage(req.Messages, text)
+		send(&StreamChunk{Done: true, FinishReason: finishReason, Usage: &usage})
+	}()
+	return resChan, nil
+}
+
+func (m *MockLLM) ValidateConfig() error {
+	switch m.mode {
+	case MockModeScript, MockModeEcho, MockModeCanned:
+	default:
+		return fmt.Errorf("unsupported mock mode: %s", m.mode)
+	}
+	return m.defaults.validate("mock_llm", allParams)
+}
+
+// SupportsTools 脚本中配置了 toolCalls 的回复会发起工具调用
+func (m *MockLLM) SupportsTools() bool {
+	return true
+}
+
+// prepare 校验参数并决定本次调用的脚本
+func (m *MockLLM) prepare(req *ChatRequest) (GenerateOptions, MockReply, error) {
+	opts := req.Options.withDefaults(m.defaults)
+	if err := opts.validate("mock_llm", allParams); err != nil {
+		return opts, MockReply{}, err
+	}
+	if err := validateTools("mock_llm", req.Tools, true); err != nil {
+		return opts, MockReply{}, err
+	}
+	if err := validateResponseFormat("mock_llm", req.ResponseFormat, false); err != nil {
+		return opts, MockReply{}, err
+	}
+	script := m.script(lastUserMessage(req.Messages))
+	// 只有请求携带工具、且尚未收到工具结果时才发起工具调用
+	results := trailingToolResults(req.Messages)
+	if len(req.Tools) == 0 || len(results) > 0 {
+		script.ToolCalls = nil
+	}
+	if strings.Contains(script.Reply, mockToolResultPlaceholder) {
+		script.Reply = strings.ReplaceAll(script.Reply, mockToolResultPlaceholder, strings.Join(results, "；"))
+		script.Chunks = nil
+	}
+	if len(script.Chunks) == 0 {
+		script.Chunks = splitRunes(script.Reply, m.chunkSize)
+	}
+	return opts, script, nil
+}
+
+// script 按模式选出回复脚本
+func (m *MockLLM) script(input string) MockReply {
+	if m.err != "" {
+		return MockReply{Error: m.err}
+	}
+	switch m.mode {
+	case MockModeEcho:
+		return MockReply{Reply: input}
+	case MockModeScript:
+		for _, r := range m.replies {
+			if r.re != nil && !r.re.MatchString(input) {
+				continue
+			}
+			if r.Match != "" && !strings.Contains(input, r.Match) {
+				continue
+			}
+			return r
+		}
+	}
+	return MockReply{Reply: m.reply}
+}
+
+func lastUserMessage(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// trailingToolResults 返回对话末尾连续的工具结果
+func trailingToolResults(messages []Message) []string {
+	var results []string
+	for i := len(messages) - 1; i >= 0 && messages[i].Role == RoleTool; i-- {
+		results = append([]string{messages[i].Content}, results...)
+	}
+	return results
+}
+
+func mockToolCalls(script MockReply) []ToolCall {
+	if len(script.ToolCalls) == 0 {
+		return nil
+	}
+	calls := make([]ToolCall, 0, len(script.ToolCalls))
+	for _, call := range script.ToolCalls {
+		args := call.Arguments
+		if args == "" {
+			args = "{}"
+		}
+		calls = append(calls, ToolCall{ID: "call_" + uuid.NewString(), Name: call.Name, Arguments: args})
+	}
+	return calls
+}
+
+// splitRunes 按字符数切分文本，保证不会截断多字节字符
+func splitRunes(text string, size int) []string {
+	runes := []rune(text)
+	chunks := make([]string, 0, len(runes)/size+1)
+	for start := 0; start < len(runes); start += size {
+		end := min(start+size, len(runes))
+		chunks = append(chunks, string(runes[start:end]))
+	}
+	return chunks
+}
+
+// applyLimits 模拟停止词与 maxTokens（按字符计）对输出的截断
+func applyLimits(text string, opts GenerateOptions) (string, string) {
+	for _, stop := range opts.Stop {
+		if idx := strings.Index(text, stop); stop != "" && idx >= 0 {
+			text = text[:idx]
+		}
+	}
+	if opts.MaxTokens != nil && utf8.RuneCountInString(text) > *opts.MaxTokens {
+		return string([]rune(text)[:*opts.MaxTokens]), "length"
+	}
+	return text, "stop"
+}
+
+// mockUsage 按字符数统计用量，保证结果确定
+func mockUsage(messages []Message, reply string) Usage {
+	prompt := 0
+	for _, msg := range messages {
+		prompt += utf8.RuneCountInString(msg.Content)
+	}
+	completion := utf8.RuneCountInString(reply)
+	return Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
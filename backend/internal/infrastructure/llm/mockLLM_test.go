@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+const mockFixture = `
+replies:
+  - match: "星期几"
+    reply: "今天是星期五"
+  - regex: "^(你好|hello)"
+    chunks: ["你好", "呀！"]
+  - match: "报错"
+    chunks: ["一", "二", "三"]
+    error: "mock upstream error"
+    errorAfterChunks: 2
+  - match: "慢"
+    delay: 1s
+    reply: "终于"
+`
+
+func newTestMock(t *testing.T, mock config.MockConfig) *MockLLM {
+	t.Helper()
+	if mock.Fixture != "" {
+		path := filepath.Join(t.TempDir(), "fixture.yaml")
+		if err := os.WriteFile(path, []byte(mock.Fixture), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		mock.Fixture = path
+	}
+	m := NewMockLLM(&config.LLMConfig{Provider: "mock_llm", Model: "mock-1", Mock: mock})
+	if m == nil {
+		t.Fatal("NewMockLLM returned nil")
+	}
+	if err := m.ValidateConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func userRequest(content string) *ChatRequest {
+	return &ChatRequest{Messages: []Message{{Role: RoleUser, Content: content}}}
+}
+
+// collect 读取全部流式分块，返回拼接的文本与最后一块
+func collect(t *testing.T, chunks <-chan *StreamChunk) ([]string, *StreamChunk) {
+	t.Helper()
+	var texts []string
+	var last *StreamChunk
+	for chunk := range chunks {
+		if chunk.Message != "" {
+			texts = append(texts, chunk.Message)
+		}
+		last = chunk
+	}
+	return texts, last
+}
+
+func TestMockModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		mock  config.MockConfig
+		input string
+		want  string
+	}{
+		{"canned default", config.MockConfig{}, "随便", defaultMockReply},
+		{"canned reply", config.MockConfig{Mode: MockModeCanned, Reply: "固定回复"}, "随便", "固定回复"},
+		{"echo", config.MockConfig{Mode: MockModeEcho}, "学我说话", "学我说话"},
+		{"script match", config.MockConfig{Fixture: mockFixture}, "今天星期几？", "今天是星期五"},
+		{"script regex", config.MockConfig{Fixture: mockFixture}, "hello there", "你好呀！"},
+		{"script fallback", config.MockConfig{Fixture: mockFixture, Reply: "兜底"}, "没有匹配", "兜底"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMock(t, tt.mock)
+			res, err := m.GenerateChat(context.Background(), userRequest(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.Content(); got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+			if res.Choices[0].FinishReason != "stop" {
+				t.Errorf("finish reason = %q", res.Choices[0].FinishReason)
+			}
+
+			texts, last := collect(t, mustStream(t, m, userRequest(tt.input)))
+			if got := strings.Join(texts, ""); got != tt.want {
+				t.Errorf("streamed = %q, want %q", got, tt.want)
+			}
+			if last == nil || !last.Done || last.Error != nil || last.Usage == nil {
+				t.Errorf("last chunk = %+v", last)
+			}
+		})
+	}
+}
+
+func mustStream(t *testing.T, m *MockLLM, req *ChatRequest) <-chan *StreamChunk {
+	t.Helper()
+	chunks, err := m.GenerateStream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chunks
+}
+
+func TestMockChunking(t *testing.T) {
+	m := newTestMock(t, config.MockConfig{Reply: "一二三四五六七", ChunkSize: 3, ChunkDelay: 20 * time.Millisecond})
+	start := time.Now()
+	texts, _ := collect(t, mustStream(t, m, userRequest("hi")))
+	if want := []string{"一二三", "四五六", "七"}; strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("chunks = %q, want %q", texts, want)
+	}
+	// 首块立即发送，之后每块间隔 chunkDelay
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("stream took %s, want at least 40ms", elapsed)
+	}
+}
+
+func TestMockErrorAfterChunks(t *testing.T) {
+	m := newTestMock(t, config.MockConfig{Fixture: mockFixture})
+	texts, last := collect(t, mustStream(t, m, userRequest("请报错")))
+	if strings.Join(texts, "") != "一二" {
+		t.Errorf("chunks before error = %q", texts)
+	}
+	if last == nil || last.Error == nil || last.Error.Error() != "mock upstream error" || !last.Done {
+		t.Errorf("last chunk = %+v, want error", last)
+	}
+	if _, err := m.GenerateChat(context.Background(), userRequest("请报错")); err == nil {
+		t.Error("GenerateChat: want error")
+	}
+
+	// 全局 error 在首块之前返回
+	m = newTestMock(t, config.MockConfig{Error: "down"})
+	texts, last = collect(t, mustStream(t, m, userRequest("hi")))
+	if len(texts) != 0 || last == nil || last.Error == nil {
+		t.Errorf("texts = %q, last = %+v", texts, last)
+	}
+}
+
+func TestMockLimits(t *testing.T) {
+	m := newTestMock(t, config.MockConfig{Reply: "第一句。第二句。第三句。", ChunkSize: 2})
+	maxTokens := 5
+	tests := []struct {
+		name         string
+		opts         GenerateOptions
+		want, reason string
+	}{
+		{"stop", GenerateOptions{Stop: []string{"第二"}}, "第一句。", "stop"},
+		{"maxTokens", GenerateOptions{MaxTokens: &maxTokens}, "第一句。第", "length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := userRequest("hi")
+			req.Options = tt.opts
+			res, err := m.GenerateChat(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Content() != tt.want || res.Choices[0].FinishReason != tt.reason {
+				t.Errorf("reply = %q (%s), want %q (%s)", res.Content(), res.Choices[0].FinishReason, tt.want, tt.reason)
+			}
+			texts, last := collect(t, mustStream(t, m, req))
+			if strings.Join(texts, "") != tt.want || last.FinishReason != tt.reason {
+				t.Errorf("streamed = %q (%s), want %q (%s)", texts, last.FinishReason, tt.want, tt.reason)
+			}
+			if last.Usage.CompletionTokens != len([]rune(tt.want)) {
+				t.Errorf("completion tokens = %d", last.Usage.CompletionTokens)
+			}
+		})
+	}
+}
+
+func TestMockStreamCancelled(t *testing.T) {
+	tests := []struct {
+		name  string
+		mock  config.MockConfig
+		input string
+	}{
+		// 取消发生在脚本的 delay 中
+		{"delay", config.MockConfig{Fixture: mockFixture}, "慢一点"},
+		// 取消发生在分块间隔中
+		{"chunk delay", config.MockConfig{Reply: "一二三四", ChunkSize: 1, ChunkDelay: time.Second}, "hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMock(t, tt.mock)
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+			defer cancel()
+			chunks, err := m.GenerateStream(ctx, userRequest(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, last := collect(t, chunks)
+			if last == nil || !errors.Is(last.Error, context.DeadlineExceeded) || !last.Done {
+				t.Errorf("last chunk = %+v, want context deadline error", last)
+			}
+		})
+	}
+}
@@ -1,79 +1,397 @@
 package llm
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/ai-companion/backend/internal/pkg/config"
 	"github.com/ai-companion/backend/internal/pkg/logger"
-	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/google/uuid"
 )
 
+const defaultOllamaBaseUrl = "http://localhost:11434"
+
+// OllamaLLM 直接调用 Ollama 的 /api/chat 接口。langchaingo 的 ollama 客户端不支持工具调用，
+// 也不返回 done_reason，因此这里自行实现请求与流式解析
 type OllamaLLM struct {
-	llm *ollama.LLM
+	client   *http.Client
+	baseUrl  string
+	model    string
+	provider string
+	defaults GenerateOptions
+	// numCtx 配置的上下文长度，为 0 时使用 Ollama 的默认值
+	numCtx int
 }
 
 func NewOllamaLLM(cfg *config.LLMConfig) *OllamaLLM {
-	llm, err := ollama.New(
-		ollama.WithModel(cfg.Model),
-	)
-	if err != nil {
-		logger.Errorf("new ollama llm error:%s", err.Error())
-		return nil
+	baseUrl := strings.TrimRight(cfg.BaseUrl, "/")
+	if baseUrl == "" {
+		baseUrl = defaultOllamaBaseUrl
 	}
 	return &OllamaLLM{
-		llm: llm,
+		client:   newHTTPClient(),
+		baseUrl:  baseUrl,
+		model:    cfg.Model,
+		provider: cfg.Provider,
+		defaults: defaultOptions(cfg),
+		numCtx:   cfg.ContextWindow,
+	}
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []ollamaTool    `json:"tools,omitempty"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+	// Thinking 开启 think 的推理模型返回的思考过程
+	Thinking string `json:"thinking,omitempty"`
+}
+
+type ollamaTool struct {
+	Type     string         `json:"type"`
+	Function ollamaFunction `json:"function"`
+}
+
+type ollamaFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// ollamaToolCall Ollama 的工具调用没有ID，参数是 JSON 对象而不是字符串
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (r *ollamaChatResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
 	}
 }
 
 func (o *OllamaLLM) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	res, err := llms.GenerateFromSinglePrompt(ctx, o.llm, req.Message)
+	body, err := o.buildRequest(req, false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.post(ctx, body)
 	if err != nil {
 		logger.Errorf("generate chat_domain error : %s", err.Error())
 		return nil, err
 	}
-	return &ChatResponse{Object: res}, nil
+	defer resp.Body.Close()
+
+	var res ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode ollama response: %w", err)
+	}
+	if res.Error != "" {
+		return nil, errors.New(res.Error)
+	}
+	toolCalls := fromOllamaToolCalls(res.Message.ToolCalls)
+	return &ChatResponse{
+		ID:      newResponseID(),
+		Object:  chatCompletionObject,
+		Created: time.Now(),
+		Model:   o.model,
+		Choices: []Choice{{
+			Message:      res.Message.Content,
+			FinishReason: ollamaFinishReason(res.DoneReason, toolCalls),
+			ToolCalls:    toolCalls,
+			Reasoning:    res.Message.Thinking,
+		}},
+		Usage: res.usage(),
+	}, nil
 }
 
 func (o *OllamaLLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-chan *StreamChunk, error) {
+	body, err := o.buildRequest(req, true)
+	if err != nil {
+		return nil, err
+	}
 	resChan := make(chan *StreamChunk, 10)
 	go func() {
 		defer close(resChan) // 确保 channel 在结束时关闭
 
-		_, err := o.llm.GenerateContent(
-			ctx,
-			[]llms.MessageContent{
-				llms.TextParts(llms.ChatMessageTypeSystem, "你是一个非常有用的助理"),
-				llms.TextParts(llms.ChatMessageTypeHuman, req.Message), // 使用请求中的消息
-			},
-			// 开启流式输出
-			llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
-				select {
-				case resChan <- &StreamChunk{
-					Message: string(chunk),
-				}:
-				case <-ctx.Done():
-					// 如果上下文被取消，停止发送
-					return ctx.Err()
-				}
-				return nil
-			}),
-		)
-
-		if err != nil {
-			// 发送错误信息到 channel
+		id := newResponseID()
+		send := func(chunk *StreamChunk) bool {
+			chunk.ID = id
 			select {
-			case resChan <- &StreamChunk{
-				Error: err,
-				Done:  true,
-			}:
+			case resChan <- chunk:
+				return true
 			case <-ctx.Done():
+				return false
 			}
 		}
+
+		resp, err := o.post(ctx, body)
+		if err != nil {
+			logger.Errorf("ollama generate stream error : %s", err.Error())
+			send(&StreamChunk{Error: err, Done: true})
+			return
+		}
+		defer resp.Body.Close()
+
+		// Ollama 的流式响应是逐行的 JSON，最后一行 done 为 true 并携带统计信息
+		var toolCalls []ToolCall
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			var res ollamaChatResponse
+			if err := json.Unmarshal(line, &res); err != nil {
+				send(&StreamChunk{Error: fmt.Errorf("decode ollama stream chunk: %w", err), Done: true})
+				return
+			}
+			if res.Error != "" {
+				send(&StreamChunk{Error: errors.New(res.Error), Done: true})
+				return
+			}
+			if res.Message.Thinking != "" && !send(&StreamChunk{Reasoning: res.Message.Thinking}) {
+				return
+			}
+			chunk := &StreamChunk{Message: res.Message.Content}
+			// Ollama 一次性返回完整的工具调用，每个调用作为一条增量下发
+			for _, call := range fromOllamaToolCalls(res.Message.ToolCalls) {
+				chunk.ToolCallDeltas = append(chunk.ToolCallDeltas, ToolCallDelta{
+					Index:     len(toolCalls),
+					ID:        call.ID,
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				})
+				toolCalls = append(toolCalls, call)
+			}
+			if chunk.Message != "" || len(chunk.ToolCallDeltas) > 0 {
+				if !send(chunk) {
+					return
+				}
+			}
+			if res.Done {
+				usage := res.usage()
+				send(&StreamChunk{
+					Done:         true,
+					FinishReason: ollamaFinishReason(res.DoneReason, toolCalls),
+					Usage:        &usage,
+					ToolCalls:    toolCalls,
+				})
+				return
+			}
+		}
+		err = scanner.Err()
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		logger.Errorf("ollama read stream error : %s", err.Error())
+		send(&StreamChunk{Error: err, Done: true})
 	}()
 	return resChan, nil
 }
 
 func (o *OllamaLLM) ValidateConfig() error {
-	return nil
+	if o.model == "" {
+		return errors.New("ollama llm requires model")
+	}
+	return o.defaults.validate(o.provider, ollamaParams)
+}
+
+func (o *OllamaLLM) SupportsTools() bool {
+	return true
+}
+
+func (o *OllamaLLM) SupportsResponseFormat() bool {
+	return true
+}
+
+// ollamaParams OllamaLLM 支持的生成参数
+var ollamaParams = allParams
+
+func (o *OllamaLLM) buildRequest(req *ChatRequest, stream bool) ([]byte, error) {
+	opts := req.Options.withDefaults(o.defaults)
+	if err := validateTools(o.provider, req.Tools, true); err != nil {
+		return nil, err
+	}
+	if err := validateResponseFormat(o.provider, req.ResponseFormat, true); err != nil {
+		return nil, err
+	}
+	if err := opts.validate(o.provider, ollamaParams); err != nil {
+		return nil, err
+	}
+	messages, err := toOllamaMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	chatReq := &ollamaChatRequest{
+		Model:    o.model,
+		Messages: messages,
+		Stream:   stream,
+		Options:  ollamaOptions(opts),
+		Format:   ollamaFormat(req.ResponseFormat),
+	}
+	if o.numCtx > 0 {
+		if chatReq.Options == nil {
+			chatReq.Options = make(map[string]any)
+		}
+		chatReq.Options["num_ctx"] = o.numCtx
+	}
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, ollamaTool{
+			Type: "function",
+			Function: ollamaFunction{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  toolParameters(tool),
+			},
+		})
+	}
+	return json.Marshal(chatReq)
+}
+
+func (o *OllamaLLM) post(ctx context.Context, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseUrl+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("ollama returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// ollamaOptions 转换为 Ollama 的 options 字段
+func ollamaOptions(opts GenerateOptions) map[string]any {
+	options := make(map[string]any)
+	if opts.Temperature != nil {
+		options["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		options["top_p"] = *opts.TopP
+	}
+	if opts.MaxTokens != nil {
+		options["num_predict"] = *opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		options["stop"] = opts.Stop
+	}
+	if opts.Seed != nil {
+		options["seed"] = *opts.Seed
+	}
+	if opts.PresencePenalty != nil {
+		options["presence_penalty"] = *opts.PresencePenalty
+	}
+	if opts.FrequencyPenalty != nil {
+		options["frequency_penalty"] = *opts.FrequencyPenalty
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// ollamaFormat 转换为 Ollama 的 format 字段："json" 或 JSON Schema 对象
+func ollamaFormat(format *ResponseFormat) json.RawMessage {
+	if format == nil {
+		return nil
+	}
+	if format.Type == FormatJSONSchema {
+		if schema, err := json.Marshal(format.Schema); err == nil {
+			return schema
+		}
+	}
+	return json.RawMessage(`"json"`)
+}
+
+// toOllamaMessages 转换对话消息，工具调用参数需要从 JSON 字符串还原为对象
+func toOllamaMessages(messages []Message) ([]ollamaMessage, error) {
+	res := make([]ollamaMessage, 0, len(messages))
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleSystem, RoleUser, RoleAssistant, RoleTool:
+		default:
+			return nil, fmt.Errorf("unsupported message role: %s", msg.Role)
+		}
+		m := ollamaMessage{Role: string(msg.Role), Content: msg.Content, ToolName: msg.Name}
+		for _, call := range msg.ToolCalls {
+			var tc ollamaToolCall
+			tc.Function.Name = call.Name
+			tc.Function.Arguments = json.RawMessage(call.Arguments)
+			if !json.Valid(tc.Function.Arguments) {
+				tc.Function.Arguments = json.RawMessage("{}")
+			}
+			m.ToolCalls = append(m.ToolCalls, tc)
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+// fromOllamaToolCalls Ollama 不返回调用ID，这里补上以便与工具结果对应
+func fromOllamaToolCalls(calls []ollamaToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	res := make([]ToolCall, 0, len(calls))
+	for _, call := range calls {
+		args := string(call.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		res = append(res, ToolCall{
+			ID:        "call_" + uuid.NewString(),
+			Name:      call.Function.Name,
+			Arguments: args,
+		})
+	}
+	return res
+}
+
+func ollamaFinishReason(doneReason string, toolCalls []ToolCall) string {
+	if len(toolCalls) > 0 {
+		return FinishReasonToolCalls
+	}
+	if doneReason == "" {
+		return "stop"
+	}
+	return doneReason
 }
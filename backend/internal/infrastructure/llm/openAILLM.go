@@ -2,6 +2,10 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/ai-companion/backend/internal/pkg/config"
@@ -12,6 +16,11 @@ import (
 
 type OpenAILLM struct {
 	llm *openai.LLM
+	// clientOpts 创建 llm 时的参数，结构化输出需要以此创建带 response_format 的客户端
+	clientOpts []openai.Option
+	model      string
+	provider   string
+	defaults   GenerateOptions
 }
 
 var openAILLM *OpenAILLM
@@ -20,6 +29,7 @@ func NewOpenAILLM(cfg *config.LLMConfig) *OpenAILLM {
 	opts := []openai.Option{
 		openai.WithToken(cfg.Token),
 		openai.WithModel(cfg.Model),
+		openai.WithHTTPClient(newHTTPClient()),
 	}
 	if cfg.BaseUrl != "" {
 		opts = append(opts, openai.WithBaseURL(cfg.BaseUrl))
@@ -33,18 +43,57 @@ func NewOpenAILLM(cfg *config.LLMConfig) *OpenAILLM {
 		logger.Errorf("connection openAI error : %s", err.Error())
 		return nil
 	}
-	return &OpenAILLM{llm: llm}
+	return &OpenAILLM{
+		llm:        llm,
+		clientOpts: opts,
+		model:      cfg.Model,
+		provider:   cfg.Provider,
+		defaults:   defaultOptions(cfg),
+	}
 }
 
 func (o *OpenAILLM) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	res, err := llms.GenerateFromSinglePrompt(ctx, o.llm, req.Message)
+	messages, err := toMessageContents(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := o.options(req)
+	if err != nil {
+		return nil, err
+	}
+	client, err := o.client(req.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
+	var reasoning strings.Builder
+	ctx = withReasoningTap(ctx, func(text string) { reasoning.WriteString(text) })
+	res, err := client.GenerateContent(ctx, messages, append(opts.callOptions(), toolCallOptions(req.Tools)...)...)
 	if err != nil {
 		return nil, err
 	}
-	return &ChatResponse{Object: res}, nil
+	if len(res.Choices) == 0 {
+		return nil, errors.New("empty response from openai")
+	}
+	resp := newChatResponse(o.model, res)
+	if len(resp.Choices) > 0 {
+		resp.Choices[0].Reasoning = reasoning.String()
+	}
+	return resp, nil
 }
 
 func (o *OpenAILLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-chan *StreamChunk, error) {
+	messages, err := toMessageContents(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := o.options(req)
+	if err != nil {
+		return nil, err
+	}
+	client, err := o.client(req.ResponseFormat)
+	if err != nil {
+		return nil, err
+	}
 	// 创建带缓冲的 channel，避免阻塞
 	resChan := make(chan *StreamChunk, 10)
 
@@ -55,36 +104,54 @@ func (o *OpenAILLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-cha
 		streamCtx, streamCancel := context.WithTimeout(ctx, 30*time.Second)
 		defer streamCancel()
 
-		_, err := o.llm.GenerateContent(
+		id := newResponseID()
+		var toolDeltas toolDeltaParser
+		// reasoning_content 由 Transport 在读取响应体时截获，单独作为推理分块输出
+		streamCtx = withReasoningTap(streamCtx, func(text string) {
+			select {
+			case resChan <- &StreamChunk{ID: id, Reasoning: text}:
+			case <-streamCtx.Done():
+			}
+		})
+		res, err := client.GenerateContent(
 			streamCtx,
-			[]llms.MessageContent{
-				llms.TextParts(llms.ChatMessageTypeSystem, "你是一个非常有用的助理"),
-				llms.TextParts(llms.ChatMessageTypeHuman, req.Message),
-			},
-			// 尝试启用流式输出
-			llms.WithStreamingFunc(func(streamCtx context.Context, chunk []byte) error {
-				chunkStr := string(chunk)
-				if chunkStr != "" {
+			messages,
+			append(append(opts.callOptions(), toolCallOptions(req.Tools)...),
+				// 尝试启用流式输出
+				llms.WithStreamingFunc(func(streamCtx context.Context, chunk []byte) error {
+					if len(chunk) == 0 {
+						return nil
+					}
+					streamChunk := &StreamChunk{ID: id, Message: string(chunk)}
+					if len(req.Tools) > 0 {
+						if deltas, ok := toolDeltas.parse(chunk); ok {
+							streamChunk = &StreamChunk{ID: id, ToolCallDeltas: deltas}
+						}
+					}
 					select {
-					case resChan <- &StreamChunk{
-						Message: chunkStr,
-					}:
+					case resChan <- streamChunk:
 					case <-streamCtx.Done():
 						return streamCtx.Err()
 					}
-				}
-				return nil
-			}),
+					return nil
+				}))...,
 		)
 		if err != nil {
 			// 错误处理
+			logger.Error("Failed to create stream: " + err.Error())
 			select {
 			case resChan <- &StreamChunk{
+				ID:    id,
 				Error: err,
 				Done:  true,
 			}:
-				logger.Error("Failed to create stream: " + err.Error())
+			case <-ctx.Done():
 			}
+			return
+		}
+		select {
+		case resChan <- newFinalChunk(id, res):
+		case <-ctx.Done():
 		}
 	}()
 
@@ -92,5 +159,57 @@ func (o *OpenAILLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-cha
 }
 
 func (o *OpenAILLM) ValidateConfig() error {
-	return nil
+	return o.defaults.validate(o.provider, openAIParams)
+}
+
+// openAIParams OpenAILLM 支持的生成参数
+var openAIParams = paramSpec{params: []string{ParamTemperature, ParamMaxTokens, ParamStop, ParamSeed,
+	ParamPresencePenalty, ParamFrequencyPenalty}}
+
+// options 合并默认生成参数并校验
+func (o *OpenAILLM) options(req *ChatRequest) (GenerateOptions, error) {
+	opts := req.Options.withDefaults(o.defaults)
+	if err := validateTools(o.provider, req.Tools, true); err != nil {
+		return opts, err
+	}
+	if err := validateResponseFormat(o.provider, req.ResponseFormat, true); err != nil {
+		return opts, err
+	}
+	return opts, opts.validate(o.provider, openAIParams)
+}
+
+func (o *OpenAILLM) SupportsTools() bool {
+	return true
+}
+
+func (o *OpenAILLM) SupportsResponseFormat() bool {
+	return true
+}
+
+// client langchaingo 只能在创建客户端时指定 response_format，指定了输出格式的请求使用单独创建的客户端
+func (o *OpenAILLM) client(format *ResponseFormat) (*openai.LLM, error) {
+	if format == nil {
+		return o.llm, nil
+	}
+	responseFormat := openai.ResponseFormatJSON
+	if format.Type == FormatJSONSchema {
+		// 经 JSON 转换为 langchaingo 的 Schema 结构，其不支持的关键字会被忽略
+		data, err := json.Marshal(format.Schema)
+		if err != nil {
+			return nil, &ValidationError{Param: ParamResponseFormat, Reason: err.Error()}
+		}
+		var schema openai.ResponseFormatJSONSchemaProperty
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, &ValidationError{Param: ParamResponseFormat, Reason: err.Error()}
+		}
+		responseFormat = &openai.ResponseFormat{
+			Type: FormatJSONSchema,
+			JSONSchema: &openai.ResponseFormatJSONSchema{
+				Name:   format.name(),
+				Strict: format.Strict,
+				Schema: &schema,
+			},
+		}
+	}
+	return openai.New(append(slices.Clone(o.clientOpts), openai.WithResponseFormat(responseFormat))...)
 }
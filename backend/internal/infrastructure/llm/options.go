@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// 生成参数名称，用于校验与错误提示
+const (
+	ParamTemperature      = "temperature"
+	ParamTopP             = "topP"
+	ParamMaxTokens        = "maxTokens"
+	ParamStop             = "stop"
+	ParamSeed             = "seed"
+	ParamPresencePenalty  = "presencePenalty"
+	ParamFrequencyPenalty = "frequencyPenalty"
+)
+
+// defaultMaxTemperature temperature 的默认上限
+const defaultMaxTemperature = 2.0
+
+// paramSpec 模型后端支持的生成参数及取值范围
+type paramSpec struct {
+	params []string
+	// maxTemperature temperature 的上限，为 0 时使用 defaultMaxTemperature
+	maxTemperature float64
+}
+
+// allParams 支持全部生成参数
+var allParams = paramSpec{params: []string{ParamTemperature, ParamTopP, ParamMaxTokens, ParamStop,
+	ParamSeed, ParamPresencePenalty, ParamFrequencyPenalty}}
+
+// GenerateOptions 单次请求的生成参数，字段为 nil 或空表示使用默认值
+type GenerateOptions struct {
+	Temperature      *float64
+	TopP             *float64
+	MaxTokens        *int
+	Stop             []string
+	Seed             *int
+	PresencePenalty  *float64
+	FrequencyPenalty *float64
+}
+
+// ValidationError 请求参数不合法，或当前模型后端不支持该参数
+type ValidationError struct {
+	Param  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid parameter %s: %s", e.Param, e.Reason)
+}
+
+// defaultOptions 从 LLMConfig 中读取默认生成参数
+func defaultOptions(cfg *config.LLMConfig) GenerateOptions {
+	return GenerateOptions{
+		Temperature:      cfg.Temperature,
+		TopP:             cfg.TopP,
+		MaxTokens:        cfg.MaxTokens,
+		Stop:             cfg.Stop,
+		Seed:             cfg.Seed,
+		PresencePenalty:  cfg.PresencePenalty,
+		FrequencyPenalty: cfg.FrequencyPenalty,
+	}
+}
+
+// withDefaults 未在请求中指定的参数使用默认值补齐
+func (o GenerateOptions) withDefaults(defaults GenerateOptions) GenerateOptions {
+	if o.Temperature == nil {
+		o.Temperature = defaults.Temperature
+	}
+	if o.TopP == nil {
+		o.TopP = defaults.TopP
+	}
+	if o.MaxTokens == nil {
+		o.MaxTokens = defaults.MaxTokens
+	}
+	if len(o.Stop) == 0 {
+		o.Stop = defaults.Stop
+	}
+	if o.Seed == nil {
+		o.Seed = defaults.Seed
+	}
+	if o.PresencePenalty == nil {
+		o.PresencePenalty = defaults.PresencePenalty
+	}
+	if o.FrequencyPenalty == nil {
+		o.FrequencyPenalty = defaults.FrequencyPenalty
+	}
+	return o
+}
+
+// setParams 返回已设置的参数名称
+func (o GenerateOptions) setParams() []string {
+	var params []string
+	if o.Temperature != nil {
+		params = append(params, ParamTemperature)
+	}
+	if o.TopP != nil {
+		params = append(params, ParamTopP)
+	}
+	if o.MaxTokens != nil {
+		params = append(params, ParamMaxTokens)
+	}
+	if len(o.Stop) > 0 {
+		params = append(params, ParamStop)
+	}
+	if o.Seed != nil {
+		params = append(params, ParamSeed)
+	}
+	if o.PresencePenalty != nil {
+		params = append(params, ParamPresencePenalty)
+	}
+	if o.FrequencyPenalty != nil {
+		params = append(params, ParamFrequencyPenalty)
+	}
+	return params
+}
+
+// validate 校验参数取值范围，以及 provider 是否支持已设置的参数
+func (o GenerateOptions) validate(provider string, spec paramSpec) error {
+	for _, param := range o.setParams() {
+		if !slices.Contains(spec.params, param) {
+			return &ValidationError{Param: param, Reason: fmt.Sprintf("not supported by %s", provider)}
+		}
+	}
+	maxTemperature := spec.maxTemperature
+	if maxTemperature == 0 {
+		maxTemperature = defaultMaxTemperature
+	}
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > maxTemperature) {
+		return &ValidationError{Param: ParamTemperature, Reason: fmt.Sprintf("must be between 0 and %g for %s", maxTemperature, provider)}
+	}
+	if o.TopP != nil && (*o.TopP <= 0 || *o.TopP > 1) {
+		return &ValidationError{Param: ParamTopP, Reason: "must be in (0, 1]"}
+	}
+	if o.MaxTokens != nil && *o.MaxTokens <= 0 {
+		return &ValidationError{Param: ParamMaxTokens, Reason: "must be positive"}
+	}
+	if len(o.Stop) > 4 {
+		return &ValidationError{Param: ParamStop, Reason: "at most 4 stop sequences"}
+	}
+	if o.PresencePenalty != nil && (*o.PresencePenalty < -2 || *o.PresencePenalty > 2) {
+		return &ValidationError{Param: ParamPresencePenalty, Reason: "must be between -2 and 2"}
+	}
+	if o.FrequencyPenalty != nil && (*o.FrequencyPenalty < -2 || *o.FrequencyPenalty > 2) {
+		return &ValidationError{Param: ParamFrequencyPenalty, Reason: "must be between -2 and 2"}
+	}
+	return nil
+}
+
+// callOptions 转换为 langchaingo 的调用参数
+func (o GenerateOptions) callOptions() []llms.CallOption {
+	var opts []llms.CallOption
+	if o.Temperature != nil {
+		opts = append(opts, llms.WithTemperature(*o.Temperature))
+	}
+	if o.TopP != nil {
+		opts = append(opts, llms.WithTopP(*o.TopP))
+	}
+	if o.MaxTokens != nil {
+		opts = append(opts, llms.WithMaxTokens(*o.MaxTokens))
+	}
+	if len(o.Stop) > 0 {
+		opts = append(opts, llms.WithStopWords(o.Stop))
+	}
+	if o.Seed != nil {
+		opts = append(opts, llms.WithSeed(*o.Seed))
+	}
+	if o.PresencePenalty != nil {
+		opts = append(opts, llms.WithPresencePenalty(*o.PresencePenalty))
+	}
+	if o.FrequencyPenalty != nil {
+		opts = append(opts, llms.WithFrequencyPenalty(*o.FrequencyPenalty))
+	}
+	return opts
+}
@@ -0,0 +1,313 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// 推理模型（DeepSeek-R1、Qwen3 等）在回答前输出的思考过程标签
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// maxReasoningBody 非流式响应中为提取 reasoning_content 最多缓存的响应体大小
+const maxReasoningBody = 4 * 1024 * 1024
+
+// ReasoningLLM 把模型输出中 <think>…</think> 包裹的思考过程从回答中分离到 Reasoning 字段，
+// 流式输出时标签可能被拆分在多个分块中。部分部署省略开始标签，输出形如 "思考…</think>回答"，
+// 非流式响应据是否出现 </think> 自动识别，流式输出需配置 implicitThink。
+// 后端原生返回的推理内容（如 reasoning_content）由各后端直接填写
+type ReasoningLLM struct {
+	handle   Handle
+	implicit bool
+}
+
+func NewReasoningLLM(h Handle, cfg *config.LLMConfig) *ReasoningLLM {
+	return &ReasoningLLM{handle: h, implicit: cfg.ImplicitThink}
+}
+
+func (r *ReasoningLLM) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	res, err := r.handle.GenerateChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for i := range res.Choices {
+		choice := &res.Choices[i]
+		p := newThinkParser(strings.Contains(choice.Message, thinkCloseTag))
+		answer, reasoning := p.feed(choice.Message)
+		restAnswer, restReasoning := p.flush()
+		choice.Message = answer + restAnswer
+		choice.Reasoning += reasoning + restReasoning
+	}
+	return res, nil
+}
+
+func (r *ReasoningLLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-chan *StreamChunk, error) {
+	stream, err := r.handle.GenerateStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resChan := make(chan *StreamChunk, 10)
+	go func() {
+		defer close(resChan)
+		defer func() { go drain(stream) }()
+
+		send := func(chunk *StreamChunk) bool {
+			select {
+			case resChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		p := newThinkParser(r.implicit)
+		for chunk := range stream {
+			var answer, reasoning string
+			if chunk.Message != "" {
+				answer, reasoning = p.feed(chunk.Message)
+			}
+			if chunk.Done {
+				restAnswer, restReasoning := p.flush()
+				answer, reasoning = answer+restAnswer, reasoning+restReasoning
+			}
+			if reasoning != "" && !send(&StreamChunk{ID: chunk.ID, Reasoning: reasoning}) {
+				return
+			}
+			// 原分块只保留回答部分，分块上的其他字段原样保留
+			if answer != chunk.Message {
+				split := *chunk
+				split.Message = answer
+				chunk = &split
+			}
+			if !chunk.hasContent() && !chunk.Done && chunk.Error == nil {
+				continue
+			}
+			if !send(chunk) {
+				return
+			}
+		}
+	}()
+	return resChan, nil
+}
+
+func (r *ReasoningLLM) ValidateConfig() error {
+	return r.handle.ValidateConfig()
+}
+
+func (r *ReasoningLLM) SupportsTools() bool {
+	return SupportsTools(r.handle)
+}
+
+func (r *ReasoningLLM) SupportsResponseFormat() bool {
+	return SupportsResponseFormat(r.handle)
+}
+
+// hasContent 分块是否携带回答、推理内容或工具调用增量
+func (c *StreamChunk) hasContent() bool {
+	return c.Message != "" || c.Reasoning != "" || len(c.ToolCallDeltas) > 0
+}
+
+// thinkParser 增量解析 <think> 标签。只识别出现在回答开头的思考块，
+// 回答正文开始后不再解析，避免误伤正文中出现的同名文本
+type thinkParser struct {
+	inThink bool
+	// implicit 输出可能省略开始标签，开头即为思考过程，直到 </think>
+	implicit bool
+	// opened 已越过思考块开头可选的开始标签
+	opened bool
+	// answered 已输出非空白的回答内容
+	answered bool
+	// trimAnswer 思考块刚结束，去掉回答开头的空行
+	trimAnswer bool
+	// pending 可能是被拆开的标签前缀，等待后续分块确认
+	pending string
+}
+
+func newThinkParser(implicit bool) *thinkParser {
+	return &thinkParser{inThink: implicit, implicit: implicit}
+}
+
+// feed 输入一段输出，返回其中可以确定的回答与推理内容
+func (p *thinkParser) feed(text string) (answer, reasoning string) {
+	if p.answered {
+		return text, ""
+	}
+	text = p.pending + text
+	p.pending = ""
+	var a, r strings.Builder
+	for text != "" {
+		tag := thinkOpenTag
+		if p.inThink {
+			tag = thinkCloseTag
+		}
+		if !p.inThink {
+			// 开始标签之前只允许空白，否则视为普通回答
+			trimmed := strings.TrimLeft(text, " \t\r\n")
+			if trimmed == "" {
+				p.pending = text
+				break
+			}
+			if !strings.HasPrefix(trimmed, thinkOpenTag) {
+				if strings.HasPrefix(thinkOpenTag, trimmed) {
+					p.pending = text
+					break
+				}
+				p.writeAnswer(&a, text)
+				break
+			}
+			text = trimmed[len(thinkOpenTag):]
+			p.inThink, p.opened = true, true
+			continue
+		}
+		if !p.opened {
+			// 省略开始标签时，开头仍可能带有开始标签，跳过它
+			trimmed := strings.TrimLeft(text, " \t\r\n")
+			if trimmed == "" || (len(trimmed) < len(thinkOpenTag) && strings.HasPrefix(thinkOpenTag, trimmed)) {
+				p.pending = text
+				break
+			}
+			p.opened = true
+			if strings.HasPrefix(trimmed, thinkOpenTag) {
+				text = trimmed[len(thinkOpenTag):]
+			}
+			continue
+		}
+		if i := strings.Index(text, tag); i >= 0 {
+			r.WriteString(text[:i])
+			text = text[i+len(tag):]
+			p.inThink = false
+			p.trimAnswer = true
+			continue
+		}
+		keep := partialSuffix(text, tag)
+		r.WriteString(text[:len(text)-keep])
+		p.pending = text[len(text)-keep:]
+		break
+	}
+	return a.String(), r.String()
+}
+
+// flush 输出结束时调用，返回尚未确定的剩余内容
+func (p *thinkParser) flush() (answer, reasoning string) {
+	rest := p.pending
+	p.pending = ""
+	if p.inThink {
+		return "", rest
+	}
+	if p.trimAnswer {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+	}
+	return rest, ""
+}
+
+func (p *thinkParser) writeAnswer(b *strings.Builder, text string) {
+	if p.trimAnswer {
+		text = strings.TrimLeft(text, " \t\r\n")
+		if text == "" {
+			return
+		}
+		p.trimAnswer = false
+	}
+	p.answered = true
+	b.WriteString(text)
+}
+
+// partialSuffix 返回 text 末尾可能是 tag 前缀的最长长度
+func partialSuffix(text, tag string) int {
+	for n := min(len(text), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(text, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+type reasoningTapKey struct{}
+
+// withReasoningTap 经由该 ctx 发出的 OpenAI 兼容请求，响应中的 reasoning_content 会交给 onReasoning。
+// langchaingo 会丢弃 reasoning_content，因此在 Transport 层截获
+func withReasoningTap(ctx context.Context, onReasoning func(string)) context.Context {
+	return context.WithValue(ctx, reasoningTapKey{}, onReasoning)
+}
+
+// reasoningReader 在 langchaingo 读取响应体的同时解析其中的 reasoning_content：
+// SSE 流逐行解析 delta，普通响应在读完后解析 message
+type reasoningReader struct {
+	body        io.ReadCloser
+	onReasoning func(string)
+	stream      bool
+	buf         []byte
+	overflow    bool
+}
+
+type reasoningPayload struct {
+	Choices []struct {
+		Delta struct {
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"delta"`
+		Message struct {
+			ReasoningContent string `json:"reasoning_content"`
+		} `json:"message"`
+	} `json:"choices"`
+}
+
+func (r *reasoningReader) Read(p []byte) (int, error) {
+	n, err := r.body.Read(p)
+	if n > 0 {
+		r.consume(p[:n])
+	}
+	if err == io.EOF && !r.stream {
+		r.parse(r.buf)
+		r.buf = nil
+	}
+	return n, err
+}
+
+func (r *reasoningReader) Close() error {
+	return r.body.Close()
+}
+
+func (r *reasoningReader) consume(data []byte) {
+	if !r.stream {
+		if len(r.buf)+len(data) > maxReasoningBody {
+			r.overflow = true
+			r.buf = nil
+		}
+		if !r.overflow {
+			r.buf = append(r.buf, data...)
+		}
+		return
+	}
+	r.buf = append(r.buf, data...)
+	for {
+		i := bytes.IndexByte(r.buf, '\n')
+		if i < 0 {
+			return
+		}
+		line := bytes.TrimSpace(r.buf[:i])
+		r.buf = r.buf[i+1:]
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			r.parse(bytes.TrimSpace(data))
+		}
+	}
+}
+
+func (r *reasoningReader) parse(data []byte) {
+	if len(data) == 0 || data[0] != '{' {
+		return
+	}
+	var payload reasoningPayload
+	if json.Unmarshal(data, &payload) != nil || len(payload.Choices) == 0 {
+		return
+	}
+	choice := payload.Choices[0]
+	if text := choice.Delta.ReasoningContent + choice.Message.ReasoningContent; text != "" {
+		r.onReasoning(text)
+	}
+}
@@ -0,0 +1,116 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// splitEvery 把文本按 n 个字节切块，模拟标签被拆分在多个分块中
+func splitEvery(text string, n int) []string {
+	var chunks []string
+	for len(text) > n {
+		chunks = append(chunks, text[:n])
+		text = text[n:]
+	}
+	return append(chunks, text)
+}
+
+func parseChunks(implicit bool, chunks []string) (answer, reasoning string) {
+	p := newThinkParser(implicit)
+	var a, r strings.Builder
+	for _, chunk := range chunks {
+		answer, reasoning := p.feed(chunk)
+		a.WriteString(answer)
+		r.WriteString(reasoning)
+	}
+	answer, reasoning = p.flush()
+	return a.String() + answer, r.String() + reasoning
+}
+
+func TestThinkParser(t *testing.T) {
+	tests := []struct {
+		name      string
+		implicit  bool
+		text      string
+		answer    string
+		reasoning string
+	}{
+		{"no tags", false, "你好，今天星期五。", "你好，今天星期五。", ""},
+		{"think block", false, "<think>先想想</think>\n\n你好", "你好", "先想想"},
+		{"leading whitespace", false, "\n <think>想</think>答", "答", "想"},
+		{"tag after answer", false, "答案里提到 <think>不是思考</think>", "答案里提到 <think>不是思考</think>", ""},
+		{"unclosed think", false, "<think>想到一半", "", "想到一半"},
+		{"empty think", false, "<think>\n\n</think>\n\n你好", "你好", "\n\n"},
+		{"text like a tag", false, "<thin>不是标签", "<thin>不是标签", ""},
+		{"bare close tag", true, "用户在打招呼，友好回应。</think>\n\n你好呀", "你好呀", "用户在打招呼，友好回应。"},
+		{"implicit with open tag", true, "<think>想</think>答", "答", "想"},
+		{"implicit reasoning like a tag", true, "<b>重点</b></think>答", "答", "<b>重点</b>"},
+		{"implicit close tag in answer", true, "想</think>答案里的 </think> 原样保留", "答案里的 </think> 原样保留", "想"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 整段输入与按 1、2、3 字节切块输入的结果应一致
+			for _, n := range []int{len(tt.text), 1, 2, 3} {
+				answer, reasoning := parseChunks(tt.implicit, splitEvery(tt.text, n))
+				if answer != tt.answer || reasoning != tt.reasoning {
+					t.Errorf("chunk size %d: answer = %q, reasoning = %q, want %q, %q",
+						n, answer, reasoning, tt.answer, tt.reasoning)
+				}
+			}
+		})
+	}
+}
+
+func TestReasoningLLM(t *testing.T) {
+	tests := []struct {
+		name      string
+		implicit  bool
+		text      string
+		answer    string
+		reasoning string
+		// streamAsIs 流式输出无法提前判断，原样作为回答
+		streamAsIs bool
+	}{
+		{"think block", false, "<think>想</think>答", "答", "想", false},
+		{"bare close tag", false, "想</think>答", "答", "想", true},
+		{"no tags", false, "答", "答", "", false},
+		{"implicit", true, "想</think>答", "答", "想", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.LLMConfig{Provider: "mock_llm", Model: "mock-1", ImplicitThink: tt.implicit,
+				Mock: config.MockConfig{Reply: tt.text, ChunkSize: 1}}
+			r := NewReasoningLLM(newTestMock(t, cfg.Mock), cfg)
+
+			res, err := r.GenerateChat(context.Background(), userRequest("hi"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 非流式响应无需配置即可识别省略开始标签的思考块
+			if res.Choices[0].Message != tt.answer || res.Choices[0].Reasoning != tt.reasoning {
+				t.Errorf("chat: answer = %q, reasoning = %q", res.Choices[0].Message, res.Choices[0].Reasoning)
+			}
+
+			if tt.streamAsIs {
+				tt.answer, tt.reasoning = tt.text, ""
+			}
+			chunks, err := r.GenerateStream(context.Background(), userRequest("hi"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var answer, reasoning strings.Builder
+			var done bool
+			for chunk := range chunks {
+				answer.WriteString(chunk.Message)
+				reasoning.WriteString(chunk.Reasoning)
+				done = done || chunk.Done
+			}
+			if answer.String() != tt.answer || reasoning.String() != tt.reasoning || !done {
+				t.Errorf("stream: answer = %q, reasoning = %q, done = %v", answer.String(), reasoning.String(), done)
+			}
+		})
+	}
+}
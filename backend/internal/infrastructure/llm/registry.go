@@ -0,0 +1,110 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// DefaultModelName llm 配置注册到模型表时使用的名称
+const DefaultModelName = "default"
+
+// ParamModel 请求中选择模型配置的参数名
+const ParamModel = "model"
+
+// ModelInfo 模型配置的公开信息，不包含 token 等敏感字段
+type ModelInfo struct {
+	Name        string
+	Description string
+	Provider    string
+	Model       string
+	Default     bool
+	// Available 为 false 表示该配置初始化失败，暂不可用
+	Available bool
+}
+
+// Registry 按名称管理多个模型配置及其 Handle
+type Registry struct {
+	handles     map[string]Handle
+	configs     map[string]*config.LLMConfig
+	infos       []ModelInfo
+	defaultName string
+}
+
+// NewRegistry 注册 llm 配置（名为 default，provider 为空时跳过）与全部具名配置。
+// defaultName 为空时依次取 default、第一个具名配置
+func NewRegistry(defaultName string, base *config.LLMConfig, profiles []config.ModelProfile) *Registry {
+	r := &Registry{handles: make(map[string]Handle), configs: make(map[string]*config.LLMConfig)}
+	if base != nil && base.Provider != "" {
+		r.register(DefaultModelName, "", base)
+	}
+	for i := range profiles {
+		p := &profiles[i]
+		if p.Name == "" {
+			logger.Errorf("models[%d]: name is required, skipped", i)
+			continue
+		}
+		if _, ok := r.handles[p.Name]; ok {
+			logger.Errorf("models[%d]: duplicate model name %s, skipped", i, p.Name)
+			continue
+		}
+		r.register(p.Name, p.Description, &p.LLMConfig)
+	}
+	if defaultName == "" && len(r.infos) > 0 {
+		defaultName = r.infos[0].Name
+	}
+	if _, ok := r.handles[defaultName]; !ok && defaultName != "" {
+		logger.Errorf("default model %s is not configured", defaultName)
+	}
+	r.defaultName = defaultName
+	for i := range r.infos {
+		r.infos[i].Default = r.infos[i].Name == defaultName
+	}
+	return r
+}
+
+func (r *Registry) register(name, description string, cfg *config.LLMConfig) {
+	h := CreateLLM(cfg)
+	r.handles[name] = h
+	r.configs[name] = cfg
+	r.infos = append(r.infos, ModelInfo{
+		Name:        name,
+		Description: description,
+		Provider:    cfg.Provider,
+		Model:       cfg.Model,
+		Available:   h != nil,
+	})
+}
+
+// Get 按名称获取 Handle，名称为空时返回默认模型；未配置的名称返回 ValidationError
+func (r *Registry) Get(name string) (Handle, error) {
+	if name == "" {
+		if r.defaultName == "" {
+			return nil, errors.New("no llm model is configured")
+		}
+		name = r.defaultName
+	}
+	h, ok := r.handles[name]
+	if !ok {
+		return nil, &ValidationError{Param: ParamModel, Reason: fmt.Sprintf("unknown model %q", name)}
+	}
+	if h == nil {
+		return nil, fmt.Errorf("model %s is not initialized, check the llm config", name)
+	}
+	return h, nil
+}
+
+// Config 按名称获取模型配置，名称为空时返回默认模型的配置，未配置的名称返回 nil
+func (r *Registry) Config(name string) *config.LLMConfig {
+	if name == "" {
+		name = r.defaultName
+	}
+	return r.configs[name]
+}
+
+// List 按配置顺序返回全部模型配置
+func (r *Registry) List() []ModelInfo {
+	return append([]ModelInfo(nil), r.infos...)
+}
@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// chatCompletionObject ChatResponse.Object 的取值，与 OpenAI 的响应类型保持一致
+const chatCompletionObject = "chat.completion"
+
+// Content 返回第一个候选回复的文本
+func (r *ChatResponse) Content() string {
+	if r == nil || len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Message
+}
+
+// newResponseID 生成响应ID，流式响应的所有分块共用同一个ID
+func newResponseID() string {
+	return "chatcmpl-" + uuid.NewString()
+}
+
+// newChatResponse 将 langchaingo 的 ContentResponse 转换为 ChatResponse
+func newChatResponse(model string, res *llms.ContentResponse) *ChatResponse {
+	resp := &ChatResponse{
+		ID:      newResponseID(),
+		Object:  chatCompletionObject,
+		Created: time.Now(),
+		Model:   model,
+	}
+	for i, choice := range res.Choices {
+		if choice == nil {
+			continue
+		}
+		resp.Choices = append(resp.Choices, Choice{
+			Index:        i,
+			Message:      choice.Content,
+			FinishReason: choice.StopReason,
+			ToolCalls:    toolCallsFromLLM(choice.ToolCalls),
+		})
+		// 各候选回复共享同一份用量统计，取第一份即可
+		if resp.Usage.TotalTokens == 0 {
+			resp.Usage = usageFromGenerationInfo(choice.GenerationInfo)
+		}
+	}
+	return resp
+}
+
+// newFinalChunk 构造流式响应的结束块，携带停止原因与用量
+func newFinalChunk(id string, res *llms.ContentResponse) *StreamChunk {
+	chunk := &StreamChunk{ID: id, Done: true}
+	if res == nil || len(res.Choices) == 0 || res.Choices[0] == nil {
+		return chunk
+	}
+	usage := usageFromGenerationInfo(res.Choices[0].GenerationInfo)
+	chunk.FinishReason = res.Choices[0].StopReason
+	chunk.Usage = &usage
+	chunk.ToolCalls = toolCallsFromLLM(res.Choices[0].ToolCalls)
+	return chunk
+}
+
+// usageFromGenerationInfo 从 langchaingo 返回的 GenerationInfo 中提取 token 用量，
+// 兼容 OpenAI/Ollama（PromptTokens/CompletionTokens）与 Anthropic（InputTokens/OutputTokens）两种命名
+func usageFromGenerationInfo(info map[string]any) Usage {
+	var usage Usage
+	if info == nil {
+		return usage
+	}
+	usage.PromptTokens = intFromAny(info["PromptTokens"])
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = intFromAny(info["InputTokens"])
+	}
+	usage.CompletionTokens = intFromAny(info["CompletionTokens"])
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = intFromAny(info["OutputTokens"])
+	}
+	usage.TotalTokens = intFromAny(info["TotalTokens"])
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage
+}
+
+func intFromAny(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case float32:
+		return int(n)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,324 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+const (
+	defaultInitialBackoff  = 500 * time.Millisecond
+	defaultMaxBackoff      = 10 * time.Second
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen 后端连续失败触发熔断，熔断期间的请求直接被拒绝
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// UpstreamError 调用上游模型服务失败，StatusCode 为 0 表示没有拿到 HTTP 响应
+type UpstreamError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration
+	Retryable  bool
+	Err        error
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// statusPattern 从 langchaingo 与补全服务的错误信息中提取状态码，
+// 如 "API returned unexpected status code: 429" 与 "completion server returned status 503"
+var statusPattern = regexp.MustCompile(`status(?: code)?:? (\d{3})`)
+
+// classifyError 将错误归类为可重试（限流、5xx、网络错误、超时）或不可重试
+func classifyError(provider string, err error, hint *responseHint) *UpstreamError {
+	var upstreamErr *UpstreamError
+	if errors.As(err, &upstreamErr) {
+		return upstreamErr
+	}
+	upstreamErr = &UpstreamError{Provider: provider, Err: err}
+	if hint != nil {
+		if code, retryAfter := hint.get(); code >= 400 {
+			upstreamErr.StatusCode, upstreamErr.RetryAfter = code, retryAfter
+		}
+	}
+	if upstreamErr.StatusCode == 0 {
+		if m := statusPattern.FindStringSubmatch(err.Error()); m != nil {
+			upstreamErr.StatusCode, _ = strconv.Atoi(m[1])
+		}
+	}
+	var validationErr *ValidationError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &validationErr), errors.Is(err, context.Canceled):
+	case upstreamErr.StatusCode != 0:
+		upstreamErr.Retryable = retryableStatus(upstreamErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET):
+		upstreamErr.Retryable = true
+	}
+	return upstreamErr
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	// 529 为 Anthropic 的过载状态码
+	case 408, 409, 425, 429, 500, 502, 503, 504, 529:
+		return true
+	}
+	return false
+}
+
+// circuitBreaker 连续失败达到阈值后熔断，冷却结束后放行一个试探请求，成功则恢复
+type circuitBreaker struct {
+	mu        sync.Mutex
+	name      string
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return nil
+	}
+	if b.probing || time.Now().Before(b.openUntil) {
+		return fmt.Errorf("%s: %w", b.name, ErrCircuitOpen)
+	}
+	b.probing = true
+	return nil
+}
+
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures >= b.threshold {
+		logger.WithField("backend", b.name).Info("circuit breaker closed")
+	}
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *circuitBreaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+		logger.WithFields(map[string]interface{}{
+			"backend":  b.name,
+			"failures": b.failures,
+			"cooldown": b.cooldown.String(),
+		}).Warn("circuit breaker opened")
+	}
+}
+
+// release 请求未到达上游（如参数校验失败），归还试探名额
+func (b *circuitBreaker) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// RetryLLM 为后端包装重试与熔断：可重试的错误按带抖动的指数退避重试，
+// 上游返回 Retry-After 时至少等待该时长，超过 maxBackoff 则不再重试；流式请求只在首个分块之前重试
+type RetryLLM struct {
+	handle         Handle
+	name           string
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	breaker        *circuitBreaker
+}
+
+func NewRetryLLM(h Handle, cfg *config.LLMConfig) *RetryLLM {
+	r := &RetryLLM{
+		handle:         h,
+		name:           fmt.Sprintf("%s(%s)", cfg.Provider, cfg.Model),
+		maxAttempts:    max(cfg.Retry.MaxAttempts, 1),
+		initialBackoff: cfg.Retry.InitialBackoff,
+		maxBackoff:     cfg.Retry.MaxBackoff,
+	}
+	if r.initialBackoff <= 0 {
+		r.initialBackoff = defaultInitialBackoff
+	}
+	if r.maxBackoff <= 0 {
+		r.maxBackoff = defaultMaxBackoff
+	}
+	if cfg.Retry.BreakerThreshold > 0 {
+		r.breaker = &circuitBreaker{
+			name:      r.name,
+			threshold: cfg.Retry.BreakerThreshold,
+			cooldown:  cfg.Retry.BreakerCooldown,
+		}
+		if r.breaker.cooldown <= 0 {
+			r.breaker.cooldown = defaultBreakerCooldown
+		}
+	}
+	return r
+}
+
+func (r *RetryLLM) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	for attempt := 1; ; attempt++ {
+		if err := r.breaker.allow(); err != nil {
+			return nil, err
+		}
+		attemptCtx, hint := withResponseHint(ctx)
+		res, err := r.handle.GenerateChat(attemptCtx, req)
+		if err == nil {
+			r.breaker.success()
+			return res, nil
+		}
+		if err := r.retry(ctx, attempt, err, hint); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *RetryLLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-chan *StreamChunk, error) {
+	for attempt := 1; ; attempt++ {
+		if err := r.breaker.allow(); err != nil {
+			return nil, err
+		}
+		attemptCtx, cancel := context.WithCancel(ctx)
+		attemptCtx, hint := withResponseHint(attemptCtx)
+		stream, err := r.handle.GenerateStream(attemptCtx, req)
+		if err == nil {
+			var buffered []*StreamChunk
+			buffered, err = leadingChunks(attemptCtx, stream)
+			if err == nil {
+				r.breaker.success()
+				return pipeStream(ctx, cancel, buffered, stream, func(chunk *StreamChunk) {
+					// 首块之后的错误不再重试，但仍计入熔断统计
+					if chunk.Error != nil && ctx.Err() == nil && classifyError(r.name, chunk.Error, hint).Retryable {
+						r.breaker.failure()
+					}
+				}), nil
+			}
+			go drain(stream)
+		}
+		cancel()
+		if err := r.retry(ctx, attempt, err, hint); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *RetryLLM) ValidateConfig() error {
+	return r.handle.ValidateConfig()
+}
+
+func (r *RetryLLM) SupportsTools() bool {
+	return SupportsTools(r.handle)
+}
+
+func (r *RetryLLM) SupportsResponseFormat() bool {
+	return SupportsResponseFormat(r.handle)
+}
+
+// retry 判断失败后是否继续重试：需要重试时完成退避等待并返回 nil，否则返回最终错误
+func (r *RetryLLM) retry(ctx context.Context, attempt int, err error, hint *responseHint) error {
+	if ctx.Err() != nil {
+		r.breaker.release()
+		return err
+	}
+	upstreamErr := classifyError(r.name, err, hint)
+	switch {
+	case upstreamErr.Retryable:
+		r.breaker.failure()
+	case upstreamErr.StatusCode != 0:
+		// 上游给出了明确的业务错误，说明服务本身可用
+		r.breaker.success()
+	default:
+		r.breaker.release()
+	}
+	if !upstreamErr.Retryable || attempt >= r.maxAttempts {
+		return upstreamErr
+	}
+	// 上游要求等待的时间超过退避上限时不再重试，把带有 Retry-After 的错误直接交给调用方
+	if upstreamErr.RetryAfter > r.maxBackoff {
+		return upstreamErr
+	}
+	wait := r.backoff(attempt, upstreamErr.RetryAfter)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		return upstreamErr
+	}
+	logger.WithFields(map[string]interface{}{
+		"backend": r.name,
+		"attempt": attempt,
+		"status":  upstreamErr.StatusCode,
+		"wait":    wait.String(),
+	}).Warn("llm request failed, retrying: " + err.Error())
+	if sleepContext(ctx, wait) != nil {
+		return upstreamErr
+	}
+	return nil
+}
+
+// backoff 第 n 次重试前的等待时间：上限按指数增长，在 [上限/2, 上限) 之间随机抖动，
+// 上游要求的 Retry-After 更长时以其为准，但不超过 maxBackoff
+func (r *RetryLLM) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	ceiling := r.maxBackoff
+	if shift := attempt - 1; shift < 30 {
+		ceiling = min(r.initialBackoff<<shift, r.maxBackoff)
+	}
+	wait := ceiling/2 + rand.N(ceiling/2+1)
+	return min(max(wait, retryAfter), r.maxBackoff)
+}
+
+// leadingChunks 读取流开头的分块直到出现内容或结束；内容出现之前的错误会返回以便重试
+func leadingChunks(ctx context.Context, stream <-chan *StreamChunk) ([]*StreamChunk, error) {
+	var buffered []*StreamChunk
+	for {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				return buffered, nil
+			}
+			if chunk.Error != nil {
+				return nil, chunk.Error
+			}
+			buffered = append(buffered, chunk)
+			if chunk.hasContent() || chunk.Done {
+				return buffered, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// attemptResult flakyHandle 单次调用的结果：err 不为空时直接返回错误，否则按 chunks 输出
+type attemptResult struct {
+	err    error
+	chunks []*StreamChunk
+}
+
+// flakyHandle 按顺序返回预设结果的后端，调用次数超出时重复最后一个结果
+type flakyHandle struct {
+	attempts []attemptResult
+	calls    int
+}
+
+func (h *flakyHandle) next() attemptResult {
+	a := h.attempts[min(h.calls, len(h.attempts)-1)]
+	h.calls++
+	return a
+}
+
+func (h *flakyHandle) GenerateChat(context.Context, *ChatRequest) (*ChatResponse, error) {
+	a := h.next()
+	if a.err != nil {
+		return nil, a.err
+	}
+	return &ChatResponse{Choices: []Choice{{Message: "ok", FinishReason: "stop"}}}, nil
+}
+
+func (h *flakyHandle) GenerateStream(ctx context.Context, _ *ChatRequest) (<-chan *StreamChunk, error) {
+	a := h.next()
+	if a.err != nil {
+		return nil, a.err
+	}
+	ch := make(chan *StreamChunk)
+	go func() {
+		defer close(ch)
+		for _, chunk := range a.chunks {
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (h *flakyHandle) ValidateConfig() error { return nil }
+
+func newTestRetry(h Handle, threshold int) *RetryLLM {
+	r := &RetryLLM{
+		handle:         h,
+		name:           "test",
+		maxAttempts:    3,
+		initialBackoff: time.Millisecond,
+		maxBackoff:     5 * time.Millisecond,
+	}
+	if threshold > 0 {
+		r.breaker = &circuitBreaker{name: "test", threshold: threshold, cooldown: time.Hour}
+	}
+	return r
+}
+
+func overloaded() error {
+	return &UpstreamError{Provider: "test", StatusCode: 503, Retryable: true, Err: errors.New("overloaded")}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		hint       *responseHint
+		status     int
+		retryAfter time.Duration
+		retryable  bool
+	}{
+		{"validation", &ValidationError{Param: ParamTemperature, Reason: "bad"}, nil, 0, 0, false},
+		{"canceled", context.Canceled, nil, 0, 0, false},
+		{"deadline", context.DeadlineExceeded, nil, 0, 0, true},
+		{"unexpected eof", fmt.Errorf("read body: %w", io.ErrUnexpectedEOF), nil, 0, 0, true},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), nil, 0, 0, true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), nil, 0, 0, true},
+		{"rate limit in message", errors.New("API returned unexpected status code: 429"), nil, 429, 0, true},
+		{"server error in message", errors.New("completion server returned status 503"), nil, 503, 0, true},
+		{"client error in message", errors.New("API returned unexpected status code: 400"), nil, 400, 0, false},
+		{"status from hint", errors.New("request failed"), &responseHint{statusCode: 529, retryAfter: 2 * time.Second}, 529, 2 * time.Second, true},
+		{"successful hint ignored", errors.New("decode response"), &responseHint{statusCode: 200}, 0, 0, false},
+		{"unknown", errors.New("boom"), nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := classifyError("test", tt.err, tt.hint)
+			if got.StatusCode != tt.status || got.RetryAfter != tt.retryAfter || got.Retryable != tt.retryable {
+				t.Errorf("classifyError = {status %d, retryAfter %v, retryable %v}, want {%d, %v, %v}",
+					got.StatusCode, got.RetryAfter, got.Retryable, tt.status, tt.retryAfter, tt.retryable)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Errorf("classifyError does not wrap %v", tt.err)
+			}
+		})
+	}
+
+	// 已经归类过的错误原样返回
+	upstreamErr := &UpstreamError{StatusCode: 418}
+	if got := classifyError("test", fmt.Errorf("wrapped: %w", upstreamErr), nil); got != upstreamErr {
+		t.Errorf("classifyError = %+v, want the wrapped UpstreamError", got)
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want bool
+	}{
+		{400, false}, {401, false}, {403, false}, {404, false}, {422, false},
+		{408, true}, {409, true}, {425, true}, {429, true},
+		{500, true}, {501, false}, {502, true}, {503, true}, {504, true}, {529, true},
+	}
+	for _, tt := range tests {
+		if got := retryableStatus(tt.code); got != tt.want {
+			t.Errorf("retryableStatus(%d) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	b := &circuitBreaker{name: "test", threshold: 2, cooldown: time.Hour}
+	expire := func() { b.openUntil = time.Now().Add(-time.Second) }
+
+	b.failure()
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow below threshold: %v", err)
+	}
+	b.failure()
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after threshold: err = %v, want ErrCircuitOpen", err)
+	}
+
+	// 冷却结束后只放行一个试探请求
+	expire()
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request while probing: err = %v, want ErrCircuitOpen", err)
+	}
+
+	// 试探失败重新熔断
+	b.failure()
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow after failed probe: err = %v, want ErrCircuitOpen", err)
+	}
+
+	// 试探未到达上游时归还名额
+	expire()
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	b.release()
+	if err := b.allow(); err != nil {
+		t.Fatalf("probe after release: %v", err)
+	}
+
+	// 试探成功后恢复
+	b.success()
+	for range 3 {
+		if err := b.allow(); err != nil {
+			t.Fatalf("allow after close: %v", err)
+		}
+	}
+
+	var nilBreaker *circuitBreaker
+	if err := nilBreaker.allow(); err != nil {
+		t.Errorf("nil breaker: %v", err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	r := &RetryLLM{initialBackoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	tests := []struct {
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{1, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{2, 0, 100 * time.Millisecond, 200 * time.Millisecond},
+		{4, 0, 400 * time.Millisecond, 800 * time.Millisecond},
+		{10, 0, 500 * time.Millisecond, time.Second},
+		{100, 0, 500 * time.Millisecond, time.Second},
+		{1, 700 * time.Millisecond, 700 * time.Millisecond, 700 * time.Millisecond},
+		{1, time.Hour, time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			if got := r.backoff(tt.attempt, tt.retryAfter); got < tt.min || got > tt.max {
+				t.Errorf("backoff(%d, %v) = %v, want in [%v, %v]", tt.attempt, tt.retryAfter, got, tt.min, tt.max)
+			}
+		}
+	}
+}
+
+func TestRetryChat(t *testing.T) {
+	tests := []struct {
+		name      string
+		attempts  []attemptResult
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", []attemptResult{{}}, 1, false},
+		{"retry then success", []attemptResult{{err: overloaded()}, {err: overloaded()}, {}}, 3, false},
+		{"gives up after max attempts", []attemptResult{{err: overloaded()}}, 3, true},
+		{"client error is not retried", []attemptResult{{err: errors.New("API returned unexpected status code: 400")}, {}}, 1, true},
+		// Retry-After 超过退避上限时立即返回，而不是长时间占住请求
+		{"retry after beyond max backoff", []attemptResult{
+			{err: &UpstreamError{StatusCode: 429, RetryAfter: time.Minute, Retryable: true, Err: errors.New("rate limited")}},
+			{},
+		}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &flakyHandle{attempts: tt.attempts}
+			start := time.Now()
+			res, err := newTestRetry(h, 0).GenerateChat(context.Background(), &ChatRequest{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && res.Choices[0].Message != "ok" {
+				t.Errorf("response = %+v", res)
+			}
+			if h.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", h.calls, tt.wantCalls)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestRetryBreakerRejectsWhenOpen(t *testing.T) {
+	h := &flakyHandle{attempts: []attemptResult{{err: overloaded()}}}
+	r := newTestRetry(h, 2)
+	// 第二次失败后熔断，同一请求的第三次尝试与之后的请求都被直接拒绝
+	if _, err := r.GenerateChat(context.Background(), &ChatRequest{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("first request: err = %v, want ErrCircuitOpen", err)
+	}
+	if _, err := r.GenerateChat(context.Background(), &ChatRequest{}); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second request: err = %v, want ErrCircuitOpen", err)
+	}
+	if h.calls != 2 {
+		t.Errorf("calls = %d, want 2", h.calls)
+	}
+}
+
+func TestRetryStream(t *testing.T) {
+	content := []*StreamChunk{{ID: "ok"}, {ID: "ok", Message: "你好"}, {ID: "ok", Done: true, FinishReason: "stop"}}
+	tests := []struct {
+		name      string
+		attempts  []attemptResult
+		wantText  string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"open error then success", []attemptResult{{err: overloaded()}, {chunks: content}}, "你好", false, 2},
+		{"error before content then success", []attemptResult{
+			{chunks: []*StreamChunk{{ID: "x"}, {Error: errors.New("API returned unexpected status code: 503"), Done: true}}},
+			{chunks: content},
+		}, "你好", false, 2},
+		// 首块之后的错误不再重试，原样转发给调用方
+		{"error after content", []attemptResult{
+			{chunks: []*StreamChunk{{ID: "x", Message: "一半"}, {Error: overloaded(), Done: true}}},
+			{chunks: content},
+		}, "一半", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &flakyHandle{attempts: tt.attempts}
+			stream, err := newTestRetry(h, 0).GenerateStream(context.Background(), &ChatRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var text strings.Builder
+			var streamErr error
+			for chunk := range stream {
+				text.WriteString(chunk.Message)
+				if chunk.Error != nil {
+					streamErr = chunk.Error
+				}
+			}
+			if text.String() != tt.wantText || (streamErr != nil) != tt.wantErr {
+				t.Errorf("text = %q, err = %v", text.String(), streamErr)
+			}
+			if h.calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", h.calls, tt.wantCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+const defaultCompletionPath = "/completion"
+
+// StatelessLLM 对接 llama.cpp、KoboldCpp 等只提供原始补全接口的推理服务，
+// 对话由 ChatTemplate 渲染为 prompt 后发送
+type StatelessLLM struct {
+	client         *http.Client
+	baseUrl        string
+	completionPath string
+	streamPath     string
+	token          string
+	model          string
+	template       *ChatTemplate
+	provider       string
+	defaults       GenerateOptions
+}
+
+func NewStatelessLLM(cfg *config.LLMConfig) *StatelessLLM {
+	tpl, err := GetChatTemplate(cfg.Template)
+	if err != nil {
+		logger.Errorf("new stateless llm error:%s", err.Error())
+		return nil
+	}
+	completionPath := cfg.CompletionPath
+	if completionPath == "" {
+		completionPath = defaultCompletionPath
+	}
+	streamPath := cfg.StreamPath
+	if streamPath == "" {
+		streamPath = completionPath
+	}
+	return &StatelessLLM{
+		client:         newHTTPClient(),
+		baseUrl:        strings.TrimRight(cfg.BaseUrl, "/"),
+		completionPath: completionPath,
+		streamPath:     streamPath,
+		token:          cfg.Token,
+		model:          cfg.Model,
+		template:       tpl,
+		provider:       cfg.Provider,
+		defaults:       defaultOptions(cfg),
+	}
+}
+
+// completionRequest 同时带上 llama.cpp 与 KoboldCpp 的字段，服务端会忽略不认识的部分
+type completionRequest struct {
+	Prompt           string   `json:"prompt"`
+	Stream           bool     `json:"stream"`
+	Stop             []string `json:"stop,omitempty"`
+	StopSequence     []string `json:"stop_sequence,omitempty"`
+	CachePrompt      bool     `json:"cache_prompt,omitempty"`
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	NPredict         *int     `json:"n_predict,omitempty"`
+	MaxLength        *int     `json:"max_length,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+// completionResponse 兼容 llama.cpp（content/stop）与 KoboldCpp（results/token）的返回格式
+type completionResponse struct {
+	Content         string `json:"content"`
+	Token           string `json:"token"`
+	Stop            bool   `json:"stop"`
+	StopType        string `json:"stop_type"`
+	StoppedEOS      bool   `json:"stopped_eos"`
+	StoppedWord     bool   `json:"stopped_word"`
+	StoppedLimit    bool   `json:"stopped_limit"`
+	FinishReason    string `json:"finish_reason"`
+	Model           string `json:"model"`
+	TokensEvaluated int    `json:"tokens_evaluated"`
+	TokensPredicted int    `json:"tokens_predicted"`
+	Results         []struct {
+		Text         string `json:"text"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"results"`
+	Error json.RawMessage `json:"error"`
+}
+
+// text 返回本次响应（或流式增量）中的文本
+func (r *completionResponse) text() string {
+	if r.Content != "" {
+		return r.Content
+	}
+	if r.Token != "" {
+		return r.Token
+	}
+	var sb strings.Builder
+	for _, res := range r.Results {
+		sb.WriteString(res.Text)
+	}
+	return sb.String()
+}
+
+// finishReason 统一转换为 OpenAI 风格的停止原因
+func (r *completionResponse) finishReason() string {
+	switch {
+	case r.FinishReason != "":
+		return r.FinishReason
+	case len(r.Results) > 0 && r.Results[0].FinishReason != "":
+		return r.Results[0].FinishReason
+	case r.StopType == "limit" || r.StoppedLimit:
+		return "length"
+	case r.StopType == "eos" || r.StopType == "word" || r.StoppedEOS || r.StoppedWord:
+		return "stop"
+	case r.Stop:
+		return "stop"
+	default:
+		return ""
+	}
+}
+
+func (r *completionResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.TokensEvaluated,
+		CompletionTokens: r.TokensPredicted,
+		TotalTokens:      r.TokensEvaluated + r.TokensPredicted,
+	}
+}
+
+func (r *completionResponse) err() error {
+	if len(r.Error) == 0 || string(r.Error) == "null" {
+		return nil
+	}
+	return fmt.Errorf("completion server error: %s", string(r.Error))
+}
+
+func (o *StatelessLLM) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	body, err := o.buildRequest(req, false)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.post(ctx, o.completionPath, body)
+	if err != nil {
+		logger.Errorf("stateless llm generate chat error : %s", err.Error())
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var res completionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode completion response: %w", err)
+	}
+	if err := res.err(); err != nil {
+		return nil, err
+	}
+	text := res.text()
+	model := res.Model
+	if model == "" {
+		model = o.model
+	}
+	return &ChatResponse{
+		ID:      newResponseID(),
+		Object:  chatCompletionObject,
+		Created: time.Now(),
+		Model:   model,
+		Choices: []Choice{{
+			Message:      text,
+			FinishReason: res.finishReason(),
+		}},
+		Usage: res.usage(),
+	}, nil
+}
+
+func (o *StatelessLLM) GenerateStream(ctx context.Context, req *ChatRequest) (<-chan *StreamChunk, error) {
+	body, err := o.buildRequest(req, true)
+	if err != nil {
+		return nil, err
+	}
+	resChan := make(chan *StreamChunk, 10)
+	go func() {
+		defer close(resChan)
+
+		id := newResponseID()
+		send := func(chunk *StreamChunk) bool {
+			chunk.ID = id
+			select {
+			case resChan <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		resp, err := o.post(ctx, o.streamPath, body)
+		if err != nil {
+			logger.Errorf("stateless llm generate stream error : %s", err.Error())
+			send(&StreamChunk{Error: err, Done: true})
+			return
+		}
+		defer resp.Body.Close()
+
+		finish, err := parseCompletionStream(resp.Body, func(res *completionResponse) bool {
+			if text := res.text(); text != "" {
+				return send(&StreamChunk{Message: text})
+			}
+			return true
+		})
+		if err != nil {
+			logger.Errorf("stateless llm read stream error : %s", err.Error())
+			send(&StreamChunk{Error: err, Done: true})
+			return
+		}
+		usage := finish.usage()
+		send(&StreamChunk{
+			Done:         true,
+			FinishReason: finish.finishReason(),
+			Usage:        &usage,
+		})
+	}()
+	return resChan, nil
+}
+
+func (o *StatelessLLM) ValidateConfig() error {
+	if o.baseUrl == "" {
+		return errors.New("stateless llm requires baseUrl")
+	}
+	if o.template == nil {
+		return errors.New("stateless llm requires a chat template")
+	}
+	return o.defaults.validate(o.provider, statelessParams)
+}
+
+// statelessParams 原始补全接口支持的生成参数
+var statelessParams = allParams
+
+func (o *StatelessLLM) buildRequest(req *ChatRequest, stream bool) ([]byte, error) {
+	opts := req.Options.withDefaults(o.defaults)
+	if err := validateTools(o.provider, req.Tools, false); err != nil {
+		return nil, err
+	}
+	if err := validateResponseFormat(o.provider, req.ResponseFormat, false); err != nil {
+		return nil, err
+	}
+	if err := opts.validate(o.provider, statelessParams); err != nil {
+		return nil, err
+	}
+	prompt, err := o.template.Render(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	// 用户的停止词与模板自身的结束标记合并
+	stop := append(slices.Clone(o.template.StopWords), opts.Stop...)
+	return json.Marshal(&completionRequest{
+		Prompt:           prompt,
+		Stream:           stream,
+		Stop:             stop,
+		StopSequence:     stop,
+		CachePrompt:      true,
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		NPredict:         opts.MaxTokens,
+		MaxLength:        opts.MaxTokens,
+		Seed:             opts.Seed,
+		PresencePenalty:  opts.PresencePenalty,
+		FrequencyPenalty: opts.FrequencyPenalty,
+	})
+}
+
+func (o *StatelessLLM) post(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseUrl+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.token)
+	}
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("completion server returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// parseCompletionStream 解析 SSE 形式的流式补全响应，每个增量回调一次 onChunk，
+// 返回最后一条数据（llama.cpp 在其中附带停止原因与 token 统计）
+func parseCompletionStream(r io.Reader, onChunk func(*completionResponse) bool) (*completionResponse, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	last := &completionResponse{}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			// 忽略空行、event: 行与注释
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			break
+		}
+		var res completionResponse
+		if err := json.Unmarshal([]byte(data), &res); err != nil {
+			return nil, fmt.Errorf("decode stream chunk: %w", err)
+		}
+		if err := res.err(); err != nil {
+			return nil, err
+		}
+		last = &res
+		if !onChunk(&res) {
+			return last, context.Canceled
+		}
+		if res.Stop {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return last, nil
+}
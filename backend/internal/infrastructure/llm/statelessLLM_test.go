@@ -0,0 +1,210 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+var templateConversation = []Message{
+	{Role: RoleSystem, Content: "你是助手"},
+	{Role: RoleUser, Content: "你好"},
+	{Role: RoleAssistant, Content: "在的"},
+	{Role: RoleTool, Content: `{"time":"09:00"}`},
+	{Role: RoleUser, Content: "几点了"},
+}
+
+func TestChatTemplateRender(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"chatml", "<|im_start|>system\n你是助手<|im_end|>\n" +
+			"<|im_start|>user\n你好<|im_end|>\n" +
+			"<|im_start|>assistant\n在的<|im_end|>\n" +
+			"<|im_start|>tool\n{\"time\":\"09:00\"}<|im_end|>\n" +
+			"<|im_start|>user\n几点了<|im_end|>\n" +
+			"<|im_start|>assistant\n"},
+		{"llama-3", "<|begin_of_text|>" +
+			"<|start_header_id|>system<|end_header_id|>\n\n你是助手<|eot_id|>" +
+			"<|start_header_id|>user<|end_header_id|>\n\n你好<|eot_id|>" +
+			"<|start_header_id|>assistant<|end_header_id|>\n\n在的<|eot_id|>" +
+			"<|start_header_id|>ipython<|end_header_id|>\n\n{\"time\":\"09:00\"}<|eot_id|>" +
+			"<|start_header_id|>user<|end_header_id|>\n\n几点了<|eot_id|>" +
+			"<|start_header_id|>assistant<|end_header_id|>\n\n"},
+		{"Qwen2.5", "<|im_start|>system\n你是助手<|im_end|>\n" +
+			"<|im_start|>user\n你好<|im_end|>\n" +
+			"<|im_start|>assistant\n在的<|im_end|>\n" +
+			"<|im_start|>user\n<tool_response>\n{\"time\":\"09:00\"}\n</tool_response><|im_end|>\n" +
+			"<|im_start|>user\n几点了<|im_end|>\n" +
+			"<|im_start|>assistant\n"},
+		{"alpaca", "你是助手\n\n" +
+			"### Instruction:\n你好\n\n" +
+			"### Response:\n在的\n\n" +
+			"### Input:\n{\"time\":\"09:00\"}\n\n" +
+			"### Instruction:\n几点了\n\n" +
+			"### Response:\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := GetChatTemplate(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tpl.Render(templateConversation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Render =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	if tpl, err := GetChatTemplate(""); err != nil || tpl.Name != "chatml" {
+		t.Errorf("default template = %v, %v", tpl, err)
+	}
+	if _, err := GetChatTemplate("vicuna"); err == nil {
+		t.Error("unknown template: want error")
+	}
+	tpl, _ := GetChatTemplate("chatml")
+	if _, err := tpl.Render([]Message{{Role: "narrator", Content: "x"}}); err == nil {
+		t.Error("unknown role: want error")
+	}
+}
+
+// completionStub 模拟 llama.cpp 的 /completion 接口，记录最近一次请求体
+type completionStub struct {
+	lastBody completionRequest
+	status   int
+}
+
+func (s *completionStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != defaultCompletionPath {
+		http.NotFound(w, r)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&s.lastBody); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.status != 0 {
+		http.Error(w, "busy", s.status)
+		return
+	}
+	if !s.lastBody.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"content":"九点了","stop":true,"stop_type":"limit","tokens_evaluated":20,"tokens_predicted":3}`)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, data := range []string{
+		`{"content":"九","stop":false}`,
+		`{"content":"点了","stop":false}`,
+		`{"content":"","stop":true,"stop_type":"eos","tokens_evaluated":20,"tokens_predicted":2}`,
+	} {
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}
+}
+
+func newTestStateless(t *testing.T, stub *completionStub) *StatelessLLM {
+	t.Helper()
+	srv := httptest.NewServer(stub)
+	t.Cleanup(srv.Close)
+	llm := NewStatelessLLM(&config.LLMConfig{Provider: "llamacpp", Model: "local", BaseUrl: srv.URL + "/", Template: "chatml"})
+	if llm == nil {
+		t.Fatal("NewStatelessLLM returned nil")
+	}
+	return llm
+}
+
+func TestStatelessGenerateChat(t *testing.T) {
+	stub := &completionStub{}
+	llm := newTestStateless(t, stub)
+	maxTokens := 64
+	res, err := llm.GenerateChat(context.Background(), &ChatRequest{
+		Messages: templateConversation,
+		Options:  GenerateOptions{MaxTokens: &maxTokens, Stop: []string{"\n\n"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content() != "九点了" || res.Model != "local" || res.Choices[0].FinishReason != "length" {
+		t.Errorf("response = %+v", res)
+	}
+	if res.Usage != (Usage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23}) {
+		t.Errorf("usage = %+v", res.Usage)
+	}
+
+	// prompt 由模板渲染，模板的结束标记与请求的停止词一起发送
+	body := stub.lastBody
+	want, _ := chatTemplates["chatml"].Render(templateConversation)
+	if body.Prompt != want || body.Stream {
+		t.Errorf("prompt = %q, stream = %v", body.Prompt, body.Stream)
+	}
+	if !slices.Equal(body.Stop, []string{"<|im_end|>", "<|im_start|>", "\n\n"}) || !slices.Equal(body.StopSequence, body.Stop) {
+		t.Errorf("stop = %v, stop_sequence = %v", body.Stop, body.StopSequence)
+	}
+	if body.NPredict == nil || *body.NPredict != 64 || body.MaxLength == nil || *body.MaxLength != 64 {
+		t.Errorf("n_predict = %v, max_length = %v", body.NPredict, body.MaxLength)
+	}
+}
+
+func TestStatelessGenerateStream(t *testing.T) {
+	stub := &completionStub{}
+	llm := newTestStateless(t, stub)
+	chunks, err := llm.GenerateStream(context.Background(), &ChatRequest{Messages: templateConversation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	var last *StreamChunk
+	for chunk := range chunks {
+		if chunk.Error != nil {
+			t.Fatal(chunk.Error)
+		}
+		text.WriteString(chunk.Message)
+		last = chunk
+	}
+	if text.String() != "九点了" || !stub.lastBody.Stream {
+		t.Errorf("streamed text = %q, stream = %v", text.String(), stub.lastBody.Stream)
+	}
+	if last == nil || !last.Done || last.FinishReason != "stop" {
+		t.Fatalf("last chunk = %+v", last)
+	}
+	if last.Usage == nil || *last.Usage != (Usage{PromptTokens: 20, CompletionTokens: 2, TotalTokens: 22}) {
+		t.Errorf("usage = %+v", last.Usage)
+	}
+}
+
+func TestStatelessUpstreamError(t *testing.T) {
+	stub := &completionStub{status: http.StatusServiceUnavailable}
+	llm := newTestStateless(t, stub)
+	_, err := llm.GenerateChat(context.Background(), &ChatRequest{Messages: templateConversation})
+	if err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Fatalf("err = %v", err)
+	}
+	// 错误信息中的状态码可被重试逻辑识别
+	if upstreamErr := classifyError("llamacpp", err, nil); upstreamErr.StatusCode != 503 || !upstreamErr.Retryable {
+		t.Errorf("classifyError = %+v", upstreamErr)
+	}
+
+	chunks, err := llm.GenerateStream(context.Background(), &ChatRequest{Messages: templateConversation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last *StreamChunk
+	for chunk := range chunks {
+		last = chunk
+	}
+	if last == nil || last.Error == nil || !last.Done {
+		t.Errorf("last chunk = %+v, want error", last)
+	}
+}
@@ -0,0 +1,54 @@
+package llm
+
+import "context"
+
+// pipeStream 先输出已缓冲的分块，再转发 stream 中剩余的分块；onChunk 可为 nil。
+// 调用方 ctx 结束时停止转发，结束后调用 cancel 释放上游
+func pipeStream(ctx context.Context, cancel context.CancelFunc, buffered []*StreamChunk,
+	stream <-chan *StreamChunk, onChunk func(*StreamChunk)) <-chan *StreamChunk {
+	resChan := make(chan *StreamChunk, 10)
+	go func() {
+		defer close(resChan)
+		defer cancel()
+
+		send := func(chunk *StreamChunk) bool {
+			select {
+			case resChan <- chunk:
+			case <-ctx.Done():
+				return false
+			}
+			if onChunk != nil {
+				onChunk(chunk)
+			}
+			return true
+		}
+		for _, chunk := range buffered {
+			if !send(chunk) {
+				go drain(stream)
+				return
+			}
+		}
+		for {
+			select {
+			case chunk, ok := <-stream:
+				if !ok {
+					return
+				}
+				if !send(chunk) {
+					go drain(stream)
+					return
+				}
+			case <-ctx.Done():
+				go drain(stream)
+				return
+			}
+		}
+	}()
+	return resChan
+}
+
+// drain 丢弃已放弃的流中剩余的分块，避免后端 goroutine 阻塞
+func drain(stream <-chan *StreamChunk) {
+	for range stream {
+	}
+}
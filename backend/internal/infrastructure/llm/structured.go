@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ParamResponseFormat 输出格式在参数校验中的名称
+const ParamResponseFormat = "responseFormat"
+
+// 输出格式类型
+const (
+	// FormatJSONObject 只要求输出合法的 JSON 对象
+	FormatJSONObject = "json_object"
+	// FormatJSONSchema 要求输出符合 Schema 的 JSON
+	FormatJSONSchema = "json_schema"
+)
+
+// defaultRepairAttempts 输出不合法时要求模型修正的最多次数
+const defaultRepairAttempts = 2
+
+// ResponseFormat 要求模型按指定格式输出
+type ResponseFormat struct {
+	Type string
+	// Name 格式名称，OpenAI 的 json_schema 要求提供，默认为 response
+	Name string
+	// Schema 输出的 JSON Schema，Type 为 json_schema 时必填
+	Schema map[string]any
+	// Strict 要求后端严格按 Schema 生成，OpenAI 的严格模式要求全部字段必填且不允许额外字段
+	Strict bool
+}
+
+// ResponseFormatSupporter 由能够原生约束输出格式的 Handle 实现
+type ResponseFormatSupporter interface {
+	SupportsResponseFormat() bool
+}
+
+// SupportsResponseFormat 判断 Handle 是否支持原生的结构化输出
+func SupportsResponseFormat(h Handle) bool {
+	s, ok := h.(ResponseFormatSupporter)
+	return ok && s.SupportsResponseFormat()
+}
+
+// JSONSchemaFormat 以 v 的类型生成 Schema 的输出格式
+func JSONSchemaFormat(name string, v any) *ResponseFormat {
+	return &ResponseFormat{Type: FormatJSONSchema, Name: name, Schema: SchemaFor(v)}
+}
+
+func (f *ResponseFormat) name() string {
+	if f.Name == "" {
+		return "response"
+	}
+	return f.Name
+}
+
+// validateResponseFormat 校验输出格式，supported 为 false 时拒绝携带输出格式的请求
+func validateResponseFormat(provider string, format *ResponseFormat, supported bool) error {
+	if format == nil {
+		return nil
+	}
+	if !supported {
+		return &ValidationError{Param: ParamResponseFormat, Reason: fmt.Sprintf("not supported by %s", provider)}
+	}
+	switch format.Type {
+	case FormatJSONObject:
+	case FormatJSONSchema:
+		if format.Schema == nil {
+			return &ValidationError{Param: ParamResponseFormat, Reason: "schema is required for json_schema"}
+		}
+	default:
+		return &ValidationError{Param: ParamResponseFormat, Reason: fmt.Sprintf("unknown type %q", format.Type)}
+	}
+	return nil
+}
+
+// StructuredError 多次修正后模型的输出仍不符合格式
+type StructuredError struct {
+	Output string
+	Err    error
+}
+
+func (e *StructuredError) Error() string {
+	return "invalid structured output: " + e.Err.Error()
+}
+
+func (e *StructuredError) Unwrap() error {
+	return e.Err
+}
+
+// GenerateStructured 生成符合 req.ResponseFormat 的 JSON 并解码到 out。
+// 支持原生结构化输出的后端直接携带格式请求，其余后端改为在提示词中描述格式；
+// 两种方式的输出都会按 Schema 校验，不合法时把错误反馈给模型要求修正，最多修正 defaultRepairAttempts 次。
+// 返回的是最后一次的响应，用量为各次之和
+func GenerateStructured(ctx context.Context, h Handle, req *ChatRequest, out any) (*ChatResponse, error) {
+	format := req.ResponseFormat
+	if format == nil {
+		return nil, &ValidationError{Param: ParamResponseFormat, Reason: "response format is required"}
+	}
+	if err := validateResponseFormat("structured output", format, true); err != nil {
+		return nil, err
+	}
+	attemptReq := *req
+	attemptReq.Messages = withFormatInstruction(req.Messages, format)
+	if !SupportsResponseFormat(h) {
+		attemptReq.ResponseFormat = nil
+	}
+
+	var usage Usage
+	for attempt := 0; ; attempt++ {
+		res, err := h.GenerateChat(ctx, &attemptReq)
+		if err != nil {
+			return nil, err
+		}
+		usage.PromptTokens += res.Usage.PromptTokens
+		usage.CompletionTokens += res.Usage.CompletionTokens
+		usage.TotalTokens += res.Usage.TotalTokens
+		res.Usage = usage
+
+		content := res.Content()
+		err = decodeStructured(content, format, out)
+		if err == nil {
+			return res, nil
+		}
+		if attempt >= defaultRepairAttempts {
+			return res, &StructuredError{Output: content, Err: err}
+		}
+		attemptReq.Messages = append(attemptReq.Messages,
+			Message{Role: RoleAssistant, Content: content},
+			Message{Role: RoleUser, Content: fmt.Sprintf(
+				"上面的输出不符合要求：%s。请只输出修正后的 JSON，不要包含任何解释或 Markdown 代码块。", err.Error())},
+		)
+	}
+}
+
+// withFormatInstruction 在系统提示词末尾说明输出格式，没有系统提示词时新增一条
+func withFormatInstruction(messages []Message, format *ResponseFormat) []Message {
+	var b strings.Builder
+	b.WriteString("只输出一个 JSON 值，不要包含任何解释或 Markdown 代码块。")
+	if format.Type == FormatJSONSchema {
+		schema, _ := json.Marshal(format.Schema)
+		b.WriteString("输出必须符合以下 JSON Schema：\n")
+		b.Write(schema)
+	}
+	res := make([]Message, 0, len(messages)+1)
+	if len(messages) > 0 && messages[0].Role == RoleSystem {
+		first := messages[0]
+		first.Content = strings.TrimSpace(first.Content + "\n\n" + b.String())
+		res = append(res, first)
+		return append(res, messages[1:]...)
+	}
+	res = append(res, Message{Role: RoleSystem, Content: b.String()})
+	return append(res, messages...)
+}
+
+// decodeStructured 提取、校验并解码模型输出的 JSON
+func decodeStructured(content string, format *ResponseFormat, out any) error {
+	raw := extractJSON(content)
+	if raw == "" {
+		return errors.New("no JSON found in output")
+	}
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if format.Type == FormatJSONObject {
+		if _, ok := value.(map[string]any); !ok {
+			return errors.New("output must be a JSON object")
+		}
+	}
+	if format.Schema != nil {
+		if err := ValidateSchema(format.Schema, value); err != nil {
+			return err
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(raw), out); err != nil {
+		return fmt.Errorf("decode output: %w", err)
+	}
+	return nil
+}
+
+// extractJSON 去掉 Markdown 代码块与前后的说明文字，返回第一个完整的 JSON 对象或数组
+func extractJSON(content string) string {
+	content = strings.TrimSpace(content)
+	if start := strings.Index(content, "```"); start >= 0 {
+		body := content[start+3:]
+		// 跳过代码块的语言标记，如 ```json
+		if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+			body = body[nl+1:]
+		}
+		if end := strings.Index(body, "```"); end >= 0 {
+			content = strings.TrimSpace(body[:end])
+		}
+	}
+	start := strings.IndexAny(content, "{[")
+	if start < 0 {
+		return ""
+	}
+	dec := json.NewDecoder(strings.NewReader(content[start:]))
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		// 返回原文以便把具体的解析错误反馈给模型
+		return content[start:]
+	}
+	return string(raw)
+}
@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+type testProfile struct {
+	Name string   `json:"name" description:"姓名"`
+	Mood string   `json:"mood" enum:"happy,sad"`
+	Age  int      `json:"age,omitempty"`
+	Tags []string `json:"tags,omitempty"`
+	Pet  *struct {
+		Kind string `json:"kind"`
+	} `json:"pet,omitempty"`
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := SchemaFor(testProfile{})
+	tests := []struct {
+		name  string
+		value string
+		err   string
+	}{
+		{"valid", `{"name":"小明","mood":"happy","age":3,"tags":["a"],"pet":{"kind":"cat"}}`, ""},
+		{"optional omitted", `{"name":"小明","mood":"sad"}`, ""},
+		{"missing required", `{"mood":"happy"}`, `$: missing required field "name"`},
+		{"type mismatch", `{"name":1,"mood":"happy"}`, "$.name: expected string, got number"},
+		{"integer", `{"name":"a","mood":"happy","age":1.5}`, "$.age: expected integer, got number"},
+		{"not an object", `["a"]`, "$: expected object, got array"},
+		{"enum", `{"name":"a","mood":"angry"}`, "$.mood: must be one of [happy sad]"},
+		{"additional field", `{"name":"a","mood":"happy","x":1}`, "$.x: unexpected field"},
+		{"nested required", `{"name":"a","mood":"happy","pet":{}}`, `$.pet: missing required field "kind"`},
+		{"nested type", `{"name":"a","mood":"happy","pet":{"kind":false}}`, "$.pet.kind: expected string, got boolean"},
+		{"array item", `{"name":"a","mood":"happy","tags":["a",2]}`, "$.tags[1]: expected string, got number"},
+		{"array type", `{"name":"a","mood":"happy","tags":"a"}`, "$.tags: expected array, got string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateJSON(t, schema, tt.value)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.err != "" && (err == nil || err.Error() != tt.err):
+				t.Errorf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateSchemaKeywords(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"score": map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+			"text":  map[string]any{"type": "string", "minLength": 2, "maxLength": 4},
+			"list":  map[string]any{"type": "array", "minItems": 1, "maxItems": 2},
+			"id":    map[string]any{"anyOf": []any{map[string]any{"type": "string"}, map[string]any{"type": "integer"}}},
+			"kind":  map[string]any{"const": "x"},
+		},
+	}
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{`{"score":0.5,"text":"你好","list":[1],"id":"a","kind":"x"}`, true},
+		{`{"id":3}`, true},
+		{`{"score":1.5}`, false},
+		{`{"score":-1}`, false},
+		{`{"text":"a"}`, false},
+		{`{"text":"abcde"}`, false},
+		{`{"list":[]}`, false},
+		{`{"list":[1,2,3]}`, false},
+		{`{"id":true}`, false},
+		{`{"kind":"y"}`, false},
+	}
+	for _, tt := range tests {
+		err := validateJSON(t, schema, tt.value)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok = %v", tt.value, err, tt.ok)
+		}
+	}
+}
+
+func validateJSON(t *testing.T, schema map[string]any, data string) error {
+	t.Helper()
+	var value any
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatal(err)
+	}
+	return ValidateSchema(schema, value)
+}
+
+// countingHandle 记录被调用的次数
+type countingHandle struct {
+	Handle
+	calls atomic.Int32
+}
+
+func (h *countingHandle) GenerateChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	h.calls.Add(1)
+	return h.Handle.GenerateChat(ctx, req)
+}
+
+func TestGenerateStructuredRepair(t *testing.T) {
+	// 首次输出缺少字段，收到修正要求后输出合法的 JSON
+	fixture := `
+replies:
+  - match: "不符合要求"
+    reply: "好的：` + "```json\\n" + `{\"name\":\"小明\",\"mood\":\"happy\"}` + "\\n```" + `"
+  - match: "介绍"
+    reply: "{\"name\":\"小明\"}"
+`
+	h := &countingHandle{Handle: newTestMock(t, config.MockConfig{Fixture: fixture})}
+	var out testProfile
+	res, err := GenerateStructured(context.Background(), h, &ChatRequest{
+		Messages:       []Message{{Role: RoleUser, Content: "介绍一下你自己"}},
+		ResponseFormat: JSONSchemaFormat("profile", testProfile{}),
+	}, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "小明" || out.Mood != "happy" {
+		t.Errorf("out = %+v", out)
+	}
+	if n := h.calls.Load(); n != 2 {
+		t.Errorf("calls = %d, want 2", n)
+	}
+	// 用量为两次调用之和
+	if res.Usage.CompletionTokens <= len([]rune(res.Content())) {
+		t.Errorf("usage = %+v is not accumulated", res.Usage)
+	}
+}
+
+func TestGenerateStructuredGivesUp(t *testing.T) {
+	h := &countingHandle{Handle: newTestMock(t, config.MockConfig{Reply: "我不会输出 JSON"})}
+	var out testProfile
+	_, err := GenerateStructured(context.Background(), h, &ChatRequest{
+		Messages:       []Message{{Role: RoleUser, Content: "介绍一下你自己"}},
+		ResponseFormat: JSONSchemaFormat("profile", testProfile{}),
+	}, &out)
+	var structuredErr *StructuredError
+	if !errors.As(err, &structuredErr) {
+		t.Fatalf("err = %v, want StructuredError", err)
+	}
+	if structuredErr.Output != "我不会输出 JSON" || !strings.Contains(err.Error(), "no JSON found") {
+		t.Errorf("err = %v, output = %q", err, structuredErr.Output)
+	}
+	if n := h.calls.Load(); n != defaultRepairAttempts+1 {
+		t.Errorf("calls = %d, want %d", n, defaultRepairAttempts+1)
+	}
+}
@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// defaultOllamaContextWindow Ollama 未指定 num_ctx 时使用的上下文长度
+const defaultOllamaContextWindow = 4096
+
+// tokenFamily 一个模型系列的分词特征，系数按各系列分词器对中英文语料的实测比例取整
+type tokenFamily struct {
+	// prefixes 模型名中出现任一前缀即属于该系列
+	prefixes []string
+	// charsPerToken 平均每个 token 对应的 ASCII 字符数
+	charsPerToken float64
+	// tokensPerCJK 平均每个中日韩文字对应的 token 数
+	tokensPerCJK float64
+	// messageOverhead 每条消息的角色标记与分隔符占用的 token 数
+	messageOverhead int
+	// contextWindow 该系列常见的上下文长度
+	contextWindow int
+}
+
+// tokenFamilies 按顺序匹配，更具体的前缀放在前面
+var tokenFamilies = []tokenFamily{
+	{prefixes: []string{"qwen"}, charsPerToken: 4, tokensPerCJK: 0.7, messageOverhead: 5, contextWindow: 32768},
+	{prefixes: []string{"deepseek"}, charsPerToken: 4, tokensPerCJK: 0.6, messageOverhead: 4, contextWindow: 65536},
+	{prefixes: []string{"glm", "chatglm"}, charsPerToken: 4, tokensPerCJK: 0.7, messageOverhead: 4, contextWindow: 131072},
+	{prefixes: []string{"gpt-4o", "gpt-4.1", "gpt-5", "o1", "o3", "o4"}, charsPerToken: 4, tokensPerCJK: 0.8, messageOverhead: 4, contextWindow: 131072},
+	{prefixes: []string{"gpt"}, charsPerToken: 4, tokensPerCJK: 1.2, messageOverhead: 4, contextWindow: 16384},
+	{prefixes: []string{"claude"}, charsPerToken: 3.5, tokensPerCJK: 1.2, messageOverhead: 5, contextWindow: 200000},
+	{prefixes: []string{"gemini"}, charsPerToken: 4, tokensPerCJK: 0.8, messageOverhead: 4, contextWindow: 1048576},
+	{prefixes: []string{"llama"}, charsPerToken: 4, tokensPerCJK: 1.0, messageOverhead: 4, contextWindow: 8192},
+	{prefixes: []string{"mistral", "mixtral"}, charsPerToken: 3.5, tokensPerCJK: 1.5, messageOverhead: 4, contextWindow: 32768},
+}
+
+// defaultTokenFamily 无法识别的模型按偏保守的系数估算
+var defaultTokenFamily = tokenFamily{charsPerToken: 3.5, tokensPerCJK: 1.0, messageOverhead: 4, contextWindow: 4096}
+
+// TokenCounter 按模型系列估算 token 数。不加载分词器，误差通常在 10% 以内，
+// 用于裁剪上下文时应留出余量
+type TokenCounter struct {
+	family tokenFamily
+}
+
+func NewTokenCounter(model string) *TokenCounter {
+	return &TokenCounter{family: matchTokenFamily(model)}
+}
+
+func matchTokenFamily(model string) tokenFamily {
+	model = strings.ToLower(model)
+	// 去掉 Ollama 的命名空间，如 library/qwen2.5、deepseek-ai/DeepSeek-R1
+	if i := strings.LastIndexByte(model, '/'); i >= 0 {
+		model = model[i+1:]
+	}
+	for _, f := range tokenFamilies {
+		for _, prefix := range f.prefixes {
+			if strings.HasPrefix(model, prefix) {
+				return f
+			}
+		}
+	}
+	return defaultTokenFamily
+}
+
+// Count 估算一段文本的 token 数
+func (c *TokenCounter) Count(text string) int {
+	if text == "" {
+		return 0
+	}
+	var ascii, cjk, other int
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case isCJK(r):
+			cjk++
+		default:
+			// 表情、带音调的字母等通常被拆成多个字节级 token
+			other++
+		}
+	}
+	tokens := float64(ascii)/c.family.charsPerToken + float64(cjk)*c.family.tokensPerCJK + float64(other)*1.5
+	return int(math.Ceil(tokens))
+}
+
+// CountMessage 估算一条消息占用的 token 数，包括角色标记与工具调用
+func (c *TokenCounter) CountMessage(m Message) int {
+	tokens := c.family.messageOverhead + c.Count(m.Content) + c.Count(m.Name)
+	for _, call := range m.ToolCalls {
+		tokens += c.Count(call.Name) + c.Count(call.Arguments)
+	}
+	return tokens
+}
+
+// CountMessages 估算消息列表的 token 数，另加回复开头的角色标记
+func (c *TokenCounter) CountMessages(messages []Message) int {
+	tokens := c.family.messageOverhead
+	for _, m := range messages {
+		tokens += c.CountMessage(m)
+	}
+	return tokens
+}
+
+// CountTools 估算工具定义占用的 token 数
+func (c *TokenCounter) CountTools(tools []Tool) int {
+	var tokens int
+	for _, tool := range tools {
+		params, _ := json.Marshal(tool.Parameters)
+		tokens += c.family.messageOverhead + c.Count(tool.Name) + c.Count(tool.Description) + c.Count(string(params))
+	}
+	return tokens
+}
+
+// ContextWindow 返回模型配置的上下文长度：优先使用 contextWindow 配置，
+// ollama_llm 未配置时为 Ollama 的默认 num_ctx，failover_llm 取各后端中最小的，其余按模型系列取默认值
+func ContextWindow(cfg *config.LLMConfig) int {
+	if cfg.ContextWindow > 0 {
+		return cfg.ContextWindow
+	}
+	switch cfg.Provider {
+	case "ollama_llm":
+		return defaultOllamaContextWindow
+	case failoverProvider:
+		var window int
+		for i := range cfg.Chain {
+			if w := ContextWindow(&cfg.Chain[i]); window == 0 || w < window {
+				window = w
+			}
+		}
+		if window > 0 {
+			return window
+		}
+	}
+	return matchTokenFamily(cfg.Model).contextWindow
+}
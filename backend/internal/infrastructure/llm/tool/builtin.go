@@ -0,0 +1,90 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// 内置工具名称
+const (
+	NameCurrentTime     = "get_current_time"
+	NameDateCalc        = "date_calc"
+	NameCalculator      = "calculator"
+	NameConvertUnit     = "convert_unit"
+	NameConvertCurrency = "convert_currency"
+)
+
+type timezoneKey struct{}
+
+// WithTimezone 在 ctx 中记录用户所在时区，供时间相关的工具使用
+func WithTimezone(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, timezoneKey{}, loc)
+}
+
+// userLocation 依次取工具参数中的时区、ctx 中的用户时区与 fallback
+func userLocation(ctx context.Context, name string, fallback *time.Location) (*time.Location, error) {
+	if name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", name)
+		}
+		return loc, nil
+	}
+	if loc, ok := ctx.Value(timezoneKey{}).(*time.Location); ok && loc != nil {
+		return loc, nil
+	}
+	return fallback, nil
+}
+
+// RegisterBuiltins 注册不依赖网络的内置工具：当前时间、日期计算、计算器、单位与货币换算
+func RegisterBuiltins(r *Registry, cfg *config.ToolsConfig) error {
+	loc := time.Local
+	if cfg.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return fmt.Errorf("tools.timezone: %w", err)
+		}
+	}
+	rates, err := newRateTable(cfg)
+	if err != nil {
+		return err
+	}
+	clock := &clock{loc: loc, now: time.Now}
+	tools := []*Tool{
+		clock.currentTimeTool(),
+		clock.dateCalcTool(),
+		calculatorTool(),
+		convertUnitTool(),
+		rates.tool(),
+	}
+	var errs []error
+	for _, t := range tools {
+		t.Timeout = cfg.Timeout
+		if err := r.Register(t); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// decodeArgs 解析工具参数，错误信息会返回给模型
+func decodeArgs(arguments json.RawMessage, v any) error {
+	if err := json.Unmarshal(arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// jsonResult 以 JSON 形式返回工具结果，便于模型准确读取各字段
+func jsonResult(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,176 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"2 ^ 3 ^ 2", 512},
+		{"-2 ^ 2", -4},
+		{"(-2) ^ 2", 4},
+		{"2 * -3", -6},
+		{"7 % 4 + 1", 4},
+		{"8 / 4 / 2", 1},
+		{"1.5e3 + .5", 1500.5},
+		{"（3 + 5）× 2 ÷ 4", 4},
+		{"2 ** 10", 1024},
+		{"sqrt(16) + abs(-2)", 6},
+		{"round(3.14159, 2)", 3.14},
+		{"log(1000) + log(8, 2)", 6},
+		{"max(1, 5, 3) - min(4, 2)", 3},
+		{"floor(pi) + ceil(e)", 6},
+	}
+	for _, tt := range tests {
+		got, err := Evaluate(tt.expr)
+		if err != nil {
+			t.Errorf("Evaluate(%q): %v", tt.expr, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Evaluate(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"1 / 0", "division by zero"},
+		{"5 % (2 - 2)", "division by zero"},
+		{"sqrt(-1)", "not a finite number"},
+		{"10 ^ 400", "not a finite number"},
+		{"(1 + 2", "missing closing parenthesis"},
+		{"1 +", "unexpected end"},
+		{"1 2", "unexpected"},
+		{"foo(1)", "unknown function"},
+		{"x + 1", "unknown constant"},
+		{"pow(2)", "pow expects 2 arguments"},
+		{"os.exit(1)", "unknown"},
+		{strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100), "nested too deeply"},
+		{strings.Repeat("-", 100) + "1", "nested too deeply"},
+		{strings.Repeat("1+", 300) + "1", "too long"},
+	}
+	for _, tt := range tests {
+		_, err := Evaluate(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Evaluate(%.20q) err = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+// callTool 调用工具并解析 JSON 结果
+func callTool(t *testing.T, tool *Tool, args string) map[string]any {
+	t.Helper()
+	out, err := tool.Handler(context.Background(), json.RawMessage(args))
+	if err != nil {
+		t.Fatalf("%s(%s): %v", tool.Name, args, err)
+	}
+	var res map[string]any
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestConvertUnit(t *testing.T) {
+	tests := []struct {
+		args string
+		want string
+	}{
+		{`{"value":1,"from":"km","to":"m"}`, "1000"},
+		{`{"value":1,"from":"公斤","to":"斤"}`, "2"},
+		{`{"value":1,"from":"亩","to":"m2"}`, "666.666666667"},
+		{`{"value":100,"from":"℃","to":"℉"}`, "212"},
+		{`{"value":32,"from":"F","to":"C"}`, "0"},
+		{`{"value":0,"from":"k","to":"c"}`, "-273.15"},
+		{`{"value":1,"from":"GB","to":"MB"}`, "1024"},
+		{`{"value":36,"from":"km/h","to":"m/s"}`, "10"},
+		{`{"value":1,"from":"mile","to":"ft"}`, "5280"},
+		{`{"value":2,"from":"天","to":"h"}`, "48"},
+	}
+	for _, tt := range tests {
+		if got := callTool(t, convertUnitTool(), tt.args)["result"]; got != tt.want {
+			t.Errorf("convert_unit(%s) = %v, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	for _, args := range []string{
+		`{"value":1,"from":"km","to":"kg"}`,
+		`{"value":1,"from":"parsec","to":"m"}`,
+		`{"value":"one","from":"km","to":"m"}`,
+	} {
+		if _, err := convertUnit(context.Background(), json.RawMessage(args)); err == nil {
+			t.Errorf("convert_unit(%s): want error", args)
+		}
+	}
+}
+
+func TestDateCalc(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+	// 北京时间 2024-02-28 23:30，UTC 仍是 28 日
+	c := &clock{loc: shanghai, now: func() time.Time { return time.Date(2024, 2, 28, 15, 30, 0, 0, time.UTC) }}
+	tests := []struct {
+		args string
+		want map[string]any
+	}{
+		{`{"operation":"diff","target":"2024-03-01"}`, map[string]any{"from": "2024-02-28", "days": 2.0, "toWeekday": "星期五"}},
+		{`{"operation":"diff","date":"2024-03-10","target":"2024-03-01"}`, map[string]any{"days": -9.0, "targetInPast": true}},
+		// 只给出月日时取当天或之后最近的一次，2 月 29 日只在闰年出现
+		{`{"operation":"diff","target":"02-28"}`, map[string]any{"to": "2024-02-28", "days": 0.0}},
+		{`{"operation":"diff","target":"01-01"}`, map[string]any{"to": "2025-01-01", "days": 308.0}},
+		{`{"operation":"diff","date":"2025-03-01","target":"02-29"}`, map[string]any{"to": "2028-02-29"}},
+		// 跨度超过 time.Duration 的范围
+		{`{"operation":"diff","date":"0001-01-01","target":"9999-12-31"}`, map[string]any{"days": 3652058.0}},
+		{`{"operation":"diff","date":"1900-01-01","target":"2200-01-01"}`, map[string]any{"days": 109573.0}},
+		{`{"operation":"add","date":"2024-01-31","months":1}`, map[string]any{"result": "2024-02-29"}},
+		{`{"operation":"add","date":"2023-01-31","months":1}`, map[string]any{"result": "2023-02-28"}},
+		{`{"operation":"add","date":"2024-02-29","years":1}`, map[string]any{"result": "2025-02-28"}},
+		{`{"operation":"add","date":"2024-03-10","weeks":-1,"days":-3}`, map[string]any{"result": "2024-02-29", "weekday": "星期四"}},
+		{`{"operation":"add","days":1,"timezone":"UTC"}`, map[string]any{"from": "2024-02-28", "result": "2024-02-29"}},
+	}
+	for _, tt := range tests {
+		got := callTool(t, c.dateCalcTool(), tt.args)
+		for k, want := range tt.want {
+			if got[k] != want {
+				t.Errorf("date_calc(%s)[%s] = %v, want %v", tt.args, k, got[k], want)
+			}
+		}
+	}
+
+	// 用户时区已经是 29 日
+	ctx := WithTimezone(context.Background(), time.FixedZone("UTC+9", 9*3600))
+	c.now = func() time.Time { return time.Date(2024, 2, 28, 15, 30, 0, 0, time.UTC) }
+	out, err := c.dateCalc(ctx, json.RawMessage(`{"operation":"diff","target":"03-01"}`))
+	if err != nil || !strings.Contains(out, `"days":1`) {
+		t.Errorf("date_calc in UTC+9 = %s, %v", out, err)
+	}
+
+	for _, args := range []string{
+		`{"operation":"diff"}`,
+		`{"operation":"diff","target":"2024/03/01"}`,
+		`{"operation":"add","date":"yesterday"}`,
+		`{"operation":"sub"}`,
+		`{"operation":"diff","target":"03-01","timezone":"Mars/Olympus"}`,
+	} {
+		if _, err := c.dateCalc(context.Background(), json.RawMessage(args)); err == nil {
+			t.Errorf("date_calc(%s): want error", args)
+		}
+	}
+}
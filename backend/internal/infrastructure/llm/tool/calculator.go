@@ -0,0 +1,391 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	maxExpressionLength = 512
+	maxExpressionDepth  = 64
+)
+
+func calculatorTool() *Tool {
+	return &Tool{
+		Name: NameCalculator,
+		Description: "计算数学表达式，支持 + - * / % ^、括号、常量 pi e，" +
+			"以及函数 sqrt abs round floor ceil ln log log2 exp sin cos tan pow min max（三角函数使用弧度）",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"expression": map[string]any{
+					"type":        "string",
+					"description": "数学表达式，如 (3 + 5) * 2 ^ 3 或 sqrt(2) / 2",
+				},
+			},
+			"required": []string{"expression"},
+		},
+		Handler: calculate,
+	}
+}
+
+func calculate(_ context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Expression string `json:"expression"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	value, err := Evaluate(args.Expression)
+	if err != nil {
+		return "", err
+	}
+	return jsonResult(map[string]any{
+		"expression": args.Expression,
+		"result":     formatNumber(value),
+	})
+}
+
+// Evaluate 计算算术表达式。只解析数字、运算符与白名单中的函数，不会执行任意代码
+func Evaluate(expression string) (float64, error) {
+	if len(expression) > maxExpressionLength {
+		return 0, fmt.Errorf("expression is too long")
+	}
+	p := &exprParser{src: []rune(normalizeExpression(expression))}
+	value, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.src) {
+		return 0, fmt.Errorf("unexpected %q at position %d", string(p.src[p.pos]), p.pos)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return value, nil
+}
+
+// normalizeExpression 兼容中文输入习惯中的全角符号与乘除号
+var expressionReplacer = strings.NewReplacer(
+	"×", "*", "÷", "/", "（", "(", "）", ")", "，", ",", "－", "-", "＋", "+", "**", "^",
+)
+
+func normalizeExpression(expression string) string {
+	return expressionReplacer.Replace(expression)
+}
+
+func formatNumber(v float64) string {
+	if v == math.Trunc(v) && math.Abs(v) < 1e15 {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', 12, 64)
+}
+
+// exprParser 递归下降解析器，优先级从低到高：加减、乘除取余、正负号、乘方
+type exprParser struct {
+	src   []rune
+	pos   int
+	depth int
+}
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.src) && unicode.IsSpace(p.src[p.pos]) {
+		p.pos++
+	}
+}
+
+// consume 跳过空白后若下一个字符为 r 则消费它
+func (p *exprParser) consume(r rune) bool {
+	p.skipSpaces()
+	if p.pos < len(p.src) && p.src[p.pos] == r {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > maxExpressionDepth {
+		return fmt.Errorf("expression is nested too deeply")
+	}
+	return nil
+}
+
+func (p *exprParser) parseExpr() (float64, error) {
+	if err := p.enter(); err != nil {
+		return 0, err
+	}
+	defer func() { p.depth-- }()
+
+	left, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch {
+		case p.consume('+'):
+			right, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			left += right
+		case p.consume('-'):
+			right, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			left -= right
+		default:
+			return left, nil
+		}
+	}
+}
+
+func (p *exprParser) parseTerm() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		var op rune
+		switch {
+		case p.consume('*'):
+			op = '*'
+		case p.consume('/'):
+			op = '/'
+		case p.consume('%'):
+			op = '%'
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			left *= right
+		case '/':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		case '%':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left = math.Mod(left, right)
+		}
+	}
+}
+
+func (p *exprParser) parseUnary() (float64, error) {
+	if err := p.enter(); err != nil {
+		return 0, err
+	}
+	defer func() { p.depth-- }()
+
+	switch {
+	case p.consume('-'):
+		v, err := p.parseUnary()
+		return -v, err
+	case p.consume('+'):
+		return p.parseUnary()
+	default:
+		return p.parsePower()
+	}
+}
+
+// parsePower 乘方为右结合，且优先级高于负号：-2^2 = -4
+func (p *exprParser) parsePower() (float64, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+	if !p.consume('^') {
+		return base, nil
+	}
+	exp, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exp), nil
+}
+
+func (p *exprParser) parsePrimary() (float64, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.src) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+	r := p.src[p.pos]
+	switch {
+	case r == '(':
+		p.pos++
+		v, err := p.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+		if !p.consume(')') {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		return v, nil
+	case unicode.IsDigit(r) || r == '.':
+		return p.parseNumber()
+	case unicode.IsLetter(r):
+		return p.parseIdent()
+	default:
+		return 0, fmt.Errorf("unexpected %q at position %d", string(r), p.pos)
+	}
+}
+
+func (p *exprParser) parseNumber() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (unicode.IsDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+		p.pos++
+	}
+	// 科学计数法，如 1.5e3
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		next := p.pos + 1
+		if next < len(p.src) && (p.src[next] == '+' || p.src[next] == '-') {
+			next++
+		}
+		if next < len(p.src) && unicode.IsDigit(p.src[next]) {
+			p.pos = next
+			for p.pos < len(p.src) && unicode.IsDigit(p.src[p.pos]) {
+				p.pos++
+			}
+		}
+	}
+	text := string(p.src[start:p.pos])
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return v, nil
+}
+
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+var functions = map[string]func(args []float64) (float64, error){
+	"sqrt":  unary(math.Sqrt),
+	"abs":   unary(math.Abs),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"ln":    unary(math.Log),
+	"log2":  unary(math.Log2),
+	"exp":   unary(math.Exp),
+	"sin":   unary(math.Sin),
+	"cos":   unary(math.Cos),
+	"tan":   unary(math.Tan),
+	"log": func(args []float64) (float64, error) {
+		switch len(args) {
+		case 1:
+			return math.Log10(args[0]), nil
+		case 2:
+			return math.Log(args[0]) / math.Log(args[1]), nil
+		}
+		return 0, fmt.Errorf("log expects 1 or 2 arguments")
+	},
+	"round": func(args []float64) (float64, error) {
+		switch len(args) {
+		case 1:
+			return math.Round(args[0]), nil
+		case 2:
+			scale := math.Pow(10, math.Trunc(args[1]))
+			return math.Round(args[0]*scale) / scale, nil
+		}
+		return 0, fmt.Errorf("round expects 1 or 2 arguments")
+	},
+	"pow": func(args []float64) (float64, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("pow expects 2 arguments")
+		}
+		return math.Pow(args[0], args[1]), nil
+	},
+	"min": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("min expects at least 1 argument")
+		}
+		return slicesMin(args), nil
+	},
+	"max": func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("max expects at least 1 argument")
+		}
+		return -slicesMin(negate(args)), nil
+	},
+}
+
+func unary(fn func(float64) float64) func(args []float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("expects 1 argument")
+		}
+		return fn(args[0]), nil
+	}
+}
+
+func slicesMin(values []float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		m = math.Min(m, v)
+	}
+	return m
+}
+
+func negate(values []float64) []float64 {
+	res := make([]float64, len(values))
+	for i, v := range values {
+		res[i] = -v
+	}
+	return res
+}
+
+func (p *exprParser) parseIdent() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.src) && (unicode.IsLetter(p.src[p.pos]) || unicode.IsDigit(p.src[p.pos])) {
+		p.pos++
+	}
+	name := strings.ToLower(string(p.src[start:p.pos]))
+	if !p.consume('(') {
+		if v, ok := constants[name]; ok {
+			return v, nil
+		}
+		return 0, fmt.Errorf("unknown constant %q", name)
+	}
+	fn, ok := functions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown function %q", name)
+	}
+	var args []float64
+	if !p.consume(')') {
+		for {
+			v, err := p.parseExpr()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+			if p.consume(')') {
+				break
+			}
+			if !p.consume(',') {
+				return 0, fmt.Errorf("expected , or ) in call to %s", name)
+			}
+		}
+	}
+	v, err := fn(args)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
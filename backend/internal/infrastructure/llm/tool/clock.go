@@ -0,0 +1,181 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	dateLayout    = "2006-01-02"
+	secondsPerDay = 24 * 60 * 60
+)
+
+var weekdays = [...]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// clock 时间相关的工具，now 可替换以便测试
+type clock struct {
+	loc *time.Location
+	now func() time.Time
+}
+
+var timezoneParam = map[string]any{
+	"type":        "string",
+	"description": "IANA 时区名，如 Asia/Shanghai，不填时使用用户所在时区",
+}
+
+func (c *clock) currentTimeTool() *Tool {
+	return &Tool{
+		Name:        NameCurrentTime,
+		Description: "获取当前的日期、时间与星期几",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"timezone": timezoneParam,
+			},
+		},
+		Handler: c.currentTime,
+	}
+}
+
+func (c *clock) currentTime(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	loc, err := userLocation(ctx, args.Timezone, c.loc)
+	if err != nil {
+		return "", err
+	}
+	now := c.now().In(loc)
+	return jsonResult(map[string]any{
+		"datetime":  now.Format(time.DateTime),
+		"date":      now.Format(dateLayout),
+		"time":      now.Format(time.TimeOnly),
+		"weekday":   weekdays[now.Weekday()],
+		"timezone":  loc.String(),
+		"utcOffset": now.Format("-07:00"),
+	})
+}
+
+func (c *clock) dateCalcTool() *Tool {
+	return &Tool{
+		Name: NameDateCalc,
+		Description: "日期计算。operation=diff 计算 date 到 target 相差的天数，可用于“距离某天还有几天”；" +
+			"operation=add 计算 date 加上若干年、月、周、天之后的日期，数值可为负数",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"operation": map[string]any{
+					"type": "string",
+					"enum": []string{"diff", "add"},
+				},
+				"date": map[string]any{
+					"type":        "string",
+					"description": "起始日期，格式 YYYY-MM-DD，不填时为今天",
+				},
+				"target": map[string]any{
+					"type":        "string",
+					"description": "diff 的目标日期，格式 YYYY-MM-DD；只给出 MM-DD 时取起始日期当天或之后最近的一次，适合生日、节日",
+				},
+				"years":    map[string]any{"type": "integer"},
+				"months":   map[string]any{"type": "integer"},
+				"weeks":    map[string]any{"type": "integer"},
+				"days":     map[string]any{"type": "integer"},
+				"timezone": timezoneParam,
+			},
+			"required": []string{"operation"},
+		},
+		Handler: c.dateCalc,
+	}
+}
+
+func (c *clock) dateCalc(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Operation string `json:"operation"`
+		Date      string `json:"date"`
+		Target    string `json:"target"`
+		Years     int    `json:"years"`
+		Months    int    `json:"months"`
+		Weeks     int    `json:"weeks"`
+		Days      int    `json:"days"`
+		Timezone  string `json:"timezone"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	loc, err := userLocation(ctx, args.Timezone, c.loc)
+	if err != nil {
+		return "", err
+	}
+	y, m, d := c.now().In(loc).Date()
+	// 只关心日期，统一用 UTC 零点计算，避免夏令时造成的天数误差
+	from := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	if args.Date != "" {
+		if from, err = time.Parse(dateLayout, args.Date); err != nil {
+			return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", args.Date)
+		}
+	}
+
+	switch args.Operation {
+	case "diff":
+		to, err := parseTarget(args.Target, from)
+		if err != nil {
+			return "", err
+		}
+		// from 与 to 都是 UTC 零点，直接按 Unix 秒数相减；time.Duration 只能表示约 292 年
+		days := int((to.Unix() - from.Unix()) / secondsPerDay)
+		return jsonResult(map[string]any{
+			"from":         from.Format(dateLayout),
+			"to":           to.Format(dateLayout),
+			"toWeekday":    weekdays[to.Weekday()],
+			"days":         days,
+			"weeksAndDays": fmt.Sprintf("%d周%d天", days/7, days%7),
+			"targetInPast": days < 0,
+		})
+	case "add":
+		to := addMonths(from, args.Years*12+args.Months).AddDate(0, 0, args.Weeks*7+args.Days)
+		return jsonResult(map[string]any{
+			"from":    from.Format(dateLayout),
+			"result":  to.Format(dateLayout),
+			"weekday": weekdays[to.Weekday()],
+		})
+	default:
+		return "", fmt.Errorf("unknown operation %q, expected diff or add", args.Operation)
+	}
+}
+
+// parseTarget 解析目标日期，MM-DD 取 from 当天或之后最近的一次
+func parseTarget(target string, from time.Time) (time.Time, error) {
+	if target == "" {
+		return time.Time{}, fmt.Errorf("target is required for diff")
+	}
+	if t, err := time.Parse(dateLayout, target); err == nil {
+		return t, nil
+	}
+	md, err := time.Parse("01-02", target)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid target %q, expected YYYY-MM-DD or MM-DD", target)
+	}
+	for year := from.Year(); ; year++ {
+		t := time.Date(year, md.Month(), md.Day(), 0, 0, 0, 0, time.UTC)
+		// 2 月 29 日只在闰年出现
+		if t.Month() == md.Month() && !t.Before(from) {
+			return t, nil
+		}
+	}
+}
+
+// addMonths 按自然月加减，目标月份没有对应日期时取该月最后一天（如 1 月 31 日加一个月为 2 月 28/29 日）
+func addMonths(t time.Time, months int) time.Time {
+	if months == 0 {
+		return t
+	}
+	y, m, d := t.Date()
+	first := time.Date(y, m+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(d, lastDay)-1)
+}
@@ -0,0 +1,264 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// unit 换算为所属类别基准单位的系数，温度另有偏移量：base = value*factor + offset
+type unit struct {
+	category string
+	factor   float64
+	offset   float64
+}
+
+// units 单位及其别名，键统一为小写。各类别的基准单位：
+// 长度米、质量千克、体积升、面积平方米、速度米每秒、数据字节、时间秒、温度摄氏度
+var units = buildUnits(map[string]struct {
+	unit
+	aliases []string
+}{
+	// 长度
+	"m":   {unit{"length", 1, 0}, []string{"meter", "meters", "metre", "米"}},
+	"km":  {unit{"length", 1000, 0}, []string{"kilometer", "kilometers", "千米", "公里"}},
+	"cm":  {unit{"length", 0.01, 0}, []string{"centimeter", "centimeters", "厘米"}},
+	"mm":  {unit{"length", 0.001, 0}, []string{"millimeter", "millimeters", "毫米"}},
+	"in":  {unit{"length", 0.0254, 0}, []string{"inch", "inches", "英寸"}},
+	"ft":  {unit{"length", 0.3048, 0}, []string{"foot", "feet", "英尺"}},
+	"yd":  {unit{"length", 0.9144, 0}, []string{"yard", "yards", "码"}},
+	"mi":  {unit{"length", 1609.344, 0}, []string{"mile", "miles", "英里"}},
+	"nmi": {unit{"length", 1852, 0}, []string{"nautical mile", "海里"}},
+	"里":   {unit{"length", 500, 0}, nil},
+	"丈":   {unit{"length", 10.0 / 3, 0}, nil},
+	"尺":   {unit{"length", 1.0 / 3, 0}, []string{"市尺"}},
+	"寸":   {unit{"length", 1.0 / 30, 0}, []string{"市寸"}},
+	// 质量
+	"kg": {unit{"mass", 1, 0}, []string{"kilogram", "kilograms", "千克", "公斤"}},
+	"g":  {unit{"mass", 0.001, 0}, []string{"gram", "grams", "克"}},
+	"mg": {unit{"mass", 1e-6, 0}, []string{"milligram", "milligrams", "毫克"}},
+	"t":  {unit{"mass", 1000, 0}, []string{"ton", "tonne", "tonnes", "吨"}},
+	"lb": {unit{"mass", 0.45359237, 0}, []string{"lbs", "pound", "pounds", "磅"}},
+	"oz": {unit{"mass", 0.028349523125, 0}, []string{"ounce", "ounces", "盎司"}},
+	"斤":  {unit{"mass", 0.5, 0}, []string{"市斤"}},
+	"两":  {unit{"mass", 0.05, 0}, []string{"市两"}},
+	// 体积
+	"l":     {unit{"volume", 1, 0}, []string{"liter", "liters", "litre", "升"}},
+	"ml":    {unit{"volume", 0.001, 0}, []string{"milliliter", "milliliters", "毫升"}},
+	"m3":    {unit{"volume", 1000, 0}, []string{"m³", "cubic meter", "立方米", "方"}},
+	"gal":   {unit{"volume", 3.785411784, 0}, []string{"gallon", "gallons", "加仑"}},
+	"qt":    {unit{"volume", 0.946352946, 0}, []string{"quart", "quarts", "夸脱"}},
+	"cup":   {unit{"volume", 0.2365882365, 0}, []string{"cups", "杯"}},
+	"fl oz": {unit{"volume", 0.0295735295625, 0}, []string{"floz", "fluid ounce", "液量盎司"}},
+	// 面积
+	"m2":   {unit{"area", 1, 0}, []string{"m²", "square meter", "平方米", "平米"}},
+	"km2":  {unit{"area", 1e6, 0}, []string{"km²", "square kilometer", "平方公里", "平方千米"}},
+	"cm2":  {unit{"area", 1e-4, 0}, []string{"cm²", "square centimeter", "平方厘米"}},
+	"ha":   {unit{"area", 1e4, 0}, []string{"hectare", "hectares", "公顷"}},
+	"acre": {unit{"area", 4046.8564224, 0}, []string{"acres", "英亩"}},
+	"ft2":  {unit{"area", 0.09290304, 0}, []string{"ft²", "square foot", "square feet", "平方英尺"}},
+	"亩":    {unit{"area", 10000.0 / 15, 0}, []string{"市亩"}},
+	// 速度
+	"m/s":  {unit{"speed", 1, 0}, []string{"mps", "米每秒"}},
+	"km/h": {unit{"speed", 1000.0 / 3600, 0}, []string{"kph", "kmh", "公里每小时", "千米每小时"}},
+	"mph":  {unit{"speed", 0.44704, 0}, []string{"mi/h", "英里每小时"}},
+	"kn":   {unit{"speed", 1852.0 / 3600, 0}, []string{"knot", "knots", "节"}},
+	// 数据
+	"b":  {unit{"data", 1, 0}, []string{"byte", "bytes", "字节"}},
+	"kb": {unit{"data", 1 << 10, 0}, []string{"kib", "kilobyte", "kilobytes"}},
+	"mb": {unit{"data", 1 << 20, 0}, []string{"mib", "megabyte", "megabytes", "兆"}},
+	"gb": {unit{"data", 1 << 30, 0}, []string{"gib", "gigabyte", "gigabytes"}},
+	"tb": {unit{"data", 1 << 40, 0}, []string{"tib", "terabyte", "terabytes"}},
+	// 时间
+	"s":    {unit{"time", 1, 0}, []string{"sec", "second", "seconds", "秒"}},
+	"min":  {unit{"time", 60, 0}, []string{"minute", "minutes", "分钟"}},
+	"h":    {unit{"time", 3600, 0}, []string{"hr", "hour", "hours", "小时"}},
+	"day":  {unit{"time", 86400, 0}, []string{"d", "days", "天"}},
+	"week": {unit{"time", 7 * 86400, 0}, []string{"weeks", "周", "星期"}},
+	// 温度
+	"c": {unit{"temperature", 1, 0}, []string{"°c", "℃", "celsius", "摄氏度"}},
+	"f": {unit{"temperature", 5.0 / 9, -32 * 5.0 / 9}, []string{"°f", "℉", "fahrenheit", "华氏度"}},
+	"k": {unit{"temperature", 1, -273.15}, []string{"kelvin", "开尔文"}},
+})
+
+func buildUnits(defs map[string]struct {
+	unit
+	aliases []string
+}) map[string]unit {
+	res := make(map[string]unit)
+	for name, def := range defs {
+		res[name] = def.unit
+		for _, alias := range def.aliases {
+			res[alias] = def.unit
+		}
+	}
+	return res
+}
+
+func lookupUnit(name string) (unit, bool) {
+	u, ok := units[strings.ToLower(strings.TrimSpace(name))]
+	return u, ok
+}
+
+func convertUnitTool() *Tool {
+	return &Tool{
+		Name: NameConvertUnit,
+		Description: "单位换算，支持长度、质量、体积、面积、速度、数据大小、时间与温度，" +
+			"包括斤、两、亩、尺等市制单位，如 km、斤、℉、mph、GB",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"value": map[string]any{"type": "number"},
+				"from":  map[string]any{"type": "string", "description": "原单位"},
+				"to":    map[string]any{"type": "string", "description": "目标单位"},
+			},
+			"required": []string{"value", "from", "to"},
+		},
+		Handler: convertUnit,
+	}
+}
+
+func convertUnit(_ context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Value float64 `json:"value"`
+		From  string  `json:"from"`
+		To    string  `json:"to"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	from, ok := lookupUnit(args.From)
+	if !ok {
+		return "", fmt.Errorf("unknown unit %q", args.From)
+	}
+	to, ok := lookupUnit(args.To)
+	if !ok {
+		return "", fmt.Errorf("unknown unit %q", args.To)
+	}
+	if from.category != to.category {
+		return "", fmt.Errorf("cannot convert %s (%s) to %s (%s)", args.From, from.category, args.To, to.category)
+	}
+	base := args.Value*from.factor + from.offset
+	return jsonResult(map[string]any{
+		"value":  args.Value,
+		"from":   args.From,
+		"to":     args.To,
+		"result": formatNumber((base - to.offset) / to.factor),
+	})
+}
+
+// defaultRates 内置的参考汇率（每 1 美元可兑换的货币数量），仅供闲聊估算，
+// 需要准确汇率时通过 tools.currencyRates 配置覆盖
+var defaultRates = map[string]float64{
+	"USD": 1,
+	"CNY": 7.1,
+	"EUR": 0.92,
+	"GBP": 0.79,
+	"JPY": 150,
+	"KRW": 1350,
+	"HKD": 7.8,
+	"TWD": 32,
+	"SGD": 1.35,
+	"AUD": 1.52,
+	"CAD": 1.36,
+	"CHF": 0.88,
+	"THB": 36,
+	"RUB": 90,
+	"INR": 83,
+}
+
+const defaultRatesDate = "2024-06-01"
+
+// rateTable 本地汇率表，所有汇率均以 base 货币为基准
+type rateTable struct {
+	base  string
+	date  string
+	rates map[string]float64
+}
+
+// newRateTable 配置了 currencyRates 时完全使用配置的汇率，否则使用内置参考汇率
+func newRateTable(cfg *config.ToolsConfig) (*rateTable, error) {
+	t := &rateTable{base: "USD", date: defaultRatesDate, rates: defaultRates}
+	if len(cfg.CurrencyRates) == 0 {
+		return t, nil
+	}
+	t.base = strings.ToUpper(cfg.RatesBase)
+	if t.base == "" {
+		t.base = "USD"
+	}
+	t.date = cfg.RatesDate
+	// viper 会把配置中的键转为小写，这里统一转回大写的货币代码
+	t.rates = make(map[string]float64, len(cfg.CurrencyRates)+1)
+	for code, rate := range cfg.CurrencyRates {
+		if rate <= 0 {
+			return nil, fmt.Errorf("tools.currencyRates.%s must be positive", code)
+		}
+		t.rates[strings.ToUpper(code)] = rate
+	}
+	t.rates[t.base] = 1
+	return t, nil
+}
+
+func (t *rateTable) tool() *Tool {
+	return &Tool{
+		Name: NameConvertCurrency,
+		Description: fmt.Sprintf("按本地汇率表换算货币，汇率日期 %s，结果仅供参考。支持的货币：%s",
+			t.dateLabel(), strings.Join(slices.Sorted(maps.Keys(t.rates)), ", ")),
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"amount": map[string]any{"type": "number"},
+				"from":   map[string]any{"type": "string", "description": "原货币的 ISO 4217 代码，如 CNY"},
+				"to":     map[string]any{"type": "string", "description": "目标货币的 ISO 4217 代码，如 USD"},
+			},
+			"required": []string{"amount", "from", "to"},
+		},
+		Handler: t.convert,
+	}
+}
+
+func (t *rateTable) dateLabel() string {
+	if t.date == "" {
+		return "未知"
+	}
+	return t.date
+}
+
+func (t *rateTable) convert(_ context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Amount float64 `json:"amount"`
+		From   string  `json:"from"`
+		To     string  `json:"to"`
+	}
+	if err := decodeArgs(arguments, &args); err != nil {
+		return "", err
+	}
+	from, to := strings.ToUpper(strings.TrimSpace(args.From)), strings.ToUpper(strings.TrimSpace(args.To))
+	fromRate, ok := t.rates[from]
+	if !ok {
+		return "", fmt.Errorf("unsupported currency %q", args.From)
+	}
+	toRate, ok := t.rates[to]
+	if !ok {
+		return "", fmt.Errorf("unsupported currency %q", args.To)
+	}
+	return jsonResult(map[string]any{
+		"amount":    args.Amount,
+		"from":      from,
+		"to":        to,
+		"result":    formatNumber(roundTo(args.Amount/fromRate*toRate, 4)),
+		"rate":      formatNumber(roundTo(toRate/fromRate, 6)),
+		"ratesDate": t.dateLabel(),
+	})
+}
+
+func roundTo(v float64, digits int) float64 {
+	scale := math.Pow(10, float64(digits))
+	return math.Round(v*scale) / scale
+}
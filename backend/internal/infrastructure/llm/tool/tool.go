@@ -0,0 +1,138 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// Handler 工具的执行函数，arguments 为模型给出的 JSON 参数，返回值作为工具结果交给模型
+type Handler func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// defaultTimeout 工具未设置超时时使用
+const defaultTimeout = 5 * time.Second
+
+// Tool 可由模型调用的 Go 工具
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters 参数的 JSON Schema
+	Parameters map[string]any
+	Handler    Handler
+	// Timeout 单次调用的超时，为 0 时使用 defaultTimeout
+	Timeout time.Duration
+}
+
+// Definition 转换为发送给模型的工具定义
+func (t *Tool) Definition() llm.Tool {
+	return llm.Tool{
+		Name:        t.Name,
+		Description: t.Description,
+		Parameters:  t.Parameters,
+	}
+}
+
+// Registry 按名称管理已注册的工具，按注册顺序提供给模型
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]*Tool
+	order []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]*Tool)}
+}
+
+// Register 注册工具，名称重复时返回错误
+func (r *Registry) Register(t *Tool) error {
+	if t.Name == "" || t.Handler == nil {
+		return fmt.Errorf("tool name and handler are required")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[t.Name]; ok {
+		return fmt.Errorf("tool %s is already registered", t.Name)
+	}
+	r.tools[t.Name] = t
+	r.order = append(r.order, t.Name)
+	return nil
+}
+
+// Definitions 返回启用的工具定义：enabled 为空时启用全部工具，disabled 中的工具始终排除
+func (r *Registry) Definitions(enabled, disabled []string) []llm.Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	defs := make([]llm.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		if len(enabled) > 0 && !slices.Contains(enabled, name) {
+			continue
+		}
+		if slices.Contains(disabled, name) {
+			continue
+		}
+		defs = append(defs, r.tools[name].Definition())
+	}
+	return defs
+}
+
+// Call 执行一次工具调用并返回 tool 角色的结果消息。
+// 工具不存在或执行失败时把错误写进结果，让模型自行处理，而不是中断整个对话
+func (r *Registry) Call(ctx context.Context, call llm.ToolCall) llm.Message {
+	result := llm.Message{Role: llm.RoleTool, ToolCallID: call.ID, Name: call.Name}
+	r.mu.RLock()
+	t, ok := r.tools[call.Name]
+	r.mu.RUnlock()
+	if !ok {
+		result.Content = fmt.Sprintf("error: unknown tool %s", call.Name)
+		return result
+	}
+	args := json.RawMessage(call.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	content, err := t.call(ctx, args)
+	if err != nil {
+		logger.WithField("tool", call.Name).Warn("tool call failed: " + err.Error())
+		result.Content = "error: " + err.Error()
+		return result
+	}
+	result.Content = content
+	return result
+}
+
+// call 在超时时间内执行工具，Handler 不响应 ctx 时也能按时返回
+func (t *Tool) call(ctx context.Context, args json.RawMessage) (string, error) {
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		content string
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("tool panicked: %v", r)}
+			}
+		}()
+		content, err := t.Handler(ctx, args)
+		done <- result{content, err}
+	}()
+	select {
+	case res := <-done:
+		return res.content, res.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("tool %s timed out after %s", t.Name, timeout)
+	}
+}
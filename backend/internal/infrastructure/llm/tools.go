@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// ParamTools 工具定义在参数校验中的名称
+const ParamTools = "tools"
+
+// FinishReasonToolCalls 模型停止生成以等待工具结果
+const FinishReasonToolCalls = "tool_calls"
+
+// SupportsTools 判断 Handle 是否支持工具调用
+func SupportsTools(h Handle) bool {
+	s, ok := h.(ToolSupporter)
+	return ok && s.SupportsTools()
+}
+
+// ToolCalls 返回第一个候选回复中的工具调用
+func (r *ChatResponse) ToolCalls() []ToolCall {
+	if r == nil || len(r.Choices) == 0 {
+		return nil
+	}
+	return r.Choices[0].ToolCalls
+}
+
+// validateTools 校验工具定义，supported 为 false 时拒绝携带工具的请求
+func validateTools(provider string, tools []Tool, supported bool) error {
+	if len(tools) == 0 {
+		return nil
+	}
+	if !supported {
+		return &ValidationError{Param: ParamTools, Reason: fmt.Sprintf("not supported by %s", provider)}
+	}
+	seen := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		if tool.Name == "" {
+			return &ValidationError{Param: ParamTools, Reason: "tool name is required"}
+		}
+		if seen[tool.Name] {
+			return &ValidationError{Param: ParamTools, Reason: fmt.Sprintf("duplicate tool %s", tool.Name)}
+		}
+		seen[tool.Name] = true
+	}
+	return nil
+}
+
+// toolParameters 未声明参数的工具按无参数处理，部分后端要求 parameters 必须是 object
+func toolParameters(tool Tool) map[string]any {
+	if tool.Parameters != nil {
+		return tool.Parameters
+	}
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+// toolCallOptions 转换为 langchaingo 的工具参数
+func toolCallOptions(tools []Tool) []llms.CallOption {
+	if len(tools) == 0 {
+		return nil
+	}
+	defs := make([]llms.Tool, 0, len(tools))
+	for _, tool := range tools {
+		defs = append(defs, llms.Tool{
+			Type: "function",
+			Function: &llms.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  toolParameters(tool),
+			},
+		})
+	}
+	return []llms.CallOption{llms.WithTools(defs)}
+}
+
+func toolCallsFromLLM(calls []llms.ToolCall) []ToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	res := make([]ToolCall, 0, len(calls))
+	for _, call := range calls {
+		if call.FunctionCall == nil {
+			continue
+		}
+		res = append(res, ToolCall{
+			ID:        call.ID,
+			Name:      call.FunctionCall.Name,
+			Arguments: call.FunctionCall.Arguments,
+		})
+	}
+	return res
+}
+
+// streamedToolCall langchaingo 的 OpenAI 客户端在流式回调中传递的工具调用增量
+type streamedToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function *struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// toolDeltaParser 解析 OpenAI 流式回调中的工具调用增量。langchaingo 将增量序列化为
+// JSON 数组，与普通文本共用同一个回调，因此只在请求携带工具时尝试解析
+type toolDeltaParser struct {
+	count int
+}
+
+// parse 返回解析出的增量，ok 为 false 表示该分块是普通文本
+func (p *toolDeltaParser) parse(chunk []byte) ([]ToolCallDelta, bool) {
+	if len(chunk) == 0 || chunk[0] != '[' {
+		return nil, false
+	}
+	var calls []streamedToolCall
+	if err := json.Unmarshal(chunk, &calls); err != nil || len(calls) == 0 {
+		return nil, false
+	}
+	deltas := make([]ToolCallDelta, 0, len(calls))
+	for _, call := range calls {
+		if call.Function == nil {
+			return nil, false
+		}
+		// 带 type 的增量是一次新调用的开头，其余增量续接在最近一次调用上
+		if call.Type != "" {
+			p.count++
+		}
+		deltas = append(deltas, ToolCallDelta{
+			Index:     max(p.count-1, 0),
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return deltas, true
+}
@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// responseHint 记录一次调用中上游最后一个 HTTP 响应的状态码与 Retry-After。
+// langchaingo 的客户端只把状态码拼进错误信息并丢弃响应头，因此在 Transport 层截获
+type responseHint struct {
+	mu         sync.Mutex
+	statusCode int
+	retryAfter time.Duration
+}
+
+type responseHintKey struct{}
+
+// withResponseHint 在 ctx 中挂载一个新的 responseHint，经由该 ctx 发出的请求会写入它
+func withResponseHint(ctx context.Context) (context.Context, *responseHint) {
+	hint := &responseHint{}
+	return context.WithValue(ctx, responseHintKey{}, hint), hint
+}
+
+func (h *responseHint) get() (int, time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.statusCode, h.retryAfter
+}
+
+// hintTransport 将响应状态码与 Retry-After 写入请求 ctx 中的 responseHint，
+// ctx 中挂载了 reasoning 截获函数时同时解析响应中的推理内容
+type hintTransport struct {
+	base http.RoundTripper
+}
+
+// newHTTPClient 各 provider 共用的 HTTP 客户端
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: &hintTransport{base: http.DefaultTransport}}
+}
+
+func (t *hintTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if hint, ok := req.Context().Value(responseHintKey{}).(*responseHint); ok {
+		hint.mu.Lock()
+		hint.statusCode = resp.StatusCode
+		hint.retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		hint.mu.Unlock()
+	}
+	if onReasoning, ok := req.Context().Value(reasoningTapKey{}).(func(string)); ok && resp.StatusCode == http.StatusOK {
+		resp.Body = &reasoningReader{
+			body:        resp.Body,
+			onReasoning: onReasoning,
+			stream:      strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"),
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter 解析 Retry-After，支持秒数与 HTTP 日期两种格式
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
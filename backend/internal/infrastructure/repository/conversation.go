@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+)
+
+const conversationColumns = "id, user_id, title, summary, created_at, updated_at, archived_at, active_message_id"
+
+// ConversationRepository 基于 SQL 的会话存储
+type ConversationRepository struct {
+	db *database.DB
+}
+
+func NewConversationRepository(db *database.DB) *ConversationRepository {
+	return &ConversationRepository{db: db}
+}
+
+func (r *ConversationRepository) Create(ctx context.Context, conv *conversation_domain.Conversation) error {
+	_, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"INSERT INTO conversations ("+conversationColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?)"),
+		conv.ID, conv.UserID, conv.Title, conv.Summary, toMillis(conv.CreatedAt), toMillis(conv.UpdatedAt),
+		archivedMillis(conv.ArchivedAt), conv.ActiveMessageID)
+	return err
+}
+
+func (r *ConversationRepository) Get(ctx context.Context, id string) (*conversation_domain.Conversation, error) {
+	row := r.db.QueryRowContext(ctx, r.db.Rebind(
+		"SELECT "+conversationColumns+" FROM conversations WHERE id = ?"), id)
+	return scanConversation(row)
+}
+
+func (r *ConversationRepository) Latest(ctx context.Context, userID string) (*conversation_domain.Conversation, error) {
+	row := r.db.QueryRowContext(ctx, r.db.Rebind(
+		"SELECT "+conversationColumns+" FROM conversations WHERE user_id = ? AND archived_at = 0 ORDER BY updated_at DESC, id DESC LIMIT 1"), userID)
+	return scanConversation(row)
+}
+
+func (r *ConversationRepository) List(ctx context.Context, query conversation_domain.ListQuery) ([]*conversation_domain.Conversation, int64, error) {
+	where := "user_id = ? AND archived_at = 0"
+	if query.Archived {
+		where = "user_id = ? AND archived_at > 0"
+	}
+	var total int64
+	if err := r.db.QueryRowContext(ctx, r.db.Rebind(
+		"SELECT COUNT(*) FROM conversations WHERE "+where), query.UserID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := r.db.QueryContext(ctx, r.db.Rebind(
+		"SELECT "+conversationColumns+" FROM conversations WHERE "+where+" ORDER BY updated_at DESC, id DESC LIMIT ? OFFSET ?"),
+		query.UserID, query.Limit, query.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var res []*conversation_domain.Conversation
+	for rows.Next() {
+		conv, err := scanConversation(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		res = append(res, conv)
+	}
+	return res, total, rows.Err()
+}
+
+func (r *ConversationRepository) Rename(ctx context.Context, id, title string) error {
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"UPDATE conversations SET title = ? WHERE id = ?"), title, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *ConversationRepository) SetActive(ctx context.Context, id, messageID string) error {
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"UPDATE conversations SET active_message_id = ? WHERE id = ?"), messageID, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *ConversationRepository) SetArchived(ctx context.Context, id string, archivedAt *time.Time) error {
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"UPDATE conversations SET archived_at = ? WHERE id = ?"), archivedMillis(archivedAt), id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *ConversationRepository) UpdateSummary(ctx context.Context, id, summary string) error {
+	res, err := r.db.ExecContext(ctx, r.db.Rebind(
+		"UPDATE conversations SET summary = ? WHERE id = ?"), summary, id)
+	if err != nil {
+		return err
+	}
+	return expectAffected(res)
+}
+
+func (r *ConversationRepository) Delete(ctx context.Context, id string) error {
+	// SQLite 的外键级联依赖连接上的 foreign_keys 设置，这里显式删除消息，两种数据库行为一致
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, r.db.Rebind("DELETE FROM messages WHERE conversation_id = ?"), id); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, r.db.Rebind("DELETE FROM conversations WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+	if err := expectAffected(res); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// rowScanner 兼容 *sql.Row 与 *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanConversation(row rowScanner) (*conversation_domain.Conversation, error) {
+	var conv conversation_domain.Conversation
+	var createdAt, updatedAt, archivedAt int64
+	err := row.Scan(&conv.ID, &conv.UserID, &conv.Title, &conv.Summary, &createdAt, &updatedAt, &archivedAt, &conv.ActiveMessageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, conversation_domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	conv.CreatedAt, conv.UpdatedAt = fromMillis(createdAt), fromMillis(updatedAt)
+	if archivedAt > 0 {
+		t := fromMillis(archivedAt)
+		conv.ArchivedAt = &t
+	}
+	return &conv, nil
+}
+
+func expectAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return conversation_domain.ErrNotFound
+	}
+	return nil
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms)
+}
+
+// archivedMillis 未归档的会话以 0 保存
+func archivedMillis(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return toMillis(*t)
+}
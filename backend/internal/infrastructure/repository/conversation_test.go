@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	db, err := database.Open(&config.DatabaseConfig{
+		Driver:      database.DriverSQLite,
+		Path:        filepath.Join(t.TempDir(), "test.db"),
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func createConversation(t *testing.T, conversations *ConversationRepository, id, userID string, at time.Time) {
+	t.Helper()
+	if err := conversations.Create(context.Background(), &conversation_domain.Conversation{
+		ID: id, UserID: userID, Title: "title of " + id, CreatedAt: at, UpdatedAt: at,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConversationCreateAndGet(t *testing.T) {
+	ctx := context.Background()
+	conversations := NewConversationRepository(openTestDB(t))
+	at := time.UnixMilli(1_700_000_000_000)
+	createConversation(t, conversations, "c1", "u1", at)
+
+	conv, err := conversations.Get(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.UserID != "u1" || conv.Title != "title of c1" || conv.Summary != "" || !conv.CreatedAt.Equal(at) || !conv.UpdatedAt.Equal(at) {
+		t.Errorf("Get = %+v", conv)
+	}
+	if _, err := conversations.Get(ctx, "missing"); !errors.Is(err, conversation_domain.ErrNotFound) {
+		t.Errorf("Get missing: err = %v", err)
+	}
+	// 主键冲突
+	if err := conversations.Create(ctx, &conversation_domain.Conversation{ID: "c1", UserID: "u2"}); err == nil {
+		t.Error("Create duplicate: want error")
+	}
+
+	if err := conversations.UpdateSummary(ctx, "c1", "聊了天气"); err != nil {
+		t.Fatal(err)
+	}
+	if conv, _ := conversations.Get(ctx, "c1"); conv.Summary != "聊了天气" {
+		t.Errorf("summary = %q", conv.Summary)
+	}
+	if err := conversations.UpdateSummary(ctx, "missing", "x"); !errors.Is(err, conversation_domain.ErrNotFound) {
+		t.Errorf("UpdateSummary missing: err = %v", err)
+	}
+}
+
+func TestConversationLatestPerUser(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	conversations, messages := NewConversationRepository(db), NewMessageRepository(db)
+	start := time.UnixMilli(1_700_000_000_000)
+	createConversation(t, conversations, "a1", "u1", start)
+	createConversation(t, conversations, "a2", "u1", start.Add(time.Second))
+	createConversation(t, conversations, "b1", "u2", start.Add(2*time.Second))
+
+	latest := func(userID string) string {
+		t.Helper()
+		conv, err := conversations.Latest(ctx, userID)
+		if errors.Is(err, conversation_domain.ErrNotFound) {
+			return ""
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conv.ID
+	}
+	// 只返回该用户自己的会话
+	if got := latest("u1"); got != "a2" {
+		t.Errorf("Latest(u1) = %q, want a2", got)
+	}
+	if got := latest("u2"); got != "b1" {
+		t.Errorf("Latest(u2) = %q, want b1", got)
+	}
+	if got := latest("u3"); got != "" {
+		t.Errorf("Latest(u3) = %q, want none", got)
+	}
+
+	// 追加消息会更新会话的活跃时间
+	at := start.Add(time.Minute)
+	if err := messages.Append(ctx, &conversation_domain.Message{
+		ID: "m1", ConversationID: "a1", Role: "user", Content: "你好", CreatedAt: at, UpdatedAt: at,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := latest("u1"); got != "a1" {
+		t.Errorf("Latest(u1) after append = %q, want a1", got)
+	}
+	if conv, _ := conversations.Get(ctx, "a1"); !conv.UpdatedAt.Equal(at) {
+		t.Errorf("updated_at = %v, want %v", conv.UpdatedAt, at)
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+)
+
+const messageColumns = "id, conversation_id, parent_id, role, content, model, finish_reason, " +
+	"prompt_tokens, completion_tokens, total_tokens, created_at, updated_at"
+
+// pathQuery 从分支末尾沿 parent_id 回溯到会话开头，depth 为距末尾的条数
+const pathQuery = `WITH RECURSIVE path (id, depth) AS (
+	SELECT id, 0 FROM messages WHERE id = ?
+	UNION ALL
+	SELECT m.parent_id, path.depth + 1 FROM messages m JOIN path ON m.id = path.id WHERE m.parent_id <> ''
+)
+SELECT ` + "m.id, m.conversation_id, m.parent_id, m.role, m.content, m.model, m.finish_reason, " +
+	"m.prompt_tokens, m.completion_tokens, m.total_tokens, m.created_at, m.updated_at" + `
+FROM messages m JOIN path ON m.id = path.id ORDER BY path.depth`
+
+// MessageRepository 基于 SQL 的消息存储
+type MessageRepository struct {
+	db *database.DB
+}
+
+func NewMessageRepository(db *database.DB) *MessageRepository {
+	return &MessageRepository{db: db}
+}
+
+func (r *MessageRepository) Append(ctx context.Context, messages ...*conversation_domain.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	insert := r.db.Rebind("INSERT INTO messages (" + messageColumns + ") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	// 每个会话最后追加的消息成为当前分支的末尾
+	last := make(map[string]*conversation_domain.Message)
+	for _, m := range messages {
+		_, err := tx.ExecContext(ctx, insert,
+			m.ID, m.ConversationID, m.ParentID, m.Role, m.Content, m.Model, m.FinishReason,
+			m.PromptTokens, m.CompletionTokens, m.TotalTokens, toMillis(m.CreatedAt), toMillis(m.UpdatedAt))
+		if err != nil {
+			return err
+		}
+		last[m.ConversationID] = m
+	}
+	for id, m := range last {
+		at := toMillis(m.CreatedAt)
+		_, err := tx.ExecContext(ctx, r.db.Rebind(
+			"UPDATE conversations SET active_message_id = ?, updated_at = CASE WHEN updated_at < ? THEN ? ELSE updated_at END WHERE id = ?"),
+			m.ID, at, at, id)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *MessageRepository) Get(ctx context.Context, id string) (*conversation_domain.Message, error) {
+	res, err := r.query(ctx, "SELECT "+messageColumns+" FROM messages WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, conversation_domain.ErrNotFound
+	}
+	return res[0], nil
+}
+
+func (r *MessageRepository) GetMany(ctx context.Context, ids []string) ([]*conversation_domain.Message, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	return r.query(ctx, "SELECT "+messageColumns+" FROM messages WHERE id IN ("+placeholders+")", args...)
+}
+
+func (r *MessageRepository) Path(ctx context.Context, leafID string, limit int) ([]*conversation_domain.Message, error) {
+	query, args := pathQuery, []any{leafID}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	res, err := r.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(res)
+	return res, nil
+}
+
+func (r *MessageRepository) Nodes(ctx context.Context, conversationID string) ([]*conversation_domain.MessageNode, error) {
+	rows, err := r.db.QueryContext(ctx, r.db.Rebind(
+		"SELECT id, parent_id, seq, created_at FROM messages WHERE conversation_id = ? ORDER BY seq"), conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []*conversation_domain.MessageNode
+	for rows.Next() {
+		var node conversation_domain.MessageNode
+		var createdAt int64
+		if err := rows.Scan(&node.ID, &node.ParentID, &node.Seq, &createdAt); err != nil {
+			return nil, err
+		}
+		node.CreatedAt = fromMillis(createdAt)
+		res = append(res, &node)
+	}
+	return res, rows.Err()
+}
+
+func (r *MessageRepository) List(ctx context.Context, conversationID string, offset, limit int) ([]*conversation_domain.Message, int64, error) {
+	var total int64
+	if err := r.db.QueryRowContext(ctx, r.db.Rebind(
+		"SELECT COUNT(*) FROM messages WHERE conversation_id = ?"), conversationID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	res, err := r.query(ctx,
+		"SELECT "+messageColumns+" FROM messages WHERE conversation_id = ? ORDER BY seq LIMIT ? OFFSET ?",
+		conversationID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return res, total, nil
+}
+
+func (r *MessageRepository) query(ctx context.Context, query string, args ...any) ([]*conversation_domain.Message, error) {
+	rows, err := r.db.QueryContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []*conversation_domain.Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
+
+func scanMessage(row rowScanner) (*conversation_domain.Message, error) {
+	var m conversation_domain.Message
+	var createdAt, updatedAt int64
+	err := row.Scan(&m.ID, &m.ConversationID, &m.ParentID, &m.Role, &m.Content, &m.Model, &m.FinishReason,
+		&m.PromptTokens, &m.CompletionTokens, &m.TotalTokens, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	m.CreatedAt, m.UpdatedAt = fromMillis(createdAt), fromMillis(updatedAt)
+	return &m, nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+)
+
+// newTestConversation 创建一个会话，返回会话与消息的存储
+func newTestConversation(t *testing.T, id string) (*ConversationRepository, *MessageRepository) {
+	t.Helper()
+	db := openTestDB(t)
+	conversations, messages := NewConversationRepository(db), NewMessageRepository(db)
+	now := time.Now()
+	if err := conversations.Create(context.Background(), &conversation_domain.Conversation{
+		ID: id, UserID: "u1", CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return conversations, messages
+}
+
+func testMessage(id, parentID, role string, at time.Time) *conversation_domain.Message {
+	return &conversation_domain.Message{
+		ID: id, ConversationID: "c1", ParentID: parentID, Role: role, Content: "content of " + id,
+		CreatedAt: at, UpdatedAt: at,
+	}
+}
+
+func messageIDs(messages []*conversation_domain.Message) []string {
+	ids := make([]string, len(messages))
+	for i, m := range messages {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+// appendChain 追加一条 n 条消息组成的单链，返回按时间顺序排列的消息ID
+func appendChain(t *testing.T, messages *MessageRepository, n int) []string {
+	t.Helper()
+	start := time.UnixMilli(1_700_000_000_000)
+	var chain []*conversation_domain.Message
+	parent := ""
+	for i := range n {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		m := testMessage(string(rune('a'+i)), parent, role, start.Add(time.Duration(i)*time.Second))
+		chain = append(chain, m)
+		parent = m.ID
+	}
+	if err := messages.Append(context.Background(), chain...); err != nil {
+		t.Fatal(err)
+	}
+	return messageIDs(chain)
+}
+
+func TestMessageAppendAndGet(t *testing.T) {
+	ctx := context.Background()
+	conversations, messages := newTestConversation(t, "c1")
+	ids := appendChain(t, messages, 3)
+
+	m, err := messages.Get(ctx, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ParentID != "a" || m.Role != "assistant" || m.Content != "content of b" || m.CreatedAt.UnixMilli() != 1_700_000_001_000 {
+		t.Errorf("Get = %+v", m)
+	}
+	if _, err := messages.Get(ctx, "missing"); !errors.Is(err, conversation_domain.ErrNotFound) {
+		t.Errorf("Get missing: err = %v", err)
+	}
+
+	many, err := messages.GetMany(ctx, []string{"c", "missing", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := messageIDs(many)
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"a", "c"}) {
+		t.Errorf("GetMany = %v", got)
+	}
+
+	// 最后追加的消息成为当前分支的末尾
+	conv, err := conversations.Get(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.ActiveMessageID != ids[len(ids)-1] {
+		t.Errorf("active message = %q, want %q", conv.ActiveMessageID, ids[len(ids)-1])
+	}
+}
+
+func TestMessagePath(t *testing.T) {
+	ctx := context.Background()
+	_, messages := newTestConversation(t, "c1")
+	ids := appendChain(t, messages, 5)
+	// 在 b 之后产生另一条分支
+	at := time.UnixMilli(1_700_000_010_000)
+	if err := messages.Append(ctx, testMessage("x", "b", "user", at), testMessage("y", "x", "assistant", at)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		leaf  string
+		limit int
+		want  []string
+	}{
+		{"e", 0, ids},
+		{"e", 2, []string{"d", "e"}},
+		{"e", 5, ids},
+		{"e", 10, ids},
+		{"c", 1, []string{"c"}},
+		{"y", 0, []string{"a", "b", "x", "y"}},
+		{"y", 3, []string{"b", "x", "y"}},
+		{"missing", 0, nil},
+	}
+	for _, tt := range tests {
+		path, err := messages.Path(ctx, tt.leaf, tt.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := messageIDs(path); !slices.Equal(got, tt.want) {
+			t.Errorf("Path(%s, %d) = %v, want %v", tt.leaf, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestMessageSiblingOrder(t *testing.T) {
+	ctx := context.Background()
+	_, messages := newTestConversation(t, "c1")
+	appendChain(t, messages, 2)
+
+	// 同一毫秒内的多次重新生成按写入顺序排列，而不是按随机的消息ID
+	at := time.UnixMilli(1_700_000_005_000)
+	siblings := []string{"z", "m", "c"}
+	for _, id := range siblings {
+		if err := messages.Append(ctx, testMessage(id, "a", "assistant", at)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nodes, err := messages.Nodes(ctx, "c1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for i, n := range nodes {
+		if i > 0 && n.Seq <= nodes[i-1].Seq {
+			t.Errorf("seq of %s = %d is not after %d", n.ID, n.Seq, nodes[i-1].Seq)
+		}
+		if n.ParentID == "a" {
+			order = append(order, n.ID)
+		}
+	}
+	if want := append([]string{"b"}, siblings...); !slices.Equal(order, want) {
+		t.Errorf("children of a = %v, want %v", order, want)
+	}
+
+	list, total, err := messages.List(ctx, "c1", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := messageIDs(list); total != 5 || !slices.Equal(got, []string{"b", "z", "m", "c"}) {
+		t.Errorf("List = %v, total = %d", got, total)
+	}
+}
+
+func TestMessageList(t *testing.T) {
+	ctx := context.Background()
+	_, messages := newTestConversation(t, "c1")
+	ids := appendChain(t, messages, 5)
+
+	// 按写入顺序分页
+	page, total, err := messages.List(ctx, "c1", 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || !slices.Equal(messageIDs(page), ids[1:4]) {
+		t.Errorf("List = %v (total %d), want %v (total 5)", messageIDs(page), total, ids[1:4])
+	}
+	if page, total, _ := messages.List(ctx, "missing", 0, 10); len(page) != 0 || total != 0 {
+		t.Errorf("List of missing conversation = %v (total %d)", messageIDs(page), total)
+	}
+
+	// 同一批写入中任意一条失败则整体回滚
+	at := time.UnixMilli(1_700_000_100_000)
+	if err := messages.Append(ctx, testMessage("f", "e", "assistant", at), testMessage("a", "", "user", at)); err == nil {
+		t.Fatal("Append duplicate: want error")
+	}
+	if _, total, _ := messages.List(ctx, "c1", 0, 10); total != 5 {
+		t.Errorf("messages after failed append = %d, want 5", total)
+	}
+}
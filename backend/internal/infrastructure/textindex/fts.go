@@ -0,0 +1,150 @@
+package textindex
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+)
+
+// ftsTable 保存切分后以空格分隔的词项，rowid 与 messages 表的 rowid 相同。
+// 分词在写入前完成，unicode61 只需按空格还原；关闭去除变音符号，与内存索引的匹配保持一致
+const ftsTable = `CREATE VIRTUAL TABLE IF NOT EXISTS message_search USING fts5(terms, tokenize = 'unicode61 remove_diacritics 0')`
+
+// ftsIndex 基于 SQLite FTS5 的索引
+type ftsIndex struct {
+	db *database.DB
+}
+
+// OpenFTS 在 SQLite 中创建 FTS5 索引表，并补全尚未索引的消息。
+// 索引表由消息派生、随时可以重建，不放在迁移中：FTS5 不可用时改用内存索引，表结构不受影响
+func OpenFTS(ctx context.Context, db *database.DB) (Index, error) {
+	if db.Driver != database.DriverSQLite {
+		return nil, fmt.Errorf("fts5 requires sqlite, database driver is %s", db.Driver)
+	}
+	if _, err := db.ExecContext(ctx, ftsTable); err != nil {
+		return nil, fmt.Errorf("create fts5 table: %w", err)
+	}
+	idx := &ftsIndex{db: db}
+	// 清理已删除的消息，并补上启用搜索之前或写入索引失败的消息
+	if err := idx.Remove(ctx, ""); err != nil {
+		return nil, err
+	}
+	docs, err := loadDocuments(ctx, db, "WHERE m.rowid NOT IN (SELECT rowid FROM message_search)")
+	if err != nil {
+		return nil, err
+	}
+	if err := idx.Add(ctx, docs...); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *ftsIndex) Backend() string {
+	return BackendFTS5
+}
+
+func (idx *ftsIndex) Add(ctx context.Context, docs ...*Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	tx, err := idx.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for _, d := range docs {
+		if _, err := tx.ExecContext(ctx,
+			"DELETE FROM message_search WHERE rowid = (SELECT rowid FROM messages WHERE id = ?)", d.MessageID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO message_search (rowid, terms) SELECT rowid, ? FROM messages WHERE id = ?",
+			strings.Join(Terms(d.Content), " "), d.MessageID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Remove 会话删除后其消息已不在 messages 表中，这里清理全部没有对应消息的词项
+func (idx *ftsIndex) Remove(ctx context.Context, _ string) error {
+	_, err := idx.db.ExecContext(ctx, "DELETE FROM message_search WHERE rowid NOT IN (SELECT rowid FROM messages)")
+	return err
+}
+
+func (idx *ftsIndex) Search(ctx context.Context, q *Query) ([]Hit, int64, error) {
+	if len(q.Terms) == 0 {
+		return nil, 0, nil
+	}
+	phrases := make([]string, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		phrases = append(phrases, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+	}
+	where := []string{"message_search MATCH ?", "c.user_id = ?"}
+	args := []any{strings.Join(phrases, " "), q.UserID}
+	if q.ConversationID != "" {
+		where, args = append(where, "m.conversation_id = ?"), append(args, q.ConversationID)
+	}
+	if q.Role != "" {
+		where, args = append(where, "m.role = ?"), append(args, q.Role)
+	}
+	if !q.From.IsZero() {
+		where, args = append(where, "m.created_at >= ?"), append(args, q.From.UnixMilli())
+	}
+	if !q.To.IsZero() {
+		where, args = append(where, "m.created_at < ?"), append(args, q.To.UnixMilli())
+	}
+	from := " FROM message_search JOIN messages m ON m.rowid = message_search.rowid" +
+		" JOIN conversations c ON c.id = m.conversation_id WHERE " + strings.Join(where, " AND ")
+
+	var total int64
+	if err := idx.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+	// bm25() 越小越相关，取相反数作为分数
+	rows, err := idx.db.QueryContext(ctx,
+		"SELECT m.id, -bm25(message_search) AS score"+from+" ORDER BY score DESC, m.created_at DESC LIMIT ? OFFSET ?",
+		append(args, limit, q.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var hits []Hit
+	for rows.Next() {
+		var h Hit
+		if err := rows.Scan(&h.MessageID, &h.Score); err != nil {
+			return nil, 0, err
+		}
+		hits = append(hits, h)
+	}
+	return hits, total, rows.Err()
+}
+
+// loadDocuments 从数据库读取待索引的消息，where 为附加的条件
+func loadDocuments(ctx context.Context, db *database.DB, where string) ([]*Document, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT m.id, m.conversation_id, c.user_id, m.role, m.content, m.created_at"+
+			" FROM messages m JOIN conversations c ON c.id = m.conversation_id "+where)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []*Document
+	for rows.Next() {
+		var d Document
+		var createdAt int64
+		if err := rows.Scan(&d.MessageID, &d.ConversationID, &d.UserID, &d.Role, &d.Content, &createdAt); err != nil {
+			return nil, err
+		}
+		d.CreatedAt = time.UnixMilli(createdAt)
+		res = append(res, &d)
+	}
+	return res, rows.Err()
+}
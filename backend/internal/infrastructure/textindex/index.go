@@ -0,0 +1,235 @@
+// Package textindex 聊天消息的全文索引：SQLite 上使用 FTS5，其他数据库或 FTS5 不可用时使用内存中的倒排索引。
+// 两种实现共用同一套分词，中日韩文字按单字与相邻两字切分，检索结果按 BM25 排序
+package textindex
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+)
+
+// 索引的实现
+const (
+	BackendFTS5   = "fts5"
+	BackendMemory = "memory"
+)
+
+// BM25 的参数，与 SQLite FTS5 的默认值相同
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// bm25MinIDF 半数以上的消息都包含的词项 IDF 不大于 0，与 FTS5 一样取一个极小的正数
+	bm25MinIDF = 1e-6
+)
+
+// Document 待索引的一条消息
+type Document struct {
+	MessageID      string
+	ConversationID string
+	UserID         string
+	Role           string
+	Content        string
+	CreatedAt      time.Time
+}
+
+// Query 检索条件，Terms 须全部命中。ConversationID、Role 为空以及 From、To 为零值时不限制，To 不含在范围内
+type Query struct {
+	UserID         string
+	Terms          []string
+	ConversationID string
+	Role           string
+	From, To       time.Time
+	Offset, Limit  int
+}
+
+// Hit 命中的消息，Score 越大越相关
+type Hit struct {
+	MessageID string
+	Score     float64
+}
+
+// Index 消息的全文索引，可并发使用
+type Index interface {
+	// Backend 返回索引的实现
+	Backend() string
+	// Add 写入消息，消息已在索引中时覆盖
+	Add(ctx context.Context, docs ...*Document) error
+	// Remove 移除一个会话的全部消息，在会话从数据库删除之后调用
+	Remove(ctx context.Context, conversationID string) error
+	// Search 按相关度从高到低返回一页命中的消息及命中总数
+	Search(ctx context.Context, q *Query) ([]Hit, int64, error)
+}
+
+// memoryDocument 内存索引中的一条消息
+type memoryDocument struct {
+	*Document
+	// terms 消息包含的词项及出现次数
+	terms  map[string]int
+	length int
+}
+
+// memoryIndex 内存中的倒排索引，创建时从数据库加载全部消息
+type memoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[string]*memoryDocument
+	postings map[string]map[string]*memoryDocument
+	// totalLength 全部消息的词项数之和，用于计算平均长度
+	totalLength int
+}
+
+// OpenMemory 创建内存索引并加载数据库中已有的消息
+func OpenMemory(ctx context.Context, db *database.DB) (Index, error) {
+	idx := &memoryIndex{docs: make(map[string]*memoryDocument), postings: make(map[string]map[string]*memoryDocument)}
+	docs, err := loadDocuments(ctx, db, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := idx.Add(ctx, docs...); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *memoryIndex) Backend() string {
+	return BackendMemory
+}
+
+func (idx *memoryIndex) Add(_ context.Context, docs ...*Document) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, d := range docs {
+		idx.removeLocked(d.MessageID)
+		terms := Terms(d.Content)
+		// 检索结果由调用方从数据库读取原文，索引中不保留
+		meta := *d
+		meta.Content = ""
+		doc := &memoryDocument{Document: &meta, terms: make(map[string]int), length: len(terms)}
+		for _, t := range terms {
+			doc.terms[t]++
+		}
+		for t := range doc.terms {
+			if idx.postings[t] == nil {
+				idx.postings[t] = make(map[string]*memoryDocument)
+			}
+			idx.postings[t][d.MessageID] = doc
+		}
+		idx.docs[d.MessageID] = doc
+		idx.totalLength += doc.length
+	}
+	return nil
+}
+
+func (idx *memoryIndex) Remove(_ context.Context, conversationID string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for id, doc := range idx.docs {
+		if doc.ConversationID == conversationID {
+			idx.removeLocked(id)
+		}
+	}
+	return nil
+}
+
+func (idx *memoryIndex) removeLocked(id string) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for t := range doc.terms {
+		delete(idx.postings[t], id)
+		if len(idx.postings[t]) == 0 {
+			delete(idx.postings, t)
+		}
+	}
+	delete(idx.docs, id)
+	idx.totalLength -= doc.length
+}
+
+func (idx *memoryIndex) Search(_ context.Context, q *Query) ([]Hit, int64, error) {
+	if len(q.Terms) == 0 {
+		return nil, 0, nil
+	}
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// 从最少的倒排表开始逐条检查
+	postings := make([]map[string]*memoryDocument, 0, len(q.Terms))
+	for _, t := range q.Terms {
+		if len(idx.postings[t]) == 0 {
+			return nil, 0, nil
+		}
+		postings = append(postings, idx.postings[t])
+	}
+	slices.SortFunc(postings, func(a, b map[string]*memoryDocument) int { return len(a) - len(b) })
+
+	n := float64(len(idx.docs))
+	avgLength := float64(idx.totalLength) / n
+	type match struct {
+		doc   *memoryDocument
+		score float64
+	}
+	var matches []match
+	for _, doc := range postings[0] {
+		if !doc.matches(q) {
+			continue
+		}
+		score := 0.0
+		for _, t := range q.Terms {
+			tf, ok := doc.terms[t]
+			if !ok {
+				score = -1
+				break
+			}
+			df := float64(len(idx.postings[t]))
+			idf := math.Log((n - df + 0.5) / (df + 0.5))
+			if idf <= 0 {
+				idf = bm25MinIDF
+			}
+			score += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*(1-bm25B+bm25B*float64(doc.length)/avgLength))
+		}
+		if score >= 0 {
+			matches = append(matches, match{doc: doc, score: score})
+		}
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		if a.score != b.score {
+			if a.score > b.score {
+				return -1
+			}
+			return 1
+		}
+		return b.doc.CreatedAt.Compare(a.doc.CreatedAt)
+	})
+
+	total := int64(len(matches))
+	matches = matches[min(q.Offset, len(matches)):]
+	if q.Limit > 0 && len(matches) > q.Limit {
+		matches = matches[:q.Limit]
+	}
+	hits := make([]Hit, 0, len(matches))
+	for _, m := range matches {
+		hits = append(hits, Hit{MessageID: m.doc.MessageID, Score: m.score})
+	}
+	return hits, total, nil
+}
+
+// matches 检查消息是否满足词项以外的条件
+func (doc *memoryDocument) matches(q *Query) bool {
+	switch {
+	case doc.UserID != q.UserID:
+		return false
+	case q.ConversationID != "" && doc.ConversationID != q.ConversationID:
+		return false
+	case q.Role != "" && doc.Role != q.Role:
+		return false
+	case !q.From.IsZero() && doc.CreatedAt.Before(q.From):
+		return false
+	case !q.To.IsZero() && !doc.CreatedAt.Before(q.To):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,198 @@
+package textindex
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+	"github.com/ai-companion/backend/internal/infrastructure/repository"
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// testStart 测试消息的起始时间，第 i 条消息在其后 i 分钟写入
+var testStart = time.UnixMilli(1_700_000_000_000)
+
+func at(i int) time.Time {
+	return testStart.Add(time.Duration(i) * time.Minute)
+}
+
+// testMessages 会话 c1、c2 属于 u1，c3 属于 u2
+var testMessages = []struct {
+	id, conversationID, role, content string
+}{
+	{"m1", "c1", "user", "我今天去公园散步了"},
+	{"m2", "c1", "assistant", "公园散步很健康！今天天气怎么样？"},
+	{"m3", "c1", "user", "I went hiking with my dog"},
+	{"m4", "c2", "user", "周末想去爬山，顺便带上狗狗"},
+	{"m5", "c2", "assistant", "爬山记得带水。Hiking is fun, hiking is good!"},
+	{"m6", "c2", "user", "ＡＢＣ全角字母 abc"},
+	{"m7", "c3", "user", "我也喜欢在公园散步"},
+	{"m8", "c1", "user", "Café naïve résumé"},
+}
+
+// openTestDB 创建写入了 testMessages 的数据库
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+	ctx := context.Background()
+	db, err := database.Open(&config.DatabaseConfig{
+		Driver:      database.DriverSQLite,
+		Path:        filepath.Join(t.TempDir(), "test.db"),
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	conversations, messages := repository.NewConversationRepository(db), repository.NewMessageRepository(db)
+	for id, user := range map[string]string{"c1": "u1", "c2": "u1", "c3": "u2"} {
+		if err := conversations.Create(ctx, &conversation_domain.Conversation{
+			ID: id, UserID: user, CreatedAt: testStart, UpdatedAt: testStart,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, m := range testMessages {
+		if err := messages.Append(ctx, &conversation_domain.Message{
+			ID: m.id, ConversationID: m.conversationID, Role: m.role, Content: m.content, CreatedAt: at(i), UpdatedAt: at(i),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// openIndexes 在同一个数据库上打开两种索引
+func openIndexes(t *testing.T, db *database.DB) []Index {
+	t.Helper()
+	ctx := context.Background()
+	fts, err := OpenFTS(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory, err := OpenMemory(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []Index{fts, memory}
+}
+
+// searchAll 在每个索引上执行同一个检索，要求命中的消息、顺序、总数与分数一致，返回命中的消息ID与总数
+func searchAll(t *testing.T, indexes []Index, q *Query) ([]string, int64) {
+	t.Helper()
+	var first []Hit
+	var firstTotal int64
+	for i, idx := range indexes {
+		hits, total, err := idx.Search(context.Background(), q)
+		if err != nil {
+			t.Fatalf("%s: %v", idx.Backend(), err)
+		}
+		if i == 0 {
+			first, firstTotal = hits, total
+			continue
+		}
+		same := total == firstTotal && slices.EqualFunc(hits, first, func(a, b Hit) bool {
+			return a.MessageID == b.MessageID && math.Abs(a.Score-b.Score) < 1e-6
+		})
+		if !same {
+			t.Errorf("%s: hits = %v (total %d), %s: hits = %v (total %d)",
+				indexes[0].Backend(), first, firstTotal, idx.Backend(), hits, total)
+		}
+	}
+	ids := make([]string, len(first))
+	for i, h := range first {
+		ids[i] = h.MessageID
+	}
+	return ids, firstTotal
+}
+
+func TestSearchBackendsAgree(t *testing.T) {
+	indexes := openIndexes(t, openTestDB(t))
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+		total int64
+	}{
+		{"cjk word", Query{Terms: QueryTerms("公园")}, []string{"m1", "m2"}, 2},
+		{"cjk phrase", Query{Terms: QueryTerms("公园散步")}, []string{"m1", "m2"}, 2},
+		{"cjk single char", Query{Terms: QueryTerms("狗")}, []string{"m4"}, 1},
+		{"cjk not adjacent", Query{Terms: QueryTerms("园步")}, nil, 0},
+		{"cjk and latin", Query{Terms: QueryTerms("爬山 hiking")}, []string{"m5"}, 1},
+		{"shorter message first", Query{Terms: QueryTerms("hiking")}, []string{"m3", "m5"}, 2},
+		{"case folded", Query{Terms: QueryTerms("HIKING Dog")}, []string{"m3"}, 1},
+		{"fullwidth", Query{Terms: QueryTerms("abc")}, []string{"m6"}, 1},
+		{"diacritics kept", Query{Terms: QueryTerms("café")}, []string{"m8"}, 1},
+		{"diacritics differ", Query{Terms: QueryTerms("cafe")}, nil, 0},
+		{"other user", Query{UserID: "u2", Terms: QueryTerms("公园")}, []string{"m7"}, 1},
+		{"conversation", Query{ConversationID: "c2", Terms: QueryTerms("爬山")}, []string{"m5", "m4"}, 2},
+		{"conversation without hits", Query{ConversationID: "c2", Terms: QueryTerms("公园")}, nil, 0},
+		{"role", Query{Role: "assistant", Terms: QueryTerms("公园")}, []string{"m2"}, 1},
+		{"time range", Query{From: at(1), To: at(4), Terms: QueryTerms("hiking")}, []string{"m3"}, 1},
+		{"to excluded", Query{To: at(2), Terms: QueryTerms("hiking")}, nil, 0},
+		{"page", Query{Offset: 1, Limit: 1, Terms: QueryTerms("公园")}, []string{"m2"}, 2},
+		{"offset past end", Query{Offset: 5, Terms: QueryTerms("公园")}, nil, 2},
+		{"no match", Query{Terms: QueryTerms("不存在")}, nil, 0},
+		{"no terms", Query{Terms: QueryTerms("，。！")}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			if q.UserID == "" {
+				q.UserID = "u1"
+			}
+			ids, total := searchAll(t, indexes, &q)
+			if !slices.Equal(ids, tt.want) || total != tt.total {
+				t.Errorf("hits = %v (total %d), want %v (total %d)", ids, total, tt.want, tt.total)
+			}
+		})
+	}
+}
+
+func TestIndexUpdateAndRemove(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+	indexes := openIndexes(t, db)
+	hiking := &Query{UserID: "u1", Terms: QueryTerms("hiking")}
+
+	// 覆盖已索引的消息
+	updated := &Document{MessageID: "m3", ConversationID: "c1", UserID: "u1", Role: "user", Content: "我去游泳了", CreatedAt: at(2)}
+	if _, err := db.ExecContext(ctx, "UPDATE messages SET content = ? WHERE id = ?", updated.Content, updated.MessageID); err != nil {
+		t.Fatal(err)
+	}
+	for _, idx := range indexes {
+		if err := idx.Add(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids, _ := searchAll(t, indexes, hiking); !slices.Equal(ids, []string{"m5"}) {
+		t.Errorf("hiking after update = %v", ids)
+	}
+	if ids, _ := searchAll(t, indexes, &Query{UserID: "u1", Terms: QueryTerms("游泳")}); !slices.Equal(ids, []string{"m3"}) {
+		t.Errorf("游泳 after update = %v", ids)
+	}
+
+	// 会话从数据库删除后移除其消息
+	if err := repository.NewConversationRepository(db).Delete(ctx, "c2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, idx := range indexes {
+		if err := idx.Remove(ctx, "c2"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ids, total := searchAll(t, indexes, hiking); len(ids) != 0 || total != 0 {
+		t.Errorf("hiking after remove = %v (total %d)", ids, total)
+	}
+
+	// 重新打开时从数据库补全索引
+	reopened := openIndexes(t, db)
+	if ids, _ := searchAll(t, reopened, &Query{UserID: "u1", Terms: QueryTerms("公园")}); !slices.Equal(ids, []string{"m1", "m2"}) {
+		t.Errorf("公园 after reopen = %v", ids)
+	}
+}
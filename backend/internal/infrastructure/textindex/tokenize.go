@@ -0,0 +1,188 @@
+package textindex
+
+import (
+	"html"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 高亮片段的默认参数
+const (
+	// DefaultSnippetLength 片段保留的字数
+	DefaultSnippetLength = 80
+	// snippetLead 片段在第一个命中之前保留的字数
+	snippetLead = 16
+)
+
+// 高亮标记，片段中的其余文本经过 HTML 转义
+const (
+	markOpen  = "<mark>"
+	markClose = "</mark>"
+)
+
+// token 文本中的一个词项，Start 与 End 为在原文中的字节位置
+type token struct {
+	Term       string
+	Start, End int
+}
+
+// isCJK 中日韩文字没有空格分词，按单字与相邻两字切分
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// fold 全角字母数字转为半角并转为小写
+func fold(r rune) rune {
+	if r >= '！' && r <= '～' {
+		r -= '！' - '!'
+	}
+	return unicode.ToLower(r)
+}
+
+// tokenize 切分文本：字母数字连续的部分为一个词，中日韩文字产生单字与相邻两字的词项。
+// query 为 true 时按检索词切分，两字以上的中日韩文字只产生相邻两字的词项，要求全部命中即近似于短语匹配
+func tokenize(text string, query bool) []token {
+	var res []token
+	var run []token
+	cjk := false
+	flush := func() {
+		if len(run) == 0 {
+			return
+		}
+		if !cjk {
+			var b strings.Builder
+			for _, t := range run {
+				b.WriteString(t.Term)
+			}
+			res = append(res, token{Term: b.String(), Start: run[0].Start, End: run[len(run)-1].End})
+		} else {
+			for i, t := range run {
+				if !query || len(run) == 1 {
+					res = append(res, t)
+				}
+				if i > 0 {
+					res = append(res, token{Term: run[i-1].Term + t.Term, Start: run[i-1].Start, End: t.End})
+				}
+			}
+		}
+		run = run[:0]
+	}
+	for i, r := range text {
+		end := i + utf8.RuneLen(r)
+		switch {
+		case r == utf8.RuneError:
+			flush()
+		case isCJK(r):
+			if !cjk {
+				flush()
+			}
+			cjk = true
+			run = append(run, token{Term: string(r), Start: i, End: end})
+		case isWordRune(fold(r)):
+			if cjk {
+				flush()
+			}
+			cjk = false
+			run = append(run, token{Term: string(fold(r)), Start: i, End: end})
+		default:
+			flush()
+		}
+	}
+	flush()
+	return res
+}
+
+// Terms 返回文本用于建立索引的全部词项，可重复
+func Terms(text string) []string {
+	tokens := tokenize(text, false)
+	res := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		res = append(res, t.Term)
+	}
+	return res
+}
+
+// QueryTerms 返回检索词切分出的词项，已去重
+func QueryTerms(query string) []string {
+	var res []string
+	for _, t := range tokenize(query, true) {
+		if !slices.Contains(res, t.Term) {
+			res = append(res, t.Term)
+		}
+	}
+	return res
+}
+
+// Snippet 截取 text 中第一处命中附近约 length 字的片段，命中的部分以 <mark> 标记，其余文本经过 HTML 转义。
+// 换行等空白替换为空格，被截断的一端以 … 表示
+func Snippet(text string, terms []string, length int) string {
+	if length <= 0 {
+		length = DefaultSnippetLength
+	}
+	// 替换前后字节数不变，词项的位置依然有效
+	text = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == '\t' {
+			return ' '
+		}
+		return r
+	}, text)
+
+	var spans [][2]int
+	for _, t := range tokenize(text, false) {
+		if !slices.Contains(terms, t.Term) {
+			continue
+		}
+		if n := len(spans); n > 0 && t.Start <= spans[n-1][1] {
+			spans[n-1][1] = max(spans[n-1][1], t.End)
+			continue
+		}
+		spans = append(spans, [2]int{t.Start, t.End})
+	}
+
+	// 片段从第一个命中之前 snippetLead 个字开始，末尾不足时向前补足
+	runes := utf8.RuneCountInString(text)
+	first := 0
+	if len(spans) > 0 {
+		first = utf8.RuneCountInString(text[:spans[0][0]])
+	}
+	startRune := max(0, min(first-snippetLead, runes-length))
+	start := byteOffset(text, startRune)
+	end := byteOffset(text, startRune+length)
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, span := range spans {
+		s, e := max(span[0], start), min(span[1], end)
+		if s >= e {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:s]))
+		b.WriteString(markOpen + html.EscapeString(text[s:e]) + markClose)
+		pos = e
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString("…")
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// byteOffset 返回第 n 个字在 text 中的字节位置，超出时返回文本长度
+func byteOffset(text string, n int) int {
+	for i := range text {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(text)
+}
@@ -0,0 +1,252 @@
+// Package vectorindex 纯 Go 实现的本地向量索引：按命名空间隔离，暴力计算余弦相似度，
+// 写入以追加日志的方式持久化到单个文件，打开时回放日志并在无效记录过多时压缩
+package vectorindex
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrDimension 向量维度与索引中已有的向量不一致
+var ErrDimension = errors.New("vector dimension mismatch")
+
+// Item 索引中的一条记录
+type Item struct {
+	ID        string
+	Namespace string
+	Text      string
+	Metadata  map[string]string
+	CreatedAt time.Time
+	Vector    []float32
+}
+
+// Result 检索结果，Score 为余弦相似度
+type Result struct {
+	Item
+	Score float64
+}
+
+// Index 向量索引，可并发使用
+type Index struct {
+	mu         sync.RWMutex
+	items      map[string]*Item
+	namespaces map[string][]*Item
+	dimension  int
+	log        *appendLog
+}
+
+// Open 打开 path 处的索引文件并回放其中的记录，文件不存在时创建；path 为空时只保存在内存中
+func Open(path string) (*Index, error) {
+	idx := &Index{items: make(map[string]*Item), namespaces: make(map[string][]*Item)}
+	if path == "" {
+		return idx, nil
+	}
+	log, err := openLog(path, idx.apply)
+	if err != nil {
+		return nil, err
+	}
+	idx.log = log
+	// 覆盖与删除产生的无效记录超过一半时重写文件
+	if log.records > 1024 && log.records > 2*len(idx.items) {
+		if err := idx.compactLocked(); err != nil {
+			_ = log.close()
+			return nil, fmt.Errorf("compact %s: %w", path, err)
+		}
+	}
+	return idx, nil
+}
+
+// Len 返回索引中的记录数
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.items)
+}
+
+// Add 写入记录，ID 已存在时覆盖。向量在写入前归一化
+func (idx *Index) Add(items ...Item) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for i := range items {
+		item := items[i]
+		if item.ID == "" {
+			return errors.New("item id is required")
+		}
+		if len(item.Vector) == 0 {
+			return fmt.Errorf("item %s: vector is empty", item.ID)
+		}
+		if idx.dimension != 0 && len(item.Vector) != idx.dimension {
+			return fmt.Errorf("item %s: %w: got %d, index has %d", item.ID, ErrDimension, len(item.Vector), idx.dimension)
+		}
+		item.Vector = normalized(item.Vector)
+		if idx.log != nil {
+			if err := idx.log.append(opPut, &item); err != nil {
+				return err
+			}
+		}
+		idx.apply(opPut, &item)
+	}
+	return nil
+}
+
+// Delete 删除记录，不存在的 ID 会被忽略
+func (idx *Index) Delete(ids ...string) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := idx.items[id]; !ok {
+			continue
+		}
+		if idx.log != nil {
+			if err := idx.log.append(opDelete, &Item{ID: id}); err != nil {
+				return err
+			}
+		}
+		idx.apply(opDelete, &Item{ID: id})
+	}
+	return nil
+}
+
+// DeleteFunc 删除命名空间中 match 返回 true 的记录，返回删除的条数
+func (idx *Index) DeleteFunc(namespace string, match func(item *Item) bool) (int, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	var ids []string
+	for _, item := range idx.namespaces[namespace] {
+		if match(item) {
+			ids = append(ids, item.ID)
+		}
+	}
+	for i, id := range ids {
+		if idx.log != nil {
+			if err := idx.log.append(opDelete, &Item{ID: id}); err != nil {
+				return i, err
+			}
+		}
+		idx.apply(opDelete, &Item{ID: id})
+	}
+	return len(ids), nil
+}
+
+// Search 返回与 query 最相似、相似度不低于 minScore 的至多 k 条记录，按相似度从高到低排列。
+// namespace 为空时在全部命名空间中检索
+func (idx *Index) Search(namespace string, query []float32, k int, minScore float64) []Result {
+	if k <= 0 || len(query) == 0 {
+		return nil
+	}
+	query = normalized(query)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if len(query) != idx.dimension {
+		return nil
+	}
+	var results []Result
+	visit := func(items []*Item) {
+		for _, item := range items {
+			score := dot(query, item.Vector)
+			if score >= minScore {
+				results = append(results, Result{Item: *item, Score: score})
+			}
+		}
+	}
+	if namespace != "" {
+		visit(idx.namespaces[namespace])
+	} else {
+		for _, items := range idx.namespaces {
+			visit(items)
+		}
+	}
+	slices.SortFunc(results, func(a, b Result) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// Compact 重写索引文件，只保留有效记录
+func (idx *Index) Compact() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.compactLocked()
+}
+
+// Close 把写入落盘并关闭文件
+func (idx *Index) Close() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if idx.log == nil {
+		return nil
+	}
+	err := idx.log.close()
+	idx.log = nil
+	return err
+}
+
+func (idx *Index) compactLocked() error {
+	if idx.log == nil {
+		return nil
+	}
+	items := make([]*Item, 0, len(idx.items))
+	for _, item := range idx.items {
+		items = append(items, item)
+	}
+	// 按写入时间排列，回放顺序与原日志一致
+	slices.SortFunc(items, func(a, b *Item) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return idx.log.rewrite(items)
+}
+
+// apply 在内存中执行一条记录，调用方持有写锁或处于打开阶段
+func (idx *Index) apply(op byte, item *Item) {
+	if old, ok := idx.items[item.ID]; ok {
+		idx.namespaces[old.Namespace] = slices.DeleteFunc(idx.namespaces[old.Namespace], func(i *Item) bool { return i == old })
+		if len(idx.namespaces[old.Namespace]) == 0 {
+			delete(idx.namespaces, old.Namespace)
+		}
+		delete(idx.items, item.ID)
+	}
+	if op != opPut {
+		return
+	}
+	stored := *item
+	idx.items[item.ID] = &stored
+	idx.namespaces[item.Namespace] = append(idx.namespaces[item.Namespace], &stored)
+	if idx.dimension == 0 {
+		idx.dimension = len(item.Vector)
+	}
+}
+
+func normalized(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	res := make([]float32, len(v))
+	if norm == 0 {
+		return res
+	}
+	scale := 1 / math.Sqrt(norm)
+	for i, x := range v {
+		res[i] = float32(float64(x) * scale)
+	}
+	return res
+}
+
+func dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
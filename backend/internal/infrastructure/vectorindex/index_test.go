@@ -0,0 +1,159 @@
+package vectorindex
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func resultIDs(results []Result) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.ID
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	idx, _ := Open("")
+	if err := idx.Add(
+		testItem("a", "u1", 1, 0),
+		testItem("bb", "u1", 1, 1),
+		testItem("ccc", "u1", 0, 1),
+		testItem("dddd", "u2", 2, 0),
+	); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		namespace string
+		query     []float32
+		k         int
+		minScore  float64
+		want      string
+	}{
+		{"ranked by similarity", "u1", []float32{3, 1}, 3, -1, "[a bb ccc]"},
+		{"top k", "u1", []float32{3, 1}, 2, -1, "[a bb]"},
+		{"min score", "u1", []float32{1, 0}, 3, 0.5, "[a bb]"},
+		// 同分时较新的在前
+		{"all namespaces", "", []float32{1, 0}, 2, 0.9, "[dddd a]"},
+		{"other namespace", "u2", []float32{0, 1}, 3, -1, "[dddd]"},
+		{"unknown namespace", "u3", []float32{1, 0}, 3, -1, "[]"},
+		{"dimension mismatch", "u1", []float32{1, 0, 0}, 3, -1, "[]"},
+		{"k is zero", "u1", []float32{1, 0}, 0, -1, "[]"},
+	}
+	for _, tt := range tests {
+		results := idx.Search(tt.namespace, tt.query, tt.k, tt.minScore)
+		if got := fmt.Sprint(resultIDs(results)); got != tt.want {
+			t.Errorf("%s: Search = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if r := idx.Search("u1", []float32{1, 1}, 1, 0); len(r) != 1 || math.Abs(r[0].Score-1) > 1e-6 {
+		t.Errorf("score of identical direction = %+v", r)
+	}
+}
+
+func TestAddAndDelete(t *testing.T) {
+	idx, _ := Open("")
+	if err := idx.Add(testItem("a", "u1", 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Add(testItem("b", "u1", 1, 0, 0)); !errors.Is(err, ErrDimension) {
+		t.Errorf("dimension mismatch: err = %v", err)
+	}
+	if err := idx.Add(testItem("", "u1", 1, 0)); err == nil {
+		t.Error("empty id: want error")
+	}
+	if err := idx.Add(testItem("c", "u1")); err == nil {
+		t.Error("empty vector: want error")
+	}
+
+	// 覆盖写入时可以换到另一个命名空间
+	if err := idx.Add(testItem("a", "u2", 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if idx.Len() != 1 || len(idx.Search("u1", []float32{1, 0}, 5, -1)) != 0 {
+		t.Errorf("overwritten item is still in its old namespace")
+	}
+
+	idx.Add(testItem("b", "u2", 1, 1), testItem("c", "u2", 1, 2), testItem("d", "u1", 1, 0))
+	n, err := idx.DeleteFunc("u2", func(item *Item) bool { return item.ID != "c" })
+	if err != nil || n != 2 {
+		t.Errorf("DeleteFunc = %d, %v", n, err)
+	}
+	if err := idx.Delete("c", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(resultIDs(idx.Search("", []float32{1, 0}, 5, -1))); got != "[d]" {
+		t.Errorf("remaining = %s, want [d]", got)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.bin")
+	idx, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 50 {
+		if err := idx.Add(testItem(fmt.Sprint("a", i%5), "u1", float32(i), 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx.Delete("a0")
+	before, _ := os.Stat(path)
+	if err := idx.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size()/5 {
+		t.Errorf("size after compact = %d, before %d", after.Size(), before.Size())
+	}
+	// 压缩后继续追加到新文件
+	if err := idx.Add(testItem("b", "u2", 1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	idx.Close()
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if reopened.Len() != 5 {
+		t.Errorf("Len after reopen = %d, want 5", reopened.Len())
+	}
+	// 保留的是每个 ID 最后一次写入的向量
+	if r := reopened.Search("u1", []float32{49, 1}, 1, 0); len(r) != 1 || r[0].ID != "a4" || r[0].Score < 0.9999 {
+		t.Errorf("Search after reopen = %+v", r)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary file left behind: %v", err)
+	}
+}
+
+func TestOpenCompactsStaleRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.bin")
+	idx, _ := Open(path)
+	for i := range 1100 {
+		if err := idx.Add(testItem(fmt.Sprint("a", i%10), "u1", 1, float32(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idx.Close()
+	before, _ := os.Stat(path)
+
+	// 无效记录超过一半时打开即压缩
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	after, _ := os.Stat(path)
+	if reopened.Len() != 10 || reopened.log.records != 10 || after.Size() >= before.Size() {
+		t.Errorf("Len = %d, records = %d, size %d -> %d", reopened.Len(), reopened.log.records, before.Size(), after.Size())
+	}
+}
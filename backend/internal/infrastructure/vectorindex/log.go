@@ -0,0 +1,275 @@
+package vectorindex
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 日志记录类型
+const (
+	opPut    byte = 1
+	opDelete byte = 2
+)
+
+// fileMagic 文件头，末位为格式版本
+var fileMagic = []byte("AIVX\x01")
+
+// maxRecordSize 单条记录的上限，超出视为文件损坏
+const maxRecordSize = 64 << 20
+
+// appendLog 追加写入的记录文件。每条记录为：uvarint 长度 + 内容 + CRC32，
+// 进程在写入中途退出留下的不完整尾部会在下次打开时截断
+type appendLog struct {
+	path    string
+	file    *os.File
+	records int
+}
+
+func openLog(path string, apply func(op byte, item *Item)) (*appendLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	l := &appendLog{path: path, file: file}
+	valid, err := l.replay(apply)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := file.Truncate(valid); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if valid == 0 {
+		if _, err := file.Write(fileMagic); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// replay 依次回放文件中的记录，返回最后一条完整记录的结束位置
+func (l *appendLog) replay(apply func(op byte, item *Item)) (int64, error) {
+	r := bufio.NewReader(l.file)
+	header := make([]byte, len(fileMagic))
+	if _, err := io.ReadFull(r, header); err != nil {
+		// 空文件或只写了一部分文件头
+		return 0, nil
+	}
+	if !bytes.Equal(header, fileMagic) {
+		return 0, errors.New("not a vector index file")
+	}
+	offset := int64(len(fileMagic))
+	for {
+		size, err := binary.ReadUvarint(r)
+		if err != nil || size > maxRecordSize {
+			return offset, nil
+		}
+		frame := make([]byte, size+4)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			return offset, nil
+		}
+		payload := frame[:size]
+		if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(frame[size:]) {
+			return offset, nil
+		}
+		op, item, err := decodeRecord(payload)
+		if err != nil {
+			return offset, nil
+		}
+		apply(op, item)
+		l.records++
+		offset += int64(uvarintLen(size)) + int64(size) + 4
+	}
+}
+
+func (l *appendLog) append(op byte, item *Item) error {
+	if _, err := l.file.Write(frame(encodeRecord(op, item))); err != nil {
+		return err
+	}
+	l.records++
+	return nil
+}
+
+// rewrite 把 items 写入临时文件后替换原文件
+func (l *appendLog) rewrite(items []*Item) error {
+	tmp := l.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	_, _ = w.Write(fileMagic)
+	for _, item := range items {
+		_, _ = w.Write(frame(encodeRecord(opPut, item)))
+	}
+	if err := w.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		_ = file.Close()
+		return err
+	}
+	_ = l.file.Close()
+	l.file = file
+	l.records = len(items)
+	_, err = file.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (l *appendLog) close() error {
+	if err := l.file.Sync(); err != nil {
+		_ = l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
+
+func frame(payload []byte) []byte {
+	buf := binary.AppendUvarint(make([]byte, 0, len(payload)+binary.MaxVarintLen64+4), uint64(len(payload)))
+	buf = append(buf, payload...)
+	return binary.LittleEndian.AppendUint32(buf, crc32.ChecksumIEEE(payload))
+}
+
+func encodeRecord(op byte, item *Item) []byte {
+	buf := []byte{op}
+	buf = appendString(buf, item.ID)
+	if op != opPut {
+		return buf
+	}
+	buf = appendString(buf, item.Namespace)
+	buf = appendString(buf, item.Text)
+	buf = binary.AppendVarint(buf, item.CreatedAt.UnixNano())
+	buf = binary.AppendUvarint(buf, uint64(len(item.Metadata)))
+	for k, v := range item.Metadata {
+		buf = appendString(buf, k)
+		buf = appendString(buf, v)
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(item.Vector)))
+	for _, x := range item.Vector {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(x))
+	}
+	return buf
+}
+
+func decodeRecord(payload []byte) (byte, *Item, error) {
+	d := decoder{buf: payload}
+	op := d.byte()
+	item := &Item{ID: d.string()}
+	if op == opPut {
+		item.Namespace = d.string()
+		item.Text = d.string()
+		item.CreatedAt = time.Unix(0, d.varint())
+		if n := d.uvarint(); n > 0 && d.err == nil {
+			item.Metadata = make(map[string]string, min(n, 64))
+			for range n {
+				k := d.string()
+				item.Metadata[k] = d.string()
+				if d.err != nil {
+					break
+				}
+			}
+		}
+		dim := d.uvarint()
+		if d.err == nil && dim*4 > uint64(len(d.buf)) {
+			d.err = io.ErrUnexpectedEOF
+		}
+		if d.err == nil {
+			item.Vector = make([]float32, dim)
+			for i := range item.Vector {
+				item.Vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(d.buf[i*4:]))
+			}
+			d.buf = d.buf[dim*4:]
+		}
+	} else if op != opDelete {
+		return 0, nil, fmt.Errorf("unknown record type %d", op)
+	}
+	return op, item, d.err
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func uvarintLen(x uint64) int {
+	return len(binary.AppendUvarint(nil, x))
+}
+
+// decoder 顺序解码记录内容，出错后的读取均返回零值
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil || len(d.buf) == 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return x
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return x
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > uint64(len(d.buf)) {
+		d.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	s := string(d.buf[:n])
+	d.buf = d.buf[n:]
+	return s
+}
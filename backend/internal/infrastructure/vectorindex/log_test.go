@@ -0,0 +1,185 @@
+package vectorindex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testItem(id, namespace string, vector ...float32) Item {
+	return Item{
+		ID:        id,
+		Namespace: namespace,
+		Text:      "text of " + id,
+		Metadata:  map[string]string{"conversation": "c-" + id},
+		CreatedAt: time.Unix(1_700_000_000, 0).Add(time.Duration(len(id)) * time.Second),
+		Vector:    vector,
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	item := testItem("a1", "u1", 0.6, 0.8)
+	item.Metadata["message"] = "你好"
+	op, got, err := decodeRecord(encodeRecord(opPut, &item))
+	if err != nil || op != opPut {
+		t.Fatalf("decode = %d, %v", op, err)
+	}
+	if !reflect.DeepEqual(*got, item) || !got.CreatedAt.Equal(item.CreatedAt) {
+		t.Errorf("decoded = %+v, want %+v", *got, item)
+	}
+
+	op, got, err = decodeRecord(encodeRecord(opDelete, &Item{ID: "a1"}))
+	if err != nil || op != opDelete || got.ID != "a1" {
+		t.Errorf("decode delete = %d, %+v, %v", op, got, err)
+	}
+
+	// 截断的记录与未知类型都报错
+	payload := encodeRecord(opPut, &item)
+	for i := 1; i < len(payload); i++ {
+		if _, _, err := decodeRecord(payload[:i]); err == nil {
+			t.Fatalf("decode of %d/%d bytes: want error", i, len(payload))
+		}
+	}
+	if _, _, err := decodeRecord([]byte{9, 0}); err == nil {
+		t.Error("unknown record type: want error")
+	}
+}
+
+func TestFrame(t *testing.T) {
+	payload := encodeRecord(opDelete, &Item{ID: "a1"})
+	buf := frame(payload)
+	size, n := binary.Uvarint(buf)
+	if int(size) != len(payload) || n != uvarintLen(size) {
+		t.Fatalf("length prefix = %d (%d bytes)", size, n)
+	}
+	if !bytes.Equal(buf[n:n+len(payload)], payload) {
+		t.Error("payload is not framed verbatim")
+	}
+	if got := binary.LittleEndian.Uint32(buf[n+len(payload):]); got != crc32.ChecksumIEEE(payload) || len(buf) != n+len(payload)+4 {
+		t.Errorf("crc = %x, frame length %d", got, len(buf))
+	}
+}
+
+// writeIndex 写入 items 后关闭，返回文件路径与有效内容的长度
+func writeIndex(t *testing.T, items ...Item) (string, int64) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "recall", "index.bin")
+	idx, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Add(items...); err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path, info.Size()
+}
+
+func appendBytes(t *testing.T, path string, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenTruncatesTornTail(t *testing.T) {
+	next := testItem("a3", "u1", 1, 1)
+	full := frame(encodeRecord(opPut, &next))
+	corrupted := bytes.Clone(full)
+	corrupted[len(corrupted)-1] ^= 0xff
+	tests := []struct {
+		name string
+		tail []byte
+	}{
+		{"partial length prefix", []byte{0x80}},
+		{"partial payload", full[:len(full)/2]},
+		{"missing crc", full[:len(full)-2]},
+		{"crc mismatch", corrupted},
+		{"oversized record", binary.AppendUvarint(nil, maxRecordSize+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, valid := writeIndex(t, testItem("a1", "u1", 1, 0), testItem("a2", "u1", 0, 1))
+			appendBytes(t, path, tt.tail)
+
+			idx, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if idx.Len() != 2 {
+				t.Errorf("Len = %d, want 2", idx.Len())
+			}
+			if info, _ := os.Stat(path); info.Size() != valid {
+				t.Errorf("size after open = %d, want %d", info.Size(), valid)
+			}
+			// 截断后继续追加，重新打开时新旧记录都在
+			if err := idx.Add(next); err != nil {
+				t.Fatal(err)
+			}
+			if err := idx.Close(); err != nil {
+				t.Fatal(err)
+			}
+			reopened, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer reopened.Close()
+			if reopened.Len() != 3 {
+				t.Errorf("Len after reopen = %d, want 3", reopened.Len())
+			}
+			if res := reopened.Search("u1", []float32{1, 1}, 1, 0); len(res) != 1 || res[0].ID != "a3" {
+				t.Errorf("Search after reopen = %+v", res)
+			}
+		})
+	}
+}
+
+func TestOpenHeader(t *testing.T) {
+	dir := t.TempDir()
+	// 只写了一部分文件头时按空索引重新开始
+	partial := filepath.Join(dir, "partial.bin")
+	if err := os.WriteFile(partial, fileMagic[:2], 0o600); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := Open(partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idx.Add(testItem("a1", "u1", 1)); err != nil {
+		t.Fatal(err)
+	}
+	idx.Close()
+	if idx, err := Open(partial); err != nil || idx.Len() != 1 {
+		t.Errorf("reopen = %v, %v", idx, err)
+	} else {
+		idx.Close()
+	}
+
+	// 其他格式的文件不会被截断覆盖
+	foreign := filepath.Join(dir, "foreign.bin")
+	if err := os.WriteFile(foreign, []byte("not an index file"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(foreign); err == nil {
+		t.Error("Open foreign file: want error")
+	}
+	if data, _ := os.ReadFile(foreign); string(data) != "not an index file" {
+		t.Errorf("foreign file was modified: %q", data)
+	}
+}
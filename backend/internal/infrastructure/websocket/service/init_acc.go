@@ -48,7 +48,7 @@ func StartWebSocket() {
 	// 添加处理程序
 	go WebsocketClientManager.start()
 	fmt.Println("StartWebSocket success.")
-	fmt.Println(fmt.Sprintf(`{"serverIp":%s,  "webSocketPort":%s, "rpcPort":%s}`, serverIp, webSocketPort))
+	fmt.Println(fmt.Sprintf(`{"serverIp":%s,  "webSocketPort":%s, "rpcPort":%s}`, serverIp, webSocketPort, config.GetString("app.rpcPort")))
 	_ = http.ListenAndServe(":"+webSocketPort, nil)
 }
 
@@ -3,16 +3,35 @@ package config
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/ai-companion/backend/internal/pkg/logger"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server ServerConfig `mapstructure:"server"`
-	//Database DatabaseConfig `mapstructure:"database"`
+	Server   ServerConfig   `mapstructure:"server"`
+	Database DatabaseConfig `mapstructure:"database"`
 	//Redis RedisConfig `mapstructure:"redis"`
 	LLM LLMConfig `mapstructure:"llm"`
+	// Embedding 向量模型，与聊天模型分开配置
+	Embedding EmbeddingConfig `mapstructure:"embedding"`
+	// Models 具名的模型配置，请求通过 model 字段选择；llm 配置以 "default" 为名一并注册
+	Models       []ModelProfile `mapstructure:"models"`
+	DefaultModel string         `mapstructure:"defaultModel"` // 请求未指定 model 时使用，默认为 default
+	Persona      PersonaConfig  `mapstructure:"persona"`
+	Tools        ToolsConfig    `mapstructure:"tools"`
+	MCP          MCPConfig      `mapstructure:"mcp"`
+	// Summary 长会话的滚动摘要
+	Summary SummaryConfig `mapstructure:"summary"`
+	// Memory 从对话中提取的关于用户的长期记忆
+	Memory MemoryConfig `mapstructure:"memory"`
+	// Recall 基于向量检索的过往对话回忆
+	Recall RecallConfig `mapstructure:"recall"`
+	// Search 聊天记录的全文搜索
+	Search SearchConfig `mapstructure:"search"`
+	// Reasoning 推理模型思考过程的处理方式
+	Reasoning ReasoningConfig `mapstructure:"reasoning"`
 	//TTS      TTSConfig      `mapstructure:"tts"`
 	//ASR      ASRConfig      `mapstructure:"asr"`
 }
@@ -22,13 +41,19 @@ type ServerConfig struct {
 	Mode string `mapstructure:"mode"`
 }
 
+// DatabaseConfig 会话与消息的存储。driver 为 sqlite（默认，内嵌）或 postgres，
+// host 等连接参数仅 postgres 使用
 type DatabaseConfig struct {
+	Driver   string `mapstructure:"driver"`
+	Path     string `mapstructure:"path"` // SQLite 数据库文件，默认 ./data/companion.db
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	User     string `mapstructure:"user"`
 	Password string `mapstructure:"password"`
 	DBName   string `mapstructure:"dbname"`
 	SSLMode  string `mapstructure:"sslmode"`
+	// AutoMigrate 启动时自动执行未执行的迁移；关闭时需先用 migrate 命令升级表结构
+	AutoMigrate bool `mapstructure:"autoMigrate"`
 }
 
 type RedisConfig struct {
@@ -43,6 +68,163 @@ type LLMConfig struct {
 	Model    string `mapstructure:"model"`
 	BaseUrl  string `mapstructure:"baseUrl"`
 	Token    string `mapstructure:"token"`
+	// 以下仅 stateless_llm_with_template 使用
+	Template       string `mapstructure:"template"`       // 聊天模板: chatml/llama3/qwen/alpaca
+	CompletionPath string `mapstructure:"completionPath"` // 非流式补全接口路径，默认 /completion
+	StreamPath     string `mapstructure:"streamPath"`     // 流式补全接口路径，默认与 CompletionPath 相同
+	// 默认生成参数，未配置时使用模型自身的默认值，可被单次请求覆盖
+	Temperature      *float64 `mapstructure:"temperature"`
+	TopP             *float64 `mapstructure:"topP"`
+	MaxTokens        *int     `mapstructure:"maxTokens"`
+	Stop             []string `mapstructure:"stop"`
+	Seed             *int     `mapstructure:"seed"`
+	PresencePenalty  *float64 `mapstructure:"presencePenalty"`
+	FrequencyPenalty *float64 `mapstructure:"frequencyPenalty"`
+	// ContextWindow 模型的上下文长度（token 数），历史过长时裁剪最早的轮次；
+	// 未配置时按模型系列取默认值，ollama_llm 配置后同时作为 num_ctx 发送
+	ContextWindow int `mapstructure:"contextWindow"`
+	// ImplicitThink 模型输出的思考块省略了 <think> 开始标签（如部分 DeepSeek-R1、Qwen3 部署），
+	// 流式输出时把开头到 </think> 之间的内容视为思考过程；非流式响应会自动识别
+	ImplicitThink bool `mapstructure:"implicitThink"`
+	// Retry 失败重试与熔断，配置后 CreateLLM 会为该后端包装一层 RetryLLM
+	Retry RetryConfig `mapstructure:"retry"`
+	// Mock 仅 mock_llm 使用
+	Mock MockConfig `mapstructure:"mock"`
+	// 以下仅 failover_llm 使用
+	Chain    []LLMConfig    `mapstructure:"chain"`    // 按顺序尝试的后端列表
+	Failover FailoverConfig `mapstructure:"failover"` // 故障转移策略
+}
+
+// EmbeddingConfig 文本向量化配置
+type EmbeddingConfig struct {
+	Provider  string `mapstructure:"provider"` // openai 兼容的 provider 名、ollama_llm 或 hash_embedder
+	Model     string `mapstructure:"model"`    // 向量模型，如 text-embedding-3-small、nomic-embed-text
+	BaseUrl   string `mapstructure:"baseUrl"`
+	Token     string `mapstructure:"token"`
+	BatchSize int    `mapstructure:"batchSize"` // 单次请求的最大文本数，默认 64
+	Dimension int    `mapstructure:"dimension"` // 向量维度，hash_embedder 默认 256，其他后端未配置时首次使用时探测
+}
+
+// ModelProfile 一个具名的模型配置
+type ModelProfile struct {
+	Name        string `mapstructure:"name"`
+	Description string `mapstructure:"description"`
+	LLMConfig   `mapstructure:",squash"`
+}
+
+// RetryConfig 单个后端的重试与熔断策略
+type RetryConfig struct {
+	MaxAttempts      int           `mapstructure:"maxAttempts"`      // 最多尝试次数（含首次），小于等于 1 表示不重试
+	InitialBackoff   time.Duration `mapstructure:"initialBackoff"`   // 首次重试的退避上限，之后指数增长，默认 500ms
+	MaxBackoff       time.Duration `mapstructure:"maxBackoff"`       // 单次退避的最大值，默认 10s
+	BreakerThreshold int           `mapstructure:"breakerThreshold"` // 连续失败多少次后熔断，0 表示不启用熔断
+	BreakerCooldown  time.Duration `mapstructure:"breakerCooldown"`  // 熔断持续时间，之后放行一个试探请求，默认 30s
+}
+
+// FailoverConfig 多后端故障转移策略
+type FailoverConfig struct {
+	Timeout          time.Duration `mapstructure:"timeout"`          // 单个后端的超时，流式请求为等待首块的超时，0 表示不限制
+	Cooldown         time.Duration `mapstructure:"cooldown"`         // 后端失败后的冷却时间，冷却期内优先跳过，默认 30s
+	FailureThreshold int           `mapstructure:"failureThreshold"` // 连续失败多少次后进入冷却，默认 1
+}
+
+// MockConfig 离线开发与测试用的模拟模型配置
+type MockConfig struct {
+	Mode       string        `mapstructure:"mode"`       // 回复模式: script(按脚本)/echo(回显)/canned(固定回复)
+	Fixture    string        `mapstructure:"fixture"`    // script 模式的脚本文件，支持 yaml/json
+	Reply      string        `mapstructure:"reply"`      // canned 模式的固定回复，也是 script 无匹配时的兜底回复
+	ChunkSize  int           `mapstructure:"chunkSize"`  // 流式输出时每块的字符数
+	ChunkDelay time.Duration `mapstructure:"chunkDelay"` // 流式输出时每块之间的间隔
+	Error      string        `mapstructure:"error"`      // 配置后每次调用都返回该错误
+}
+
+// PersonaConfig 陪伴角色的人设定义，渲染后作为系统提示词
+type PersonaConfig struct {
+	Name            string           `mapstructure:"name"`            // 角色名称
+	Personality     string           `mapstructure:"personality"`     // 性格描述
+	SpeakingStyle   string           `mapstructure:"speakingStyle"`   // 说话风格
+	ExampleDialogue []DialogueConfig `mapstructure:"exampleDialogue"` // 示例对话
+	Greeting        string           `mapstructure:"greeting"`        // 开场白
+	Instructions    string           `mapstructure:"instructions"`    // 额外的行为要求
+	EnabledTools    []string         `mapstructure:"enabledTools"`    // 允许该角色使用的工具，为空时启用全部工具
+	DisabledTools   []string         `mapstructure:"disabledTools"`   // 禁止该角色使用的工具，优先于 enabledTools
+}
+
+// ToolsConfig 内置工具配置
+type ToolsConfig struct {
+	Timezone      string             `mapstructure:"timezone"`      // 请求未携带时区时使用的默认时区，如 Asia/Shanghai，默认为服务器时区
+	Timeout       time.Duration      `mapstructure:"timeout"`       // 单次工具调用的超时，默认 5s
+	CurrencyRates map[string]float64 `mapstructure:"currencyRates"` // 汇率表：1 单位基准货币可兑换的各币种数量，覆盖内置汇率
+	RatesBase     string             `mapstructure:"ratesBase"`     // 汇率表的基准货币，默认 USD
+	RatesDate     string             `mapstructure:"ratesDate"`     // 汇率表的更新日期，会随换算结果告知模型
+}
+
+// SummaryConfig 长会话的滚动摘要：较早的轮次在后台压缩为摘要，注入之后请求的系统提示词
+type SummaryConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Model     string        `mapstructure:"model"`     // 生成摘要使用的模型配置名（models 中的 name），为空时使用默认模型
+	Every     int           `mapstructure:"every"`     // 最近轮次之前的对话累计多少轮后生成一次摘要，默认 10
+	KeepTurns int           `mapstructure:"keepTurns"` // 始终保留原文、不参与摘要的最近轮次，默认 4
+	MaxLength int           `mapstructure:"maxLength"` // 摘要的最大字数，默认 500
+	Timeout   time.Duration `mapstructure:"timeout"`   // 单次生成摘要的超时，默认 60s
+}
+
+// MemoryConfig 长期记忆：从每轮对话中提取关于用户的事实，按 userId 保存并注入之后的请求
+type MemoryConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Model    string        `mapstructure:"model"`    // 提取事实使用的模型配置名，为空时使用默认模型
+	Path     string        `mapstructure:"path"`     // 记忆的保存文件，为空时只保存在内存中
+	MaxFacts int           `mapstructure:"maxFacts"` // 每个用户最多保存的记忆条数，超出时丢弃最久未更新的，默认 200
+	TopK     int           `mapstructure:"topK"`     // 每次请求注入的记忆条数，配置了 embedding 时按与用户消息的相似度选取，默认 8
+	Timeout  time.Duration `mapstructure:"timeout"`  // 单次提取的超时，默认 60s
+}
+
+// RecallConfig 过往对话回忆：每轮对话向量化后写入本地索引，之后的请求检索相关片段注入系统提示词，需要配置 embedding
+type RecallConfig struct {
+	Enabled   bool     `mapstructure:"enabled"`
+	Path      string   `mapstructure:"path"`      // 索引文件，为空时只保存在内存中
+	Scope     string   `mapstructure:"scope"`     // 检索范围: user(只检索同一用户的对话，默认)/shared(全部用户)
+	TopK      int      `mapstructure:"topK"`      // 每轮最多注入的片段数，默认 3
+	MinScore  *float64 `mapstructure:"minScore"`  // 相似度阈值，低于该值的片段不注入，默认 0.3
+	MaxTokens int      `mapstructure:"maxTokens"` // 注入片段的 token 预算，默认 600
+}
+
+// SearchConfig 聊天记录的全文搜索，需要配置数据库
+type SearchConfig struct {
+	Backend       string `mapstructure:"backend"`       // 索引实现: auto(SQLite 上使用 FTS5，否则使用内存索引，默认)/fts5/memory
+	SnippetLength int    `mapstructure:"snippetLength"` // 结果中高亮片段的字数，默认 80
+}
+
+// ReasoningConfig 推理模型（DeepSeek-R1、Qwen3 等）输出的思考过程的处理方式
+type ReasoningConfig struct {
+	Mode string `mapstructure:"mode"` // hide(丢弃，默认)/log(写入日志)/forward(以 reasoning 事件转发给客户端)
+}
+
+// MCPConfig 外部 MCP 工具服务配置
+type MCPConfig struct {
+	Servers []MCPServerConfig `mapstructure:"servers"`
+}
+
+// MCPServerConfig 单个 MCP 服务，stdio 服务由本进程启动，http 服务使用 Streamable HTTP 连接
+type MCPServerConfig struct {
+	Name       string            `mapstructure:"name"`       // 服务名称，用于日志与工具名前缀
+	Transport  string            `mapstructure:"transport"`  // 连接方式: stdio/http，默认配置了 command 时为 stdio，否则为 http
+	Command    string            `mapstructure:"command"`    // stdio: 启动命令
+	Args       []string          `mapstructure:"args"`       // stdio: 命令参数
+	Env        []string          `mapstructure:"env"`        // stdio: 追加的环境变量，格式 KEY=VALUE
+	Dir        string            `mapstructure:"dir"`        // stdio: 工作目录
+	URL        string            `mapstructure:"url"`        // http: MCP 端点地址
+	Headers    map[string]string `mapstructure:"headers"`    // http: 附加的请求头，如 Authorization
+	Timeout    time.Duration     `mapstructure:"timeout"`    // 单次请求（含工具调用）的超时，默认 30s
+	ToolPrefix string            `mapstructure:"toolPrefix"` // 注册工具时的名称前缀，默认为 "<name>_"
+	Tools      []string          `mapstructure:"tools"`      // 只暴露这些工具（使用服务端的原始名称），为空时暴露全部
+	Disabled   bool              `mapstructure:"disabled"`   // 暂时停用该服务
+}
+
+// DialogueConfig 一轮示例对话
+type DialogueConfig struct {
+	User      string `mapstructure:"user"`
+	Assistant string `mapstructure:"assistant"`
 }
 
 func Load() *Config {
@@ -61,7 +243,7 @@ func Load() *Config {
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err != nil {
-		logger.Error("Config file not found, using defaults: %v", err)
+		logger.Errorf("Config file not found, using defaults: %v", err)
 		fmt.Println("Config file not found")
 	}
 
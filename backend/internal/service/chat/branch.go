@@ -0,0 +1,161 @@
+package chat
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/chat_domain"
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/google/uuid"
+)
+
+// ErrInvalidBranch 只能为 assistant 消息重新生成回复、只能编辑 user 消息
+var ErrInvalidBranch = errors.New("message cannot be regenerated or edited this way")
+
+// Regenerate 为 assistant 消息对应的用户消息重新生成回复。新回复与原回复互为兄弟分支，并成为当前分支
+func (s *Service) Regenerate(c context.Context, conversationID, messageID string, req *chat_domain.RegenerateRequest) (*chat_domain.Response, error) {
+	chatReq := &chat_domain.Request{ConversationID: conversationID, Options: req.Options}
+	return s.respond(c, chatReq, func(ctx context.Context) (*exchange, error) {
+		return s.forkExchange(ctx, chatReq, messageID, true)
+	})
+}
+
+// EditMessage 以新的内容替换一条用户消息并生成回复。新消息与原消息互为兄弟分支，并成为当前分支
+func (s *Service) EditMessage(c context.Context, conversationID, messageID string, req *chat_domain.EditRequest) (*chat_domain.Response, error) {
+	chatReq := &chat_domain.Request{Message: req.Message, ConversationID: conversationID, Options: req.Options}
+	return s.respond(c, chatReq, func(ctx context.Context) (*exchange, error) {
+		return s.forkExchange(ctx, chatReq, messageID, false)
+	})
+}
+
+// Branch 返回会话当前分支上的全部消息
+func (s *Service) Branch(ctx context.Context, userID, conversationID string) ([]*conversation_domain.BranchMessage, error) {
+	conv, err := s.GetConversation(ctx, userID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.branch(ctx, conv.ID, conv.ActiveMessageID)
+}
+
+// SwitchBranch 切换到包含指定消息的分支，分支末尾沿最新的后续消息确定
+func (s *Service) SwitchBranch(ctx context.Context, conversationID string, req *conversation_domain.SwitchBranchRequest) ([]*conversation_domain.BranchMessage, error) {
+	conv, err := s.GetConversation(ctx, req.UserID, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := s.store.messages.Nodes(ctx, conv.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(nodes, func(n *conversation_domain.MessageNode) bool { return n.ID == req.MessageID }) {
+		return nil, conversation_domain.ErrNotFound
+	}
+	children := childrenOf(nodes)
+	leaf := req.MessageID
+	for kids := children[leaf]; len(kids) > 0; kids = children[leaf] {
+		leaf = kids[len(kids)-1]
+	}
+	if err := s.store.conversations.SetActive(ctx, conv.ID, leaf); err != nil {
+		return nil, err
+	}
+	messages, summary, err := s.loadPath(ctx, conv, leaf)
+	if err != nil {
+		return nil, err
+	}
+	s.history.Replace(conv.ID, messages, summary)
+	return s.branch(ctx, conv.ID, leaf)
+}
+
+// forkExchange 在 messageID 所在的位置产生新分支：regenerate 时 messageID 为 assistant 消息，
+// 复用其用户消息重新生成回复；否则 messageID 为被编辑的用户消息，新消息与其共用父消息
+func (s *Service) forkExchange(ctx context.Context, req *chat_domain.Request, messageID string, regenerate bool) (*exchange, error) {
+	if s.store == nil {
+		return nil, ErrStorageUnavailable
+	}
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	conv, err := s.ownedConversation(ctx, conversationKey(req), req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.conversationMessage(ctx, conv.ID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	ex := &exchange{
+		conversationID: conv.ID,
+		userMessageID:  uuid.NewString(),
+		replyID:        uuid.NewString(),
+		startedAt:      time.Now(),
+		untitled:       conv.Title == "",
+		regenerate:     regenerate,
+		forked:         true,
+	}
+	if regenerate {
+		if target.Role != string(llm.RoleAssistant) || target.ParentID == "" {
+			return nil, ErrInvalidBranch
+		}
+		user, err := s.conversationMessage(ctx, conv.ID, target.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		req.Message, ex.userMessageID, ex.parentID = user.Content, user.ID, user.ParentID
+	} else {
+		if target.Role != string(llm.RoleUser) {
+			return nil, ErrInvalidBranch
+		}
+		ex.parentID = target.ParentID
+	}
+	if ex.history, ex.summary, err = s.loadPath(ctx, conv, ex.parentID); err != nil {
+		return nil, err
+	}
+	return ex, nil
+}
+
+// conversationMessage 返回会话中的一条消息，属于其他会话时同样返回 ErrNotFound
+func (s *Service) conversationMessage(ctx context.Context, conversationID, id string) (*conversation_domain.Message, error) {
+	m, err := s.store.messages.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if m.ConversationID != conversationID {
+		return nil, conversation_domain.ErrNotFound
+	}
+	return m, nil
+}
+
+// branch 返回以 leafID 结尾的分支，并附上每条消息的兄弟消息
+func (s *Service) branch(ctx context.Context, conversationID, leafID string) ([]*conversation_domain.BranchMessage, error) {
+	res := []*conversation_domain.BranchMessage{}
+	if leafID == "" {
+		return res, nil
+	}
+	path, err := s.store.messages.Path(ctx, leafID, 0)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := s.store.messages.Nodes(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	children := childrenOf(nodes)
+	for _, m := range path {
+		res = append(res, &conversation_domain.BranchMessage{Message: m, SiblingIDs: children[m.ParentID]})
+	}
+	return res, nil
+}
+
+// childrenOf 按父消息分组，同一父消息下的消息按写入顺序排列
+func childrenOf(nodes []*conversation_domain.MessageNode) map[string][]string {
+	nodes = slices.Clone(nodes)
+	slices.SortStableFunc(nodes, func(a, b *conversation_domain.MessageNode) int { return cmp.Compare(a.Seq, b.Seq) })
+	children := make(map[string][]string)
+	for _, n := range nodes {
+		children[n.ParentID] = append(children[n.ParentID], n.ID)
+	}
+	return children
+}
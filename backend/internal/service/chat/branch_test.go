@@ -0,0 +1,155 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ai-companion/backend/internal/domain/chat_domain"
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/infrastructure/llm/tool"
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+func TestMain(m *testing.M) {
+	// 回显用户消息的模型，便于检查回复对应的是哪条用户消息
+	models = llm.NewRegistry("", &config.LLMConfig{
+		Provider: "mock_llm", Model: "mock-1", Mock: config.MockConfig{Mode: llm.MockModeEcho},
+	}, nil)
+	os.Exit(m.Run())
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	store := newConversationStore(&config.DatabaseConfig{
+		Driver:      database.DriverSQLite,
+		Path:        filepath.Join(t.TempDir(), "test.db"),
+		AutoMigrate: true,
+	})
+	if store == nil {
+		t.Fatal("open test database failed")
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return &Service{store: store, history: newHistoryStore(defaultMaxHistory), tools: tool.NewRegistry()}
+}
+
+func branchIDs(branch []*conversation_domain.BranchMessage) []string {
+	ids := make([]string, len(branch))
+	for i, m := range branch {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+func TestBranchOperations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(t)
+	options := chat_domain.Options{UserID: "u1"}
+	send := func(conversationID, message string) *chat_domain.Response {
+		t.Helper()
+		res, err := s.ProcessMessage(ctx, &chat_domain.Request{Message: message, ConversationID: conversationID, Options: options})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	current := func(conversationID string) []*conversation_domain.BranchMessage {
+		t.Helper()
+		branch, err := s.Branch(ctx, "u1", conversationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return branch
+	}
+
+	first := send("", "你好")
+	convID := first.ConversationID
+	second := send(convID, "讲个故事")
+	original := current(convID)
+	if len(original) != 4 || original[3].ID != second.MessageID {
+		t.Fatalf("branch = %v", branchIDs(original))
+	}
+	firstUserID, secondUserID := original[0].ID, original[2].ID
+
+	// 连续重新生成两次，新回复按生成顺序排在原回复之后
+	var replies []string
+	for range 2 {
+		res, err := s.Regenerate(ctx, convID, second.MessageID, &chat_domain.RegenerateRequest{Options: options})
+		if err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, res.MessageID)
+	}
+	branch := current(convID)
+	if got := branchIDs(branch); !slices.Equal(got[:3], branchIDs(original)[:3]) || got[3] != replies[1] {
+		t.Errorf("branch after regenerate = %v", got)
+	}
+	if want := append([]string{second.MessageID}, replies...); !slices.Equal(branch[3].SiblingIDs, want) {
+		t.Errorf("siblings = %v, want %v", branch[3].SiblingIDs, want)
+	}
+	if branch[3].Content != "讲个故事" {
+		t.Errorf("regenerated reply = %q", branch[3].Content)
+	}
+
+	// 编辑第一条用户消息产生新的分支
+	edited, err := s.EditMessage(ctx, convID, firstUserID, &chat_domain.EditRequest{Message: "早上好", Options: options})
+	if err != nil {
+		t.Fatal(err)
+	}
+	branch = current(convID)
+	if len(branch) != 2 || branch[0].Content != "早上好" || branch[1].ID != edited.MessageID || branch[1].Content != "早上好" {
+		t.Fatalf("branch after edit = %v", branchIDs(branch))
+	}
+	if want := []string{firstUserID, branch[0].ID}; !slices.Equal(branch[0].SiblingIDs, want) {
+		t.Errorf("edited siblings = %v, want %v", branch[0].SiblingIDs, want)
+	}
+
+	// 切换回原消息时沿最新的后续消息找到分支末尾
+	switched, err := s.SwitchBranch(ctx, convID, &conversation_domain.SwitchBranchRequest{UserID: "u1", MessageID: firstUserID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := branchIDs(switched); len(got) != 4 || got[2] != secondUserID || got[3] != replies[1] {
+		t.Errorf("branch after switch = %v", got)
+	}
+	// 后续消息接在切换后的分支上
+	send(convID, "继续")
+	if got := branchIDs(current(convID)); len(got) != 6 || got[3] != replies[1] {
+		t.Errorf("branch after switch and send = %v", got)
+	}
+
+	if _, err := s.Regenerate(ctx, convID, firstUserID, &chat_domain.RegenerateRequest{Options: options}); !errors.Is(err, ErrInvalidBranch) {
+		t.Errorf("regenerate a user message: err = %v", err)
+	}
+	if _, err := s.EditMessage(ctx, convID, second.MessageID, &chat_domain.EditRequest{Message: "x", Options: options}); !errors.Is(err, ErrInvalidBranch) {
+		t.Errorf("edit an assistant message: err = %v", err)
+	}
+	_, err = s.SwitchBranch(ctx, convID, &conversation_domain.SwitchBranchRequest{UserID: "u1", MessageID: "missing"})
+	if !errors.Is(err, conversation_domain.ErrNotFound) {
+		t.Errorf("switch to a missing message: err = %v", err)
+	}
+}
+
+func TestChildrenOf(t *testing.T) {
+	// 节点不按写入顺序给出时也按 Seq 排列兄弟消息
+	nodes := []*conversation_domain.MessageNode{
+		{ID: "a", Seq: 1},
+		{ID: "z", ParentID: "a", Seq: 4},
+		{ID: "b", ParentID: "a", Seq: 2},
+		{ID: "m", ParentID: "a", Seq: 3},
+		{ID: "c", ParentID: "b", Seq: 5},
+	}
+	children := childrenOf(nodes)
+	if !slices.Equal(children[""], []string{"a"}) || !slices.Equal(children["a"], []string{"b", "m", "z"}) ||
+		!slices.Equal(children["b"], []string{"c"}) || len(children["c"]) != 0 {
+		t.Errorf("children = %v", children)
+	}
+	if nodes[1].ID != "z" {
+		t.Error("childrenOf reordered its input")
+	}
+}
@@ -2,48 +2,368 @@ package chat
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"github.com/ai-companion/backend/global"
 	"github.com/ai-companion/backend/internal/domain/chat_domain"
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
 	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/infrastructure/llm/mcp"
+	"github.com/ai-companion/backend/internal/infrastructure/llm/tool"
+	"github.com/ai-companion/backend/internal/pkg/config"
 	"github.com/ai-companion/backend/internal/pkg/logger"
+	"github.com/ai-companion/backend/internal/service/memory"
+	"github.com/ai-companion/backend/internal/service/recall"
+	"github.com/ai-companion/backend/internal/service/search"
 )
 
+// defaultSystemPrompt 默认的系统提示词
+const defaultSystemPrompt = "你是一个非常有用的助理"
+
+// anonymousConversation 未携带用户ID时使用的会话标识，仅用于不经过 HTTP 接口的内部调用；
+// HTTP 接口总会带上用户ID或浏览器会话标识
+const anonymousConversation = "anonymous"
+
 type Service struct {
+	store      *conversationStore
+	history    *historyStore
+	summarizer *summarizer
+	memory     *memory.Service
+	recall     *recall.Service
+	search     *search.Service
+	tools      *tool.Registry
+	mcp        *mcp.Manager
 }
 
-var llmHandle = llm.CreateLLM(&global.Cfg.LLM)
+// models 由 llm 配置与 models 中的具名配置组成的模型表
+var models = llm.NewRegistry(global.Cfg.DefaultModel, &global.Cfg.LLM, global.Cfg.Models)
 
 // NewService 创建新的聊天服务实例
 func NewService() *Service {
-	return &Service{}
+	tools := tool.NewRegistry()
+	if err := tool.RegisterBuiltins(tools, &global.Cfg.Tools); err != nil {
+		logger.Errorf("register builtin tools error: %s", err.Error())
+	}
+	embedder := llm.CreateEmbedder(&global.Cfg.Embedding)
+	s := &Service{
+		store:   newConversationStore(&global.Cfg.Database),
+		history: newHistoryStore(defaultMaxHistory),
+		memory:  memory.NewService(&global.Cfg.Memory, models, embedder),
+		recall:  recall.NewService(&global.Cfg.Recall, embedder),
+		tools:   tools,
+		// MCP 服务在后台连接，工具发现完成后才会出现在工具表中
+		mcp: mcp.Start(&global.Cfg.MCP, tools),
+	}
+	if s.store != nil {
+		s.search = search.NewService(&global.Cfg.Search, s.store.db, s.store.conversations, s.store.messages)
+	}
+	s.summarizer = newSummarizer(&global.Cfg.Summary, s.history, s.saveSummary)
+	s.history.maxMessages = s.summarizer.maxHistory()
+	return s
+}
+
+// StreamReply 流式回复：回复分块，以及本轮回复所在的会话与消息ID
+type StreamReply struct {
+	ConversationID string
+	MessageID      string
+	Chunks         <-chan *llm.StreamChunk
+}
+
+// Close 释放服务持有的外部资源，如启动的 MCP 服务进程、进行中的摘要与记忆提取任务、回忆索引和数据库连接
+func (s *Service) Close() error {
+	s.summarizer.Close()
+	s.memory.Close()
+	if err := s.recall.Close(); err != nil {
+		logger.Errorf("close recall index error: %s", err.Error())
+	}
+	if err := s.store.Close(); err != nil {
+		logger.Errorf("close database error: %s", err.Error())
+	}
+	return s.mcp.Close()
+}
+
+// Memory 返回长期记忆服务
+func (s *Service) Memory() *memory.Service {
+	return s.memory
+}
+
+// Tools 返回可供模型调用的工具表，注册到其中的工具会随每次请求提供给支持工具调用的模型
+func (s *Service) Tools() *tool.Registry {
+	return s.tools
 }
 
 // ProcessMessage 处理用户消息并生成AI回复
 func (s *Service) ProcessMessage(c context.Context, req *chat_domain.Request) (*chat_domain.Response, error) {
-	ctx, cancel := context.WithTimeout(c, 15*time.Second)
-	defer cancel()
-
-	result, err := llmHandle.GenerateChat(ctx, &llm.ChatRequest{
-		Message: req.Message,
+	return s.respond(c, req, func(ctx context.Context) (*exchange, error) {
+		return s.newExchange(ctx, conversationKey(req), req.ConversationID, req.Message)
 	})
+}
+
+// respond 在 open 确定的会话位置上生成一条回复
+func (s *Service) respond(c context.Context, req *chat_domain.Request, open func(context.Context) (*exchange, error)) (*chat_domain.Response, error) {
+	llmHandle, err := models.Get(req.Model)
 	if err != nil {
-		logger.Error("AI GenerateChat error: %s", err.Error())
 		return nil, err
 	}
+	if c, err = withTimezone(c, req); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(c, requestTimeout)
+	defer cancel()
 
+	ex, err := open(ctx)
+	if err != nil {
+		logger.Errorf("resolve conversation error: %s", err.Error())
+		return nil, err
+	}
+	persona := resolvePersona(global.Cfg.Persona, req.Persona)
+	chatReq := &llm.ChatRequest{
+		Messages: s.buildMessages(ctx, ex, req, persona),
+		Options:  generateOptions(req),
+		Tools:    s.toolDefinitions(llmHandle, persona),
+	}
+	if err := fitContext(models.Config(req.Model), chatReq, systemPrompt(req, persona)); err != nil {
+		return nil, err
+	}
+	result, err := s.generateWithTools(ctx, llmHandle, chatReq)
+	if err != nil {
+		logger.Errorf("AI GenerateChat error: %s", err.Error())
+		return nil, err
+	}
+	content := result.Content()
+	// 思考过程不写入历史，避免占用后续轮次的上下文
+	var reasoning string
+	if len(result.Choices) > 0 {
+		reasoning = result.Choices[0].Reasoning
+	}
+	logReasoning(ex.conversationID, result.Model, reasoning)
 	reply := &chat_domain.Response{
-		Reply:     result.Object,
-		MessageID: global.UUID.String(),
-		Timestamp: time.Now().Unix(),
+		Reply:          content,
+		MessageID:      ex.replyID,
+		ConversationID: ex.conversationID,
+		Timestamp:      time.Now().Unix(),
+		Model:          result.Model,
+		Usage:          ToDomainUsage(&result.Usage),
+	}
+	if len(result.Choices) > 0 {
+		reply.FinishReason = result.Choices[0].FinishReason
+	}
+	s.complete(ex, req, &conversation_domain.Message{
+		Content:          content,
+		Model:            result.Model,
+		FinishReason:     reply.FinishReason,
+		PromptTokens:     result.Usage.PromptTokens,
+		CompletionTokens: result.Usage.CompletionTokens,
+		TotalTokens:      result.Usage.TotalTokens,
+	})
+	if reasoningMode() == reasoningForward {
+		reply.Reasoning = reasoning
 	}
 	return reply, nil
 }
 
 // ProcessStreamMessage 流式处理用户消息并生成AI回复
-func (s *Service) ProcessStreamMessage(c context.Context, req *chat_domain.Request) (<-chan *llm.StreamChunk, error) {
-	return llmHandle.GenerateStream(c, &llm.ChatRequest{
-		Message: req.Message,
-	})
+func (s *Service) ProcessStreamMessage(c context.Context, req *chat_domain.Request) (*StreamReply, error) {
+	llmHandle, err := models.Get(req.Model)
+	if err != nil {
+		return nil, err
+	}
+	if c, err = withTimezone(c, req); err != nil {
+		return nil, err
+	}
+	ex, err := s.newExchange(c, conversationKey(req), req.ConversationID, req.Message)
+	if err != nil {
+		logger.Errorf("resolve conversation error: %s", err.Error())
+		return nil, err
+	}
+	persona := resolvePersona(global.Cfg.Persona, req.Persona)
+	chatReq := &llm.ChatRequest{
+		Messages: s.buildMessages(c, ex, req, persona),
+		Options:  generateOptions(req),
+		Tools:    s.toolDefinitions(llmHandle, persona),
+	}
+	if err := fitContext(models.Config(req.Model), chatReq, systemPrompt(req, persona)); err != nil {
+		return nil, err
+	}
+	stream, err := llmHandle.GenerateStream(c, chatReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resChan := make(chan *llm.StreamChunk, 10)
+	go func() {
+		defer close(resChan)
+		var reply, reasoning strings.Builder
+		var usage llm.Usage
+		var finishReason string
+		failed, forward := false, true
+		mode := reasoningMode()
+		for round := 0; ; round++ {
+			var toolCalls *llm.StreamChunk
+			var content strings.Builder
+			// 始终读完上游，保证模型侧的 goroutine 能正常退出
+			for chunk := range stream {
+				if chunk.Error != nil {
+					failed = true
+				} else {
+					content.WriteString(chunk.Message)
+				}
+				addUsage(&usage, chunk.Usage)
+				if chunk.Done {
+					finishReason = chunk.FinishReason
+				}
+				// 推理分块只携带思考过程，仅 forward 模式转发给客户端
+				if chunk.Reasoning != "" {
+					reasoning.WriteString(chunk.Reasoning)
+					if mode != reasoningForward {
+						continue
+					}
+				}
+				// 要求执行工具的结束块不转发，执行完工具后继续下一轮
+				if needsTools(chatReq, chunk) {
+					toolCalls = chunk
+					continue
+				}
+				if chunk.Done && chunk.Usage != nil {
+					final, total := *chunk, usage
+					final.Usage = &total
+					chunk = &final
+				}
+				if !forward {
+					continue
+				}
+				select {
+				case resChan <- chunk:
+				case <-c.Done():
+					forward = false
+				}
+			}
+			reply.WriteString(content.String())
+			if failed || !forward || toolCalls == nil {
+				break
+			}
+			s.runTools(c, chatReq, content.String(), toolCalls.ToolCalls, round)
+			if stream, err = llmHandle.GenerateStream(c, chatReq); err != nil {
+				failed = true
+				select {
+				case resChan <- &llm.StreamChunk{Error: err, Done: true}:
+				case <-c.Done():
+				}
+				break
+			}
+		}
+		logReasoning(ex.conversationID, req.Model, reasoning.String())
+		if !failed && forward && reply.Len() > 0 {
+			s.complete(ex, req, &conversation_domain.Message{
+				Content:          reply.String(),
+				Model:            modelName(req.Model),
+				FinishReason:     finishReason,
+				PromptTokens:     usage.PromptTokens,
+				CompletionTokens: usage.CompletionTokens,
+				TotalTokens:      usage.TotalTokens,
+			})
+		}
+	}()
+	return &StreamReply{ConversationID: ex.conversationID, MessageID: ex.replyID, Chunks: resChan}, nil
+}
+
+// complete 一轮对话成功后写入历史与数据库，并触发摘要、记忆提取、回忆索引与搜索索引。
+// 思考过程不写入历史，避免占用后续轮次的上下文
+func (s *Service) complete(ex *exchange, req *chat_domain.Request, reply *conversation_domain.Message) {
+	turn := []llm.Message{
+		{Role: llm.RoleUser, Content: req.Message},
+		{Role: llm.RoleAssistant, Content: reply.Content},
+	}
+	if ex.forked {
+		// 新分支成为当前分支
+		s.history.Replace(ex.conversationID, append(ex.history, turn...), ex.summary)
+	} else {
+		s.history.Append(ex.conversationID, turn...)
+	}
+	saved := s.save(ex, req.Message, reply)
+	s.summarizer.Trigger(ex.conversationID)
+	s.memory.Observe(req.UserID, req.Message, reply.Content)
+	s.recall.Index(conversationKey(req), ex.conversationID, req.Message, reply.Content)
+	s.search.Index(conversationKey(req), saved...)
+}
+
+// ListModels 返回可选的模型配置
+func (s *Service) ListModels() []chat_domain.ModelInfo {
+	infos := models.List()
+	res := make([]chat_domain.ModelInfo, 0, len(infos))
+	for _, info := range infos {
+		res = append(res, chat_domain.ModelInfo{
+			Name:        info.Name,
+			Description: info.Description,
+			Provider:    info.Provider,
+			Model:       info.Model,
+			Default:     info.Default,
+			Available:   info.Available,
+		})
+	}
+	return res
+}
+
+// buildMessages 组装发送给模型的完整消息列表：系统提示词（人设、长期记忆、相关的过往对话与会话摘要）+ 历史 + 本轮用户消息，
+// 新会话会以人设的开场白作为第一条 assistant 消息
+func (s *Service) buildMessages(ctx context.Context, ex *exchange, req *chat_domain.Request, persona config.PersonaConfig) []llm.Message {
+	history, summary := ex.history, ex.summary
+	if !ex.forked {
+		history, summary = s.history.Get(ex.conversationID), s.history.Summary(ex.conversationID)
+	}
+	prompt := withMemories(systemPrompt(req, persona), s.memory.Relevant(ctx, req.UserID, req.Message))
+	prompt = withRecall(prompt, s.recall.Retrieve(ctx, conversationKey(req), ex.conversationID, req.Message, history, tokenCounter(req.Model)))
+	messages := make([]llm.Message, 0, len(history)+3)
+	messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: withSummary(prompt, summary)})
+	if len(history) == 0 && summary == "" && persona.Greeting != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: persona.Greeting})
+	}
+	messages = append(messages, history...)
+	return append(messages, llm.Message{Role: llm.RoleUser, Content: req.Message})
+}
+
+// ToDomainUsage 将模型层的用量转换为接口返回结构
+func ToDomainUsage(usage *llm.Usage) *chat_domain.Usage {
+	if usage == nil {
+		return nil
+	}
+	return &chat_domain.Usage{
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+}
+
+// generateOptions 提取请求中的生成参数
+func generateOptions(req *chat_domain.Request) llm.GenerateOptions {
+	return llm.GenerateOptions{
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		MaxTokens:        req.MaxTokens,
+		Stop:             req.Stop,
+		Seed:             req.Seed,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+}
+
+// modelName 返回具名配置实际使用的模型
+func modelName(name string) string {
+	if cfg := models.Config(name); cfg != nil {
+		return cfg.Model
+	}
+	return name
+}
+
+// conversationKey 以用户ID区分会话的归属，未携带用户ID的请求共用匿名用户
+func conversationKey(req *chat_domain.Request) string {
+	return userKey(req.UserID)
+}
+
+func userKey(userID string) string {
+	if userID == "" {
+		return anonymousConversation
+	}
+	return userID
 }
@@ -0,0 +1,144 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// defaultReplyReserve 未设置 maxTokens 时为回复预留的 token 数，不超过上下文长度的四分之一
+const defaultReplyReserve = 1024
+
+// truncationMark 截断处的标记
+const truncationMark = "…"
+
+// fitContext 把 req.Messages 裁剪到模型的上下文长度以内。首条系统提示词与最后一条本轮用户消息始终保留，
+// 中间的历史从最近的轮次开始保留，放不下的较早轮次整轮丢弃；工具定义与回复预留的 token 一并计入。
+// 丢弃全部历史后仍然超出时，先截断本轮用户消息，再截断系统提示词中 persona 之后的长期记忆、相关对话与摘要，
+// persona 本身从不截断。maxTokens 不小于上下文长度或 persona 放不下时返回 ValidationError
+func fitContext(cfg *config.LLMConfig, req *llm.ChatRequest, persona string) error {
+	if cfg == nil || len(req.Messages) < 2 {
+		return nil
+	}
+	counter := llm.NewTokenCounter(cfg.Model)
+	window := llm.ContextWindow(cfg)
+	reserve := replyReserve(cfg, req.Options, window)
+	if reserve >= window {
+		return &llm.ValidationError{Param: llm.ParamMaxTokens, Reason: fmt.Sprintf("must be less than the context window (%d)", window)}
+	}
+	budget := window - reserve - counter.CountTools(req.Tools)
+
+	messages := req.Messages
+	head, current := 0, messages[len(messages)-1]
+	if messages[0].Role == llm.RoleSystem {
+		head = 1
+	}
+	history := messages[head : len(messages)-1]
+	used := counter.CountMessages(messages[:head]) + counter.CountMessage(current)
+
+	// 从最近的轮次往前累加，一轮从用户消息开始；keep 为保留的第一条历史消息
+	keep := len(history)
+	for keep > 0 {
+		start := keep - 1
+		for start > 0 && history[start].Role != llm.RoleUser {
+			start--
+		}
+		var tokens int
+		for _, m := range history[start:keep] {
+			tokens += counter.CountMessage(m)
+		}
+		if used+tokens > budget {
+			break
+		}
+		used += tokens
+		keep = start
+	}
+	if keep > 0 {
+		res := make([]llm.Message, 0, len(messages)-keep)
+		res = append(res, messages[:head]...)
+		res = append(res, history[keep:]...)
+		req.Messages = append(res, current)
+	}
+
+	// shrink 截断 m 中 prefix 之后的部分，直到总量回到预算以内或该部分为空
+	truncated := 0
+	shrink := func(m *llm.Message, prefix string) {
+		rest := m.Content[len(prefix):]
+		if used <= budget || rest == "" {
+			return
+		}
+		for used > budget && rest != "" {
+			before := counter.CountMessage(*m)
+			rest = truncateTokens(counter, rest, counter.Count(rest)-(used-budget))
+			m.Content = prefix + rest
+			used += counter.CountMessage(*m) - before
+		}
+		truncated++
+	}
+	// 历史已全部丢弃：先截断本轮用户消息，再截断系统提示词中 persona 之后的长期记忆、相关对话与摘要
+	shrink(&req.Messages[len(req.Messages)-1], "")
+	if head == 1 {
+		system := &req.Messages[0]
+		if strings.HasPrefix(system.Content, persona) {
+			shrink(system, persona)
+		}
+	}
+
+	logger.WithFields(map[string]interface{}{
+		"model":             cfg.Model,
+		"promptTokens":      used,
+		"budget":            budget,
+		"contextWindow":     window,
+		"messages":          len(req.Messages),
+		"droppedMessages":   keep,
+		"truncatedMessages": truncated,
+	}).Debug("assembled prompt")
+	if used > budget {
+		return &llm.ValidationError{Param: "systemPrompt", Reason: fmt.Sprintf("persona does not fit in the context window (%d) of %s", window, cfg.Model)}
+	}
+	return nil
+}
+
+// truncateTokens 保留 text 开头不超过 limit 个 token 的部分，截断处加上标记
+func truncateTokens(counter *llm.TokenCounter, text string, limit int) string {
+	if counter.Count(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	// 二分查找保留的最大字符数
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if counter.Count(string(runes[:mid])+truncationMark) <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if lo == 0 {
+		return ""
+	}
+	return string(runes[:lo]) + truncationMark
+}
+
+// replyReserve 为回复预留的 token 数：优先取请求或配置中的 maxTokens
+func replyReserve(cfg *config.LLMConfig, opts llm.GenerateOptions, window int) int {
+	if opts.MaxTokens != nil && *opts.MaxTokens > 0 {
+		return *opts.MaxTokens
+	}
+	if cfg.MaxTokens != nil && *cfg.MaxTokens > 0 {
+		return *cfg.MaxTokens
+	}
+	return min(defaultReplyReserve, window/4)
+}
+
+// tokenCounter 返回所选模型配置的 token 估算器
+func tokenCounter(name string) *llm.TokenCounter {
+	if cfg := models.Config(name); cfg != nil {
+		return llm.NewTokenCounter(cfg.Model)
+	}
+	return llm.NewTokenCounter("")
+}
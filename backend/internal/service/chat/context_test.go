@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+func TestFitContext(t *testing.T) {
+	// llama 系列每个汉字计 1 个 token，每条消息另加 4 个
+	persona := strings.Repeat("人", 20)
+	extras := strings.Repeat("记", 30)
+	text := func(r string) string { return strings.Repeat(r, 10) }
+	conversation := func() []llm.Message {
+		return []llm.Message{
+			{Role: llm.RoleSystem, Content: persona + extras},
+			{Role: llm.RoleUser, Content: text("一")},
+			{Role: llm.RoleAssistant, Content: text("二")},
+			{Role: llm.RoleUser, Content: text("三")},
+			{Role: llm.RoleAssistant, Content: text("四")},
+			{Role: llm.RoleUser, Content: text("你")},
+		}
+	}
+	tests := []struct {
+		name      string
+		window    int
+		maxTokens int
+		// want 裁剪后各条消息的内容
+		want      []string
+		wantParam string
+	}{
+		{name: "fits", window: 138, maxTokens: 10,
+			want: []string{persona + extras, text("一"), text("二"), text("三"), text("四"), text("你")}},
+		{name: "drops oldest turn", window: 110, maxTokens: 10,
+			want: []string{persona + extras, text("三"), text("四"), text("你")}},
+		{name: "truncates current message before system prompt", window: 77, maxTokens: 10,
+			want: []string{persona + extras, "你你你" + truncationMark}},
+		{name: "trims memory but keeps persona", window: 57, maxTokens: 10,
+			want: []string{persona + strings.Repeat("记", 13) + truncationMark, ""}},
+		{name: "persona does not fit", window: 30, maxTokens: 10, wantParam: "systemPrompt"},
+		{name: "maxTokens equals window", window: 96, maxTokens: 96, wantParam: llm.ParamMaxTokens},
+		{name: "maxTokens exceeds window", window: 96, maxTokens: 200, wantParam: llm.ParamMaxTokens},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.LLMConfig{Model: "llama-test", ContextWindow: tt.window, MaxTokens: &tt.maxTokens}
+			req := &llm.ChatRequest{Messages: conversation()}
+			err := fitContext(cfg, req, persona)
+			if tt.wantParam != "" {
+				var validationErr *llm.ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Param != tt.wantParam {
+					t.Fatalf("err = %v, want ValidationError on %s", err, tt.wantParam)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range req.Messages {
+				got = append(got, m.Content)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+			counter := llm.NewTokenCounter(cfg.Model)
+			if used := counter.CountMessages(req.Messages); used > tt.window-tt.maxTokens {
+				t.Errorf("prompt tokens = %d, budget %d", used, tt.window-tt.maxTokens)
+			}
+		})
+	}
+}
+
+func TestFitContextCustomSystemPrompt(t *testing.T) {
+	// 系统提示词不以 persona 开头时整体保留
+	maxTokens := 10
+	cfg := &config.LLMConfig{Model: "llama-test", ContextWindow: 40, MaxTokens: &maxTokens}
+	req := &llm.ChatRequest{Messages: []llm.Message{
+		{Role: llm.RoleSystem, Content: strings.Repeat("规", 20)},
+		{Role: llm.RoleUser, Content: strings.Repeat("你", 10)},
+	}}
+	err := fitContext(cfg, req, "人设")
+	var validationErr *llm.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("err = %v, want ValidationError", err)
+	}
+	if req.Messages[0].Content != strings.Repeat("规", 20) {
+		t.Errorf("system prompt = %q", req.Messages[0].Content)
+	}
+}
+
+func TestTruncateTokens(t *testing.T) {
+	counter := llm.NewTokenCounter("llama-test")
+	tests := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"你好", 2, "你好"},
+		{"你好", 5, "你好"},
+		{strings.Repeat("你", 10), 5, "你你你" + truncationMark},
+		{strings.Repeat("你", 10), 1, ""},
+		{strings.Repeat("你", 10), 0, ""},
+		{strings.Repeat("你", 10), -3, ""},
+		{"abcdefghij", 2, "ab" + truncationMark},
+		{"", 0, ""},
+	}
+	for _, tt := range tests {
+		got := truncateTokens(counter, tt.text, tt.limit)
+		if got != tt.want {
+			t.Errorf("truncateTokens(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+		if got != "" && counter.Count(got) > tt.limit {
+			t.Errorf("truncateTokens(%q, %d) = %q exceeds the limit", tt.text, tt.limit, got)
+		}
+	}
+}
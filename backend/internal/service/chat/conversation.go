@@ -0,0 +1,322 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/domain/search_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/infrastructure/repository"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// 持久化相关的参数
+const (
+	// storeTimeout 单次读写数据库的超时
+	storeTimeout = 5 * time.Second
+	// maxTitleLength 以首条消息作为会话标题时保留的字数
+	maxTitleLength = 20
+)
+
+// ErrStorageUnavailable 数据库不可用，无法按会话ID管理会话
+var ErrStorageUnavailable = errors.New("conversation storage is not available")
+
+// conversationStore 会话与消息的持久化，数据库不可用时为 nil，方法均为空操作
+type conversationStore struct {
+	db            *database.DB
+	conversations conversation_domain.ConversationRepository
+	messages      conversation_domain.MessageRepository
+}
+
+func newConversationStore(cfg *config.DatabaseConfig) *conversationStore {
+	db, err := database.Open(cfg)
+	if err != nil {
+		logger.Errorf("open database error: %s, conversations will not be persisted", err.Error())
+		return nil
+	}
+	return &conversationStore{
+		db:            db,
+		conversations: repository.NewConversationRepository(db),
+		messages:      repository.NewMessageRepository(db),
+	}
+}
+
+func (st *conversationStore) Close() error {
+	if st == nil {
+		return nil
+	}
+	return st.db.Close()
+}
+
+// exchange 一轮对话中需要保存的信息
+type exchange struct {
+	conversationID string
+	// parentID 本轮用户消息在消息树中的父消息
+	parentID      string
+	userMessageID string
+	replyID       string
+	startedAt     time.Time
+	// untitled 会话还没有标题，保存时以本轮的用户消息作为标题
+	untitled bool
+	// regenerate 为已有的用户消息重新生成回复，不再保存用户消息
+	regenerate bool
+	// forked 本轮在当前分支以外的位置产生新分支，history 与 summary 为该位置之前的历史
+	forked  bool
+	history []llm.Message
+	summary string
+}
+
+// newExchange 确定本轮对话所在的会话：指定了会话ID时使用该会话，否则使用用户最近的未归档会话，没有时新建；
+// 未配置数据库时以用户区分会话
+func (s *Service) newExchange(ctx context.Context, userKey, conversationID, message string) (*exchange, error) {
+	ex := &exchange{
+		conversationID: userKey,
+		userMessageID:  uuid.NewString(),
+		replyID:        uuid.NewString(),
+		startedAt:      time.Now(),
+	}
+	if s.store == nil {
+		if conversationID != "" {
+			return nil, ErrStorageUnavailable
+		}
+		return ex, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+	defer cancel()
+	var conv *conversation_domain.Conversation
+	var err error
+	if conversationID != "" {
+		conv, err = s.ownedConversation(ctx, userKey, conversationID)
+	} else if conv, err = s.store.conversations.Latest(ctx, userKey); errors.Is(err, conversation_domain.ErrNotFound) {
+		conv = &conversation_domain.Conversation{
+			ID:        uuid.NewString(),
+			UserID:    userKey,
+			Title:     conversationTitle(message),
+			CreatedAt: ex.startedAt,
+			UpdatedAt: ex.startedAt,
+		}
+		err = s.store.conversations.Create(ctx, conv)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ex.conversationID, ex.untitled, ex.parentID = conv.ID, conv.Title == "", conv.ActiveMessageID
+	s.loadHistory(ctx, conv)
+	return ex, nil
+}
+
+// loadHistory 会话不在内存中时（如服务重启后）从数据库加载当前分支
+func (s *Service) loadHistory(ctx context.Context, conv *conversation_domain.Conversation) {
+	if s.history.Has(conv.ID) {
+		return
+	}
+	messages, summary, err := s.loadPath(ctx, conv, conv.ActiveMessageID)
+	if err != nil {
+		logger.Errorf("load history of conversation %s error: %s", conv.ID, err.Error())
+		return
+	}
+	s.history.Load(conv.ID, messages, summary)
+}
+
+// loadPath 返回以 leafID 结尾的分支上最近的消息。分支超出历史上限时附带会话的滚动摘要，
+// 否则整条分支都在历史中，不再需要摘要（摘要也可能来自其他分支）
+func (s *Service) loadPath(ctx context.Context, conv *conversation_domain.Conversation, leafID string) ([]llm.Message, string, error) {
+	if leafID == "" {
+		return nil, "", nil
+	}
+	stored, err := s.store.messages.Path(ctx, leafID, s.history.maxMessages+1)
+	if err != nil {
+		return nil, "", err
+	}
+	var summary string
+	if len(stored) > s.history.maxMessages {
+		stored, summary = stored[1:], conv.Summary
+	}
+	messages := make([]llm.Message, 0, len(stored))
+	for _, m := range stored {
+		messages = append(messages, llm.Message{Role: llm.Role(m.Role), Content: m.Content})
+	}
+	return messages, summary, nil
+}
+
+// save 保存一轮对话的用户消息与回复并返回保存的消息，失败只记录日志，不影响已经生成的回复
+func (s *Service) save(ex *exchange, message string, reply *conversation_domain.Message) []*conversation_domain.Message {
+	if s.store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	// 同一毫秒内完成的回复也要排在用户消息之后
+	replyAt := time.Now()
+	if earliest := ex.startedAt.Add(time.Millisecond); replyAt.Before(earliest) {
+		replyAt = earliest
+	}
+	reply.ID, reply.ConversationID, reply.ParentID, reply.Role = ex.replyID, ex.conversationID, ex.userMessageID, string(llm.RoleAssistant)
+	reply.CreatedAt, reply.UpdatedAt = replyAt, replyAt
+	messages := []*conversation_domain.Message{reply}
+	if !ex.regenerate {
+		messages = []*conversation_domain.Message{{
+			ID:             ex.userMessageID,
+			ConversationID: ex.conversationID,
+			ParentID:       ex.parentID,
+			Role:           string(llm.RoleUser),
+			Content:        message,
+			CreatedAt:      ex.startedAt,
+			UpdatedAt:      ex.startedAt,
+		}, reply}
+	}
+	if err := s.store.messages.Append(ctx, messages...); err != nil {
+		logger.Errorf("save messages of conversation %s error: %s", ex.conversationID, err.Error())
+		return nil
+	}
+	if ex.untitled {
+		if err := s.store.conversations.Rename(ctx, ex.conversationID, conversationTitle(message)); err != nil {
+			logger.Errorf("set title of conversation %s error: %s", ex.conversationID, err.Error())
+		}
+	}
+	return messages
+}
+
+// saveSummary 随会话保存滚动摘要
+func (s *Service) saveSummary(conversationID, summary string) {
+	if s.store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	err := s.store.conversations.UpdateSummary(ctx, conversationID, summary)
+	// 摘要期间会话可能已被删除
+	if err != nil && !errors.Is(err, conversation_domain.ErrNotFound) {
+		logger.Errorf("save summary of conversation %s error: %s", conversationID, err.Error())
+	}
+}
+
+// conversationTitle 以首条消息的开头作为会话标题
+func conversationTitle(message string) string {
+	runes := []rune(message)
+	if len(runes) > maxTitleLength {
+		return string(runes[:maxTitleLength]) + "…"
+	}
+	return message
+}
+
+// CreateConversation 新建会话
+func (s *Service) CreateConversation(ctx context.Context, req *conversation_domain.CreateRequest) (*conversation_domain.Conversation, error) {
+	if s.store == nil {
+		return nil, ErrStorageUnavailable
+	}
+	now := time.Now()
+	conv := &conversation_domain.Conversation{
+		ID:        uuid.NewString(),
+		UserID:    userKey(req.UserID),
+		Title:     req.Title,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.store.conversations.Create(ctx, conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// ListConversations 按更新时间从新到旧列出用户的一页会话，返回会话与总数
+func (s *Service) ListConversations(ctx context.Context, req *conversation_domain.ListRequest) ([]*conversation_domain.Conversation, int64, error) {
+	if s.store == nil {
+		return nil, 0, ErrStorageUnavailable
+	}
+	req.Normalize()
+	convs, total, err := s.store.conversations.List(ctx, conversation_domain.ListQuery{
+		UserID:   userKey(req.UserID),
+		Archived: req.Archived,
+		Offset:   req.Offset(),
+		Limit:    req.Size,
+	})
+	if convs == nil {
+		convs = []*conversation_domain.Conversation{}
+	}
+	return convs, total, err
+}
+
+// GetConversation 返回用户的一个会话
+func (s *Service) GetConversation(ctx context.Context, userID, id string) (*conversation_domain.Conversation, error) {
+	if s.store == nil {
+		return nil, ErrStorageUnavailable
+	}
+	return s.ownedConversation(ctx, userKey(userID), id)
+}
+
+// UpdateConversation 重命名或归档会话
+func (s *Service) UpdateConversation(ctx context.Context, id string, req *conversation_domain.UpdateRequest) (*conversation_domain.Conversation, error) {
+	conv, err := s.GetConversation(ctx, req.UserID, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Title != nil {
+		if err := s.store.conversations.Rename(ctx, id, *req.Title); err != nil {
+			return nil, err
+		}
+		conv.Title = *req.Title
+	}
+	if req.Archived != nil && *req.Archived != (conv.ArchivedAt != nil) {
+		var archivedAt *time.Time
+		if *req.Archived {
+			now := time.Now()
+			archivedAt = &now
+		}
+		if err := s.store.conversations.SetArchived(ctx, id, archivedAt); err != nil {
+			return nil, err
+		}
+		conv.ArchivedAt = archivedAt
+	}
+	return conv, nil
+}
+
+// DeleteConversation 删除会话及其消息，并从内存历史、回忆索引与搜索索引中移除
+func (s *Service) DeleteConversation(ctx context.Context, userID, id string) error {
+	conv, err := s.GetConversation(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.store.conversations.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.history.Delete(id)
+	s.recall.Forget(conv.UserID, id)
+	s.search.Forget(id)
+	return nil
+}
+
+// SearchMessages 在用户的全部会话中全文搜索消息，返回一页结果与命中总数
+func (s *Service) SearchMessages(ctx context.Context, req *search_domain.Request) ([]*search_domain.Result, int64, error) {
+	return s.search.Search(ctx, userKey(req.UserID), req)
+}
+
+// ListMessages 按时间顺序列出会话中的一页消息，返回消息与总数
+func (s *Service) ListMessages(ctx context.Context, id string, req *conversation_domain.ListMessagesRequest) ([]*conversation_domain.Message, int64, error) {
+	if _, err := s.GetConversation(ctx, req.UserID, id); err != nil {
+		return nil, 0, err
+	}
+	req.Normalize()
+	messages, total, err := s.store.messages.List(ctx, id, req.Offset(), req.Size)
+	if messages == nil {
+		messages = []*conversation_domain.Message{}
+	}
+	return messages, total, err
+}
+
+// ownedConversation 返回属于该用户的会话，不属于该用户时同样返回 ErrNotFound
+func (s *Service) ownedConversation(ctx context.Context, userKey, id string) (*conversation_domain.Conversation, error) {
+	conv, err := s.store.conversations.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if conv.UserID != userKey {
+		return nil, conversation_domain.ErrNotFound
+	}
+	return conv, nil
+}
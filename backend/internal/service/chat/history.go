@@ -0,0 +1,248 @@
+package chat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+)
+
+const (
+	// defaultMaxHistory 每个会话在内存中保留的最大消息条数
+	defaultMaxHistory = 40
+	// maxSessions 内存中最多缓存的会话数，超出时淘汰最久未写入的会话
+	maxSessions = 1000
+	// sessionTTL 会话超过该时长没有新消息即从内存中淘汰，配置数据库时下次请求重新加载
+	sessionTTL = 2 * time.Hour
+)
+
+// historyStore 按会话在内存中缓存对话历史与滚动摘要，配置数据库时未缓存的会话从数据库加载
+type historyStore struct {
+	mu          sync.RWMutex
+	sessions    map[string]*conversation
+	maxMessages int
+	maxSessions int
+	ttl         time.Duration
+}
+
+// conversation 一个会话的历史。已被摘要的较早消息会从 messages 中移除
+type conversation struct {
+	messages []llm.Message
+	summary  string
+	// removed 已从 messages 开头移除的消息总数，用于在后台摘要完成时定位被摘要的消息
+	removed     int
+	summarizing bool
+	// generation 切换分支后递增，切换前开始的摘要不再写回
+	generation int
+	// lastUsed 最近一次写入的时间，用于淘汰
+	lastUsed time.Time
+}
+
+// summaryTask 一次后台摘要的输入及其在会话中的位置
+type summaryTask struct {
+	summary    string
+	messages   []llm.Message
+	offset     int
+	generation int
+}
+
+func newHistoryStore(maxMessages int) *historyStore {
+	return &historyStore{
+		sessions:    make(map[string]*conversation),
+		maxMessages: maxMessages,
+		maxSessions: maxSessions,
+		ttl:         sessionTTL,
+	}
+}
+
+// Get 返回会话历史的副本
+func (h *historyStore) Get(key string) []llm.Message {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	conv, ok := h.sessions[key]
+	if !ok {
+		return []llm.Message{}
+	}
+	res := make([]llm.Message, len(conv.messages))
+	copy(res, conv.messages)
+	return res
+}
+
+// Summary 返回会话的滚动摘要
+func (h *historyStore) Summary(key string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if conv, ok := h.sessions[key]; ok {
+		return conv.summary
+	}
+	return ""
+}
+
+// Has 返回会话是否已在内存中
+func (h *historyStore) Has(key string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.sessions[key]
+	return ok
+}
+
+// Load 以持久化的历史与摘要初始化会话，会话已在内存中时不做改动
+func (h *historyStore) Load(key string, messages []llm.Message, summary string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.sessions[key]; ok {
+		return
+	}
+	conv := h.add(key)
+	conv.messages, conv.summary = messages, summary
+}
+
+// Replace 以另一条分支的历史与摘要替换会话，用于切换分支
+func (h *historyStore) Replace(key string, messages []llm.Message, summary string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conv := h.session(key)
+	conv.messages = append([]llm.Message(nil), messages...)
+	conv.summary = summary
+	conv.removed = 0
+	conv.summarizing = false
+	conv.generation++
+	conv.trim(h.maxMessages)
+}
+
+// Append 追加消息，超出上限时按整轮丢弃最早的消息
+func (h *historyStore) Append(key string, messages ...llm.Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conv := h.session(key)
+	conv.messages = append(conv.messages, messages...)
+	conv.trim(h.maxMessages)
+}
+
+// session 返回会话并刷新其写入时间，不存在时新建，调用方需持有写锁
+func (h *historyStore) session(key string) *conversation {
+	conv, ok := h.sessions[key]
+	if !ok {
+		return h.add(key)
+	}
+	conv.lastUsed = time.Now()
+	return conv
+}
+
+// add 新建会话，先淘汰过期的会话，仍达到上限时淘汰最久未写入的会话，调用方需持有写锁
+func (h *historyStore) add(key string) *conversation {
+	now := time.Now()
+	var oldestKey string
+	var oldest time.Time
+	for k, conv := range h.sessions {
+		if h.ttl > 0 && now.Sub(conv.lastUsed) > h.ttl {
+			delete(h.sessions, k)
+			continue
+		}
+		if oldestKey == "" || conv.lastUsed.Before(oldest) {
+			oldestKey, oldest = k, conv.lastUsed
+		}
+	}
+	if h.maxSessions > 0 && len(h.sessions) >= h.maxSessions {
+		delete(h.sessions, oldestKey)
+	}
+	conv := &conversation{lastUsed: now}
+	h.sessions[key] = conv
+	return conv
+}
+
+// Delete 移除会话，进行中的摘要完成后不再写回
+func (h *historyStore) Delete(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, key)
+}
+
+// takeForSummary 最近 keepTurns 轮之前的消息累计达到 every 轮时，把会话标记为摘要中，
+// 返回当前摘要、待摘要的消息及其位置。同一会话同时只有一个摘要任务
+func (h *historyStore) takeForSummary(key string, every, keepTurns int) (*summaryTask, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conv, exists := h.sessions[key]
+	if !exists || conv.summarizing {
+		return nil, false
+	}
+	// 一轮从用户消息开始
+	var starts []int
+	for i, m := range conv.messages {
+		if m.Role == llm.RoleUser {
+			starts = append(starts, i)
+		}
+	}
+	if len(starts)-keepTurns < every {
+		return nil, false
+	}
+	end := len(conv.messages)
+	if keepTurns > 0 {
+		end = starts[len(starts)-keepTurns]
+	}
+	conv.summarizing = true
+	messages := make([]llm.Message, end)
+	copy(messages, conv.messages[:end])
+	return &summaryTask{summary: conv.summary, messages: messages, offset: conv.removed, generation: conv.generation}, true
+}
+
+// applySummary 保存新的摘要，并移除 task 中已被摘要的消息，其间因超出上限已被丢弃的消息不会重复移除。
+// 会话已被删除或切换了分支时返回 false
+func (h *historyStore) applySummary(key string, task *summaryTask, summary string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	conv, ok := h.sessions[key]
+	if !ok || conv.generation != task.generation {
+		return false
+	}
+	conv.summarizing = false
+	conv.summary = summary
+	if n := task.offset + len(task.messages) - conv.removed; n > 0 {
+		conv.remove(min(n, len(conv.messages)))
+	}
+	return true
+}
+
+// cancelSummary 摘要失败时清除摘要中的标记，下次追加消息后重试
+func (h *historyStore) cancelSummary(key string, task *summaryTask) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if conv, ok := h.sessions[key]; ok && conv.generation == task.generation {
+		conv.summarizing = false
+	}
+}
+
+// trim 消息超出 maxMessages 时从最早的一轮开始整轮丢弃，保证历史总是从用户消息开始；
+// 最近一轮本身超出上限时保留这一轮，没有用户消息时才按条丢弃
+func (c *conversation) trim(maxMessages int) {
+	if maxMessages <= 0 || len(c.messages) <= maxMessages {
+		return
+	}
+	start := -1
+	for i := len(c.messages) - maxMessages; i < len(c.messages); i++ {
+		if c.messages[i].Role == llm.RoleUser {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		for i := len(c.messages) - maxMessages - 1; i >= 0; i-- {
+			if c.messages[i].Role == llm.RoleUser {
+				start = i
+				break
+			}
+		}
+	}
+	if start < 0 {
+		start = len(c.messages) - maxMessages
+	}
+	if start > 0 {
+		c.remove(start)
+	}
+}
+
+func (c *conversation) remove(n int) {
+	c.messages = append([]llm.Message(nil), c.messages[n:]...)
+	c.removed += n
+}
@@ -0,0 +1,107 @@
+package chat
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+)
+
+// testTurn 一轮对话：用户消息、toolCalls 次工具调用与结果，最后是回复
+func testTurn(n, toolCalls int) []llm.Message {
+	messages := []llm.Message{{Role: llm.RoleUser, Content: fmt.Sprintf("q%d", n)}}
+	for range toolCalls {
+		messages = append(messages,
+			llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{ID: "t", Name: "clock"}}},
+			llm.Message{Role: llm.RoleTool, ToolCallID: "t", Content: "ok"})
+	}
+	return append(messages, llm.Message{Role: llm.RoleAssistant, Content: fmt.Sprintf("a%d", n)})
+}
+
+func TestHistoryTrimsWholeTurns(t *testing.T) {
+	tests := []struct {
+		name      string
+		turns     [][]llm.Message
+		max       int
+		wantFirst string
+		wantLen   int
+	}{
+		{"within limit", [][]llm.Message{testTurn(1, 0), testTurn(2, 0)}, 4, "q1", 4},
+		{"drops oldest turn", [][]llm.Message{testTurn(1, 0), testTurn(2, 0), testTurn(3, 0)}, 5, "q2", 4},
+		// 截到第一轮的工具结果中间时，整轮丢弃
+		{"turn boundary after tool calls", [][]llm.Message{testTurn(1, 2), testTurn(2, 0)}, 5, "q2", 2},
+		// 最近一轮本身超出上限时保留整轮
+		{"last turn longer than limit", [][]llm.Message{testTurn(1, 0), testTurn(2, 3)}, 4, "q2", 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHistoryStore(tt.max)
+			for _, messages := range tt.turns {
+				h.Append("c1", messages...)
+			}
+			got := h.Get("c1")
+			if len(got) != tt.wantLen || got[0].Role != llm.RoleUser || got[0].Content != tt.wantFirst {
+				t.Errorf("history = %+v, want %d messages starting with %q", got, tt.wantLen, tt.wantFirst)
+			}
+		})
+	}
+
+	// 切换分支时同样按整轮截断
+	h := newHistoryStore(3)
+	h.Replace("c1", append(testTurn(1, 1), testTurn(2, 0)...), "")
+	if got := h.Get("c1"); len(got) != 2 || got[0].Content != "q2" {
+		t.Errorf("history after replace = %+v", got)
+	}
+}
+
+func TestHistoryTrimKeepsSummaryOffset(t *testing.T) {
+	h := newHistoryStore(6)
+	for i := range 3 {
+		h.Append("c1", testTurn(i, 0)...)
+	}
+	task, ok := h.takeForSummary("c1", 2, 1)
+	if !ok || len(task.messages) != 4 {
+		t.Fatalf("takeForSummary = %+v, %v", task, ok)
+	}
+	// 摘要进行中又丢弃了最早的一轮，写回时只再移除剩下被摘要的一轮
+	h.Append("c1", testTurn(3, 0)...)
+	if !h.applySummary("c1", task, "摘要") {
+		t.Fatal("applySummary = false")
+	}
+	if got := h.Get("c1"); len(got) != 4 || got[0].Content != "q2" {
+		t.Errorf("history = %+v", got)
+	}
+}
+
+func TestHistoryEviction(t *testing.T) {
+	h := newHistoryStore(defaultMaxHistory)
+	h.maxSessions = 2
+	h.Append("a", testTurn(1, 0)...)
+	h.Append("b", testTurn(1, 0)...)
+	h.sessions["a"].lastUsed = time.Now().Add(-time.Minute)
+	h.sessions["b"].lastUsed = time.Now().Add(-2 * time.Minute)
+
+	// 写入刷新会话的使用时间
+	h.Append("b", testTurn(2, 0)...)
+	h.Append("c", testTurn(1, 0)...)
+	if h.Has("a") || !h.Has("b") || !h.Has("c") {
+		t.Errorf("sessions = %v, want a evicted", sessionKeys(h))
+	}
+
+	// 过期的会话在新建会话时淘汰
+	h.maxSessions = 10
+	h.sessions["b"].lastUsed = time.Now().Add(-h.ttl - time.Second)
+	h.Load("d", testTurn(1, 0), "")
+	if h.Has("b") || !h.Has("c") || !h.Has("d") {
+		t.Errorf("sessions = %v, want b expired", sessionKeys(h))
+	}
+}
+
+func sessionKeys(h *historyStore) []string {
+	var res []string
+	for k := range h.sessions {
+		res = append(res, k)
+	}
+	return res
+}
@@ -0,0 +1,125 @@
+package chat
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/chat_domain"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/service/recall"
+)
+
+// defaultPersonaName 人设未配置名称时，示例对话中使用的称呼
+const defaultPersonaName = "助理"
+
+// resolvePersona 以配置中的人设为基础，叠加请求中的覆盖字段
+func resolvePersona(base config.PersonaConfig, override *chat_domain.Persona) config.PersonaConfig {
+	persona := base
+	if override == nil {
+		return persona
+	}
+	if override.Name != "" {
+		persona.Name = override.Name
+	}
+	if override.Personality != "" {
+		persona.Personality = override.Personality
+	}
+	if override.SpeakingStyle != "" {
+		persona.SpeakingStyle = override.SpeakingStyle
+	}
+	if len(override.ExampleDialogue) > 0 {
+		persona.ExampleDialogue = make([]config.DialogueConfig, 0, len(override.ExampleDialogue))
+		for _, d := range override.ExampleDialogue {
+			persona.ExampleDialogue = append(persona.ExampleDialogue, config.DialogueConfig{
+				User:      d.User,
+				Assistant: d.Assistant,
+			})
+		}
+	}
+	if override.Greeting != "" {
+		persona.Greeting = override.Greeting
+	}
+	if override.Instructions != "" {
+		persona.Instructions = override.Instructions
+	}
+	if override.EnabledTools != nil {
+		persona.EnabledTools = override.EnabledTools
+	}
+	if override.DisabledTools != nil {
+		persona.DisabledTools = override.DisabledTools
+	}
+	return persona
+}
+
+// renderPersona 将人设渲染为系统提示词，人设为空时返回默认提示词
+func renderPersona(persona config.PersonaConfig) string {
+	var sb strings.Builder
+	if persona.Name != "" {
+		sb.WriteString("你是" + persona.Name + "，用户的陪伴伙伴。\n")
+	}
+	if persona.Personality != "" {
+		sb.WriteString("性格：" + persona.Personality + "\n")
+	}
+	if persona.SpeakingStyle != "" {
+		sb.WriteString("说话风格：" + persona.SpeakingStyle + "\n")
+	}
+	if len(persona.ExampleDialogue) > 0 {
+		name := persona.Name
+		if name == "" {
+			name = defaultPersonaName
+		}
+		sb.WriteString("\n以下是你与用户对话的示例，请保持一致的语气：\n")
+		for _, d := range persona.ExampleDialogue {
+			sb.WriteString("用户：" + d.User + "\n")
+			sb.WriteString(name + "：" + d.Assistant + "\n")
+		}
+	}
+	if persona.Instructions != "" {
+		sb.WriteString("\n" + persona.Instructions + "\n")
+	}
+	prompt := strings.TrimSpace(sb.String())
+	if prompt == "" {
+		return defaultSystemPrompt
+	}
+	return prompt
+}
+
+// systemPrompt 计算本次请求使用的系统提示词：请求中的 SystemPrompt 优先，否则渲染人设
+func systemPrompt(req *chat_domain.Request, persona config.PersonaConfig) string {
+	if strings.TrimSpace(req.SystemPrompt) != "" {
+		return req.SystemPrompt
+	}
+	return renderPersona(persona)
+}
+
+// withMemories 把记住的用户事实附加到系统提示词末尾
+func withMemories(prompt string, facts []string) string {
+	if len(facts) == 0 {
+		return prompt
+	}
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\n你记得关于用户的以下信息，在合适的时候自然地运用，不要逐条复述：")
+	for _, f := range facts {
+		b.WriteString("\n- ")
+		b.WriteString(f)
+	}
+	return b.String()
+}
+
+// withRecall 把检索到的过往对话片段附加到系统提示词末尾
+func withRecall(prompt string, snippets []recall.Snippet) string {
+	if len(snippets) == 0 {
+		return prompt
+	}
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\n以下是与当前话题相关的过往对话片段，括号中为对话日期，用户问起以前聊过的内容时可以参考：")
+	for _, snippet := range snippets {
+		b.WriteString("\n[")
+		b.WriteString(snippet.Time.Format(time.DateOnly))
+		b.WriteString("] ")
+		b.WriteString(snippet.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ai-companion/backend/global"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// 推理模型思考过程的处理方式
+const (
+	reasoningHide    = "hide"
+	reasoningLog     = "log"
+	reasoningForward = "forward"
+)
+
+var warnReasoningMode sync.Once
+
+// reasoningMode 返回配置的处理方式，未配置或无法识别时丢弃思考过程
+func reasoningMode() string {
+	switch mode := global.Cfg.Reasoning.Mode; mode {
+	case reasoningLog, reasoningForward:
+		return mode
+	case "", reasoningHide:
+	default:
+		warnReasoningMode.Do(func() {
+			logger.Warn(fmt.Sprintf("unknown reasoning mode %q, reasoning will be hidden", mode))
+		})
+	}
+	return reasoningHide
+}
+
+// logReasoning log 模式下把一次回复的思考过程写入日志
+func logReasoning(key, model, reasoning string) {
+	if reasoning == "" || reasoningMode() != reasoningLog {
+		return
+	}
+	logger.WithFields(map[string]interface{}{
+		"conversation": key,
+		"model":        model,
+	}).Info("reasoning: " + reasoning)
+}
@@ -0,0 +1,158 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// 滚动摘要的默认参数
+const (
+	defaultSummaryEvery     = 10
+	defaultSummaryKeepTurns = 4
+	defaultSummaryMaxLength = 500
+	defaultSummaryTimeout   = 60 * time.Second
+)
+
+const summaryInstruction = "你负责为一段陪伴对话维护滚动摘要。根据已有摘要和新的对话内容，输出更新后的完整摘要：" +
+	"保留用户的个人信息、偏好、经历的重要事件、情绪变化以及双方的约定，省略寒暄和重复内容。" +
+	"用第三人称陈述，不超过 %d 字，只输出摘要正文。"
+
+// summarizer 在后台把长会话中较早的轮次压缩为滚动摘要，摘要随会话保存并注入之后请求的系统提示词
+type summarizer struct {
+	cfg     config.SummaryConfig
+	history *historyStore
+	// onUpdate 摘要更新后调用，用于随会话持久化
+	onUpdate func(key, summary string)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// newSummarizer 未启用摘要时返回 nil，nil 的 summarizer 的方法均为空操作
+func newSummarizer(cfg *config.SummaryConfig, history *historyStore, onUpdate func(key, summary string)) *summarizer {
+	if !cfg.Enabled {
+		return nil
+	}
+	s := &summarizer{cfg: *cfg, history: history, onUpdate: onUpdate}
+	if s.cfg.Every <= 0 {
+		s.cfg.Every = defaultSummaryEvery
+	}
+	if s.cfg.KeepTurns <= 0 {
+		s.cfg.KeepTurns = defaultSummaryKeepTurns
+	}
+	if s.cfg.MaxLength <= 0 {
+		s.cfg.MaxLength = defaultSummaryMaxLength
+	}
+	if s.cfg.Timeout <= 0 {
+		s.cfg.Timeout = defaultSummaryTimeout
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// maxHistory 保证摘要前累积的轮次不会先被历史上限丢弃
+func (s *summarizer) maxHistory() int {
+	if s == nil {
+		return defaultMaxHistory
+	}
+	return max(defaultMaxHistory, 4*(s.cfg.Every+s.cfg.KeepTurns))
+}
+
+// Trigger 会话累积的较早轮次达到阈值时在后台生成摘要，不阻塞调用方
+func (s *summarizer) Trigger(key string) {
+	if s == nil || s.ctx.Err() != nil {
+		return
+	}
+	task, ok := s.history.takeForSummary(key, s.cfg.Every, s.cfg.KeepTurns)
+	if !ok {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		updated, err := s.summarize(task.summary, task.messages)
+		if err != nil {
+			logger.Errorf("summarize conversation %s error: %s", key, err.Error())
+			s.history.cancelSummary(key, task)
+			return
+		}
+		if !s.history.applySummary(key, task, updated) {
+			return
+		}
+		if s.onUpdate != nil {
+			s.onUpdate(key, updated)
+		}
+		logger.WithFields(map[string]interface{}{
+			"conversation": key,
+			"messages":     len(task.messages),
+			"length":       len([]rune(updated)),
+		}).Info("conversation summary updated")
+	}()
+}
+
+func (s *summarizer) summarize(summary string, messages []llm.Message) (string, error) {
+	h, err := models.Get(s.cfg.Model)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.Timeout)
+	defer cancel()
+
+	var b strings.Builder
+	b.WriteString("已有摘要：\n")
+	if summary == "" {
+		summary = "（无）"
+	}
+	b.WriteString(summary)
+	b.WriteString("\n\n新的对话：\n")
+	for _, m := range messages {
+		switch m.Role {
+		case llm.RoleUser:
+			b.WriteString("用户：")
+		case llm.RoleAssistant:
+			b.WriteString("助手：")
+		default:
+			continue
+		}
+		b.WriteString(m.Content)
+		b.WriteString("\n")
+	}
+	res, err := h.GenerateChat(ctx, &llm.ChatRequest{Messages: []llm.Message{
+		{Role: llm.RoleSystem, Content: fmt.Sprintf(summaryInstruction, s.cfg.MaxLength)},
+		{Role: llm.RoleUser, Content: b.String()},
+	}})
+	if err != nil {
+		return "", err
+	}
+	updated := strings.TrimSpace(res.Content())
+	if updated == "" {
+		return "", errors.New("empty summary from model")
+	}
+	return updated, nil
+}
+
+// Close 取消进行中的摘要并等待其退出
+func (s *summarizer) Close() {
+	if s == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+// withSummary 把会话摘要附加到系统提示词末尾
+func withSummary(prompt, summary string) string {
+	if summary == "" {
+		return prompt
+	}
+	return prompt + "\n\n以下是你与用户此前对话的摘要，回复时可以参考：\n" + summary
+}
@@ -0,0 +1,221 @@
+package chat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/chat_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// useSummaryModel 在回显的默认模型之外注册名为 summary 的模型，测试结束后恢复
+func useSummaryModel(t *testing.T, mock config.MockConfig) {
+	t.Helper()
+	saved := models
+	models = llm.NewRegistry("", &config.LLMConfig{
+		Provider: "mock_llm", Model: "mock-1", Mock: config.MockConfig{Mode: llm.MockModeEcho},
+	}, []config.ModelProfile{{Name: "summary", LLMConfig: config.LLMConfig{Provider: "mock_llm", Model: "mock-summary", Mock: mock}}})
+	t.Cleanup(func() { models = saved })
+}
+
+// slowSummaryModel 延迟 delay 后回复固定摘要的模型
+func slowSummaryModel(t *testing.T, delay string) config.MockConfig {
+	t.Helper()
+	fixture := filepath.Join(t.TempDir(), "summary.yaml")
+	content := "replies:\n  - reply: 新的摘要\n    delay: " + delay + "\n"
+	if err := os.WriteFile(fixture, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return config.MockConfig{Mode: llm.MockModeScript, Fixture: fixture}
+}
+
+// summaryRecorder 记录 onUpdate 收到的摘要
+type summaryRecorder struct {
+	mu      sync.Mutex
+	updates []string
+}
+
+func (r *summaryRecorder) onUpdate(key, summary string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates = append(r.updates, key+": "+summary)
+}
+
+func (r *summaryRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.updates)
+}
+
+func TestSummarizerTriggersEveryNTurns(t *testing.T) {
+	// 默认模型回显输入，摘要即发送给模型的内容
+	h := newHistoryStore(defaultMaxHistory)
+	rec := &summaryRecorder{}
+	s := newSummarizer(&config.SummaryConfig{Enabled: true, Every: 2, KeepTurns: 1}, h, rec.onUpdate)
+	defer s.Close()
+
+	want := []int{0, 0, 1, 1, 2}
+	for i, n := range want {
+		h.Append("c1", testTurn(i+1, 0)...)
+		s.Trigger("c1")
+		s.wg.Wait()
+		if rec.count() != n {
+			t.Fatalf("after turn %d: %d summaries, want %d", i+1, rec.count(), n)
+		}
+	}
+
+	first, second := rec.updates[0], rec.updates[1]
+	if !strings.HasPrefix(first, "c1: ") || !strings.Contains(first, "（无）") ||
+		!strings.Contains(first, "用户：q1\n助手：a1\n用户：q2") || strings.Contains(first, "q3") {
+		t.Errorf("first summary = %q", first)
+	}
+	// 第二次摘要以上一次的摘要为基础，只包含其后新增的轮次
+	if !strings.Contains(second, "用户：q3") || !strings.Contains(second, "用户：q4") ||
+		!strings.Contains(second, "助手：a2") || strings.Contains(second, "用户：q5") {
+		t.Errorf("second summary = %q", second)
+	}
+	// 被摘要的消息从历史中移除，最近 KeepTurns 轮保留原文
+	if got := h.Get("c1"); len(got) != 2 || got[0].Content != "q5" {
+		t.Errorf("history = %+v", got)
+	}
+	if h.Summary("c1") != strings.TrimPrefix(second, "c1: ") {
+		t.Errorf("stored summary = %q", h.Summary("c1"))
+	}
+
+	var disabled *summarizer
+	disabled.Trigger("c1")
+	disabled.Close()
+	if newSummarizer(&config.SummaryConfig{}, h, nil) != nil {
+		t.Error("disabled summarizer should be nil")
+	}
+}
+
+func TestSummarizerConcurrentChanges(t *testing.T) {
+	tests := []struct {
+		name string
+		mock config.MockConfig
+		// change 在摘要进行中修改会话
+		change      func(h *historyStore)
+		wantApplied bool
+		wantHistory []string
+	}{
+		{name: "applied", wantApplied: true, wantHistory: []string{"q2", "a2"}},
+		{name: "appended during summary", change: func(h *historyStore) {
+			h.Append("c1", testTurn(3, 0)...)
+		}, wantApplied: true, wantHistory: []string{"q2", "a2", "q3", "a3"}},
+		{name: "deleted", change: func(h *historyStore) {
+			h.Delete("c1")
+		}, wantHistory: []string{}},
+		{name: "branch replaced", change: func(h *historyStore) {
+			h.Replace("c1", testTurn(9, 0), "另一条分支的摘要")
+		}, wantHistory: []string{"q9", "a9"}},
+		{name: "model error", mock: config.MockConfig{Mode: llm.MockModeEcho, Error: "mock upstream error"},
+			wantHistory: []string{"q1", "a1", "q2", "a2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := tt.mock
+			if mock.Mode == "" {
+				mock = slowSummaryModel(t, "100ms")
+			}
+			useSummaryModel(t, mock)
+			h := newHistoryStore(defaultMaxHistory)
+			rec := &summaryRecorder{}
+			s := newSummarizer(&config.SummaryConfig{Enabled: true, Model: "summary", Every: 1, KeepTurns: 1}, h, rec.onUpdate)
+			defer s.Close()
+
+			h.Append("c1", testTurn(1, 0)...)
+			h.Append("c1", testTurn(2, 0)...)
+			s.Trigger("c1")
+			if tt.change != nil {
+				tt.change(h)
+			}
+			s.wg.Wait()
+
+			if applied := rec.count() == 1; applied != tt.wantApplied {
+				t.Fatalf("applied = %v, want %v (updates %q)", applied, tt.wantApplied, rec.updates)
+			}
+			var got []string
+			for _, m := range h.Get("c1") {
+				got = append(got, m.Content)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantHistory, ",") {
+				t.Errorf("history = %v, want %v", got, tt.wantHistory)
+			}
+			if tt.wantApplied && h.Summary("c1") != "新的摘要" {
+				t.Errorf("summary = %q", h.Summary("c1"))
+			}
+			if tt.name == "branch replaced" && h.Summary("c1") != "另一条分支的摘要" {
+				t.Errorf("summary of replaced branch = %q", h.Summary("c1"))
+			}
+			// 未写回的摘要不会让会话一直处于摘要中
+			if tt.name != "deleted" {
+				if _, ok := h.takeForSummary("c1", 1, 0); !ok {
+					t.Error("conversation is still marked as summarizing")
+				}
+			}
+		})
+	}
+}
+
+func TestStreamDoesNotWaitForSummarizer(t *testing.T) {
+	useSummaryModel(t, slowSummaryModel(t, "1h"))
+	s := newTestService(t)
+	s.summarizer = newSummarizer(&config.SummaryConfig{Enabled: true, Model: "summary", Every: 1, KeepTurns: 1}, s.history, nil)
+
+	// stream 读完一次流式回复，回复结束前被摘要阻塞则失败
+	stream := func(conversationID, message string) *StreamReply {
+		t.Helper()
+		reply, err := s.ProcessStreamMessage(context.Background(), &chat_domain.Request{
+			Message: message, ConversationID: conversationID, Options: chat_domain.Options{UserID: "u1"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text strings.Builder
+		timeout := time.After(5 * time.Second)
+		for done := false; !done; {
+			select {
+			case chunk, ok := <-reply.Chunks:
+				done = !ok
+				if ok {
+					text.WriteString(chunk.Message)
+				}
+			case <-timeout:
+				t.Fatal("stream blocked by the summarizer")
+			}
+		}
+		if text.String() != message {
+			t.Errorf("reply = %q, want %q", text.String(), message)
+		}
+		return reply
+	}
+	first := stream("", "你好")
+	// 第二轮结束时开始摘要第一轮，第三轮不等待摘要完成
+	stream(first.ConversationID, "在吗")
+	stream(first.ConversationID, "还在吗")
+
+	// 摘要仍在后台进行，关闭服务时取消
+	if _, ok := s.history.takeForSummary(first.ConversationID, 1, 0); ok {
+		t.Error("summary should still be running")
+	}
+	closed := make(chan struct{})
+	go func() {
+		s.summarizer.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not cancel the running summary")
+	}
+	if s.history.Summary(first.ConversationID) != "" || len(s.history.Get(first.ConversationID)) != 6 {
+		t.Errorf("cancelled summary changed the history")
+	}
+}
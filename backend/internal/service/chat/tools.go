@@ -0,0 +1,97 @@
+package chat
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/chat_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/infrastructure/llm/tool"
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+const (
+	// maxToolRounds 单次请求中工具调用的最大轮数，达到后不再提供工具，要求模型直接给出回答
+	maxToolRounds = 5
+	// roundTimeout 单轮生成的超时，工具调用的每一轮分别计时
+	roundTimeout = 15 * time.Second
+	// requestTimeout 非流式请求的总超时，按最多的轮数放大，并为读取会话、组装上下文与执行工具留出余量
+	requestTimeout = roundTimeout * (maxToolRounds + 2)
+)
+
+// toolDefinitions 返回本次请求的人设可用的工具，模型不支持工具调用时返回 nil
+func (s *Service) toolDefinitions(h llm.Handle, persona config.PersonaConfig) []llm.Tool {
+	if !llm.SupportsTools(h) {
+		return nil
+	}
+	return s.tools.Definitions(persona.EnabledTools, persona.DisabledTools)
+}
+
+// withTimezone 把请求携带的用户时区放入 ctx，供时间相关的工具使用
+func withTimezone(ctx context.Context, req *chat_domain.Request) (context.Context, error) {
+	if req.Timezone == "" {
+		return ctx, nil
+	}
+	loc, err := time.LoadLocation(req.Timezone)
+	if err != nil {
+		return nil, &llm.ValidationError{Param: "timezone", Reason: "unknown timezone " + req.Timezone}
+	}
+	return tool.WithTimezone(ctx, loc), nil
+}
+
+// runTools 依次执行模型发起的工具调用，把调用与结果追加到对话中
+func (s *Service) runTools(ctx context.Context, req *llm.ChatRequest, content string, calls []llm.ToolCall, round int) {
+	req.Messages = append(req.Messages, llm.Message{Role: llm.RoleAssistant, Content: content, ToolCalls: calls})
+	for _, call := range calls {
+		// 只执行本次请求提供给模型的工具
+		if !slices.ContainsFunc(req.Tools, func(t llm.Tool) bool { return t.Name == call.Name }) {
+			req.Messages = append(req.Messages, llm.Message{
+				Role:       llm.RoleTool,
+				ToolCallID: call.ID,
+				Name:       call.Name,
+				Content:    "error: tool " + call.Name + " is not available",
+			})
+			continue
+		}
+		req.Messages = append(req.Messages, s.tools.Call(ctx, call))
+	}
+	if round+1 >= maxToolRounds {
+		req.Tools = nil
+	}
+}
+
+// generateWithTools 非流式的工具调用循环：模型发起工具调用时执行工具并把结果交回模型，
+// 直到模型给出最终回答，返回的用量为各轮之和。每轮生成单独计时
+func (s *Service) generateWithTools(ctx context.Context, h llm.Handle, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	var usage llm.Usage
+	for round := 0; ; round++ {
+		roundCtx, cancel := context.WithTimeout(ctx, roundTimeout)
+		result, err := h.GenerateChat(roundCtx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		addUsage(&usage, &result.Usage)
+		calls := result.ToolCalls()
+		if len(calls) == 0 || len(req.Tools) == 0 {
+			result.Usage = usage
+			return result, nil
+		}
+		s.runTools(ctx, req, result.Content(), calls, round)
+	}
+}
+
+// needsTools 判断流的结束块是否要求执行工具
+func needsTools(req *llm.ChatRequest, chunk *llm.StreamChunk) bool {
+	return chunk.Done && chunk.Error == nil && len(chunk.ToolCalls) > 0 && len(req.Tools) > 0
+}
+
+func addUsage(total *llm.Usage, usage *llm.Usage) {
+	if usage == nil {
+		return
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// maxKnownFacts 提取时提供给模型用于去重与更新的已有记忆条数
+const maxKnownFacts = 30
+
+const extractInstruction = "你负责从陪伴对话中提取关于用户的长期事实，让陪伴角色在之后的对话中记住用户。" +
+	"只提取稳定、之后仍然成立的信息，例如身份、职业与作息、家人与宠物、喜好与禁忌、健康状况、重要经历和计划；" +
+	"不要提取一时的情绪、寒暄、提问内容或助手自己的信息。" +
+	"每条事实写成一句以“用户”开头的独立完整陈述。已记住的事实带有编号：新信息更新或纠正了其中某条时，" +
+	"在 replaces 中填写该编号；与已记住的事实重复的信息不要输出。没有值得记住的信息时输出空列表。"
+
+// extraction 提取结果的结构
+type extraction struct {
+	Facts []extractedFact `json:"facts" description:"本轮对话中新出现或有变化的用户事实"`
+}
+
+type extractedFact struct {
+	Content  string `json:"content" description:"以“用户”开头的一句完整陈述"`
+	Category string `json:"category" enum:"profile,work,family,pet,preference,health,event,plan,other"`
+	Replaces int    `json:"replaces,omitempty" description:"被更新或纠正的已记住事实的编号，新事实不填"`
+}
+
+// extract 从一轮对话中提取事实并写入存储
+func (s *Service) extract(userID, message, reply string) error {
+	h, err := s.models.Get(s.cfg.Model)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.Timeout)
+	defer cancel()
+
+	known := s.relevant(ctx, userID, message, maxKnownFacts)
+	var b strings.Builder
+	b.WriteString("已记住的事实：\n")
+	if len(known) == 0 {
+		b.WriteString("（无）\n")
+	}
+	for i, f := range known {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, f.Content)
+	}
+	fmt.Fprintf(&b, "\n本轮对话：\n用户：%s\n助手：%s", message, reply)
+
+	var out extraction
+	_, err = llm.GenerateStructured(ctx, h, &llm.ChatRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: extractInstruction},
+			{Role: llm.RoleUser, Content: b.String()},
+		},
+		ResponseFormat: llm.JSONSchemaFormat("memory_facts", extraction{}),
+	}, &out)
+	if err != nil {
+		return err
+	}
+	for _, extracted := range out.Facts {
+		content := strings.TrimSpace(extracted.Content)
+		if content == "" {
+			continue
+		}
+		var replaces string
+		if extracted.Replaces > 0 && extracted.Replaces <= len(known) {
+			replaces = known[extracted.Replaces-1].ID
+		}
+		f, err := s.store.upsert(userID, replaces, fact{
+			Content:   content,
+			Category:  extracted.Category,
+			Source:    SourceExtracted,
+			Embedding: s.embed(ctx, content),
+		})
+		if err != nil {
+			return err
+		}
+		logger.WithFields(map[string]interface{}{
+			"userId":   userID,
+			"memoryId": f.ID,
+			"replaces": replaces,
+		}).Info("memory saved: " + f.Content)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/memory_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// 记忆来源
+const (
+	SourceExtracted = "extracted"
+	SourceManual    = "manual"
+)
+
+// 默认参数
+const (
+	defaultMaxFacts = 200
+	defaultTopK     = 8
+	defaultTimeout  = 60 * time.Second
+	// embedTimeout 检索相关记忆时向量化查询的超时，超时后退回按更新时间选取
+	embedTimeout = 5 * time.Second
+)
+
+// ErrNotFound 记忆不存在或不属于该用户
+var ErrNotFound = errors.New("memory not found")
+
+// Service 长期记忆：在后台从每轮对话中提取关于用户的事实，按用户去重保存，并为之后的请求检索相关记忆。
+// 未启用时不提取也不注入，但已保存的记忆仍可通过接口查看与编辑
+type Service struct {
+	cfg      config.MemoryConfig
+	models   *llm.Registry
+	embedder llm.Embedder
+	store    *store
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// userLocks 同一用户的提取依次执行，避免并发提取出重复的记忆
+	userLocks sync.Map
+}
+
+// NewService 创建记忆服务，models 用于提取事实，embedder 为 nil 时按更新时间选取注入的记忆
+func NewService(cfg *config.MemoryConfig, models *llm.Registry, embedder llm.Embedder) *Service {
+	s := &Service{cfg: *cfg, models: models, embedder: embedder}
+	if s.cfg.MaxFacts <= 0 {
+		s.cfg.MaxFacts = defaultMaxFacts
+	}
+	if s.cfg.TopK <= 0 {
+		s.cfg.TopK = defaultTopK
+	}
+	if s.cfg.Timeout <= 0 {
+		s.cfg.Timeout = defaultTimeout
+	}
+	st, err := newStore(s.cfg.Path, s.cfg.MaxFacts)
+	if err != nil {
+		logger.Errorf("load memories from %s error: %s, starting with empty memory", s.cfg.Path, err.Error())
+		st = &store{path: s.cfg.Path, maxFacts: s.cfg.MaxFacts, users: make(map[string][]*fact)}
+	}
+	s.store = st
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s
+}
+
+// Enabled 是否从对话中提取并注入记忆
+func (s *Service) Enabled() bool {
+	return s != nil && s.cfg.Enabled
+}
+
+// List 返回用户的全部记忆，最近更新的在前
+func (s *Service) List(userID string) []memory_domain.Fact {
+	facts := s.store.list(userID)
+	res := make([]memory_domain.Fact, 0, len(facts))
+	for _, f := range facts {
+		res = append(res, toDomain(f))
+	}
+	return res
+}
+
+// Update 修改一条记忆
+func (s *Service) Update(ctx context.Context, id string, req *memory_domain.UpdateRequest) (*memory_domain.Fact, error) {
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		return nil, &llm.ValidationError{Param: "content", Reason: "must not be empty"}
+	}
+	f, err := s.store.update(req.UserID, id, content, req.Category, s.embed(ctx, content))
+	if err != nil {
+		return nil, err
+	}
+	res := toDomain(f)
+	return &res, nil
+}
+
+// Delete 删除一条记忆
+func (s *Service) Delete(userID, id string) error {
+	return s.store.delete(userID, id)
+}
+
+// Relevant 返回与 query 最相关的至多 TopK 条记忆的内容。配置了向量模型时按相似度排序，
+// 否则或向量化失败时取最近更新的记忆
+func (s *Service) Relevant(ctx context.Context, userID, query string) []string {
+	if !s.Enabled() || userID == "" {
+		return nil
+	}
+	facts := s.relevant(ctx, userID, query, s.cfg.TopK)
+	res := make([]string, 0, len(facts))
+	for _, f := range facts {
+		res = append(res, f.Content)
+	}
+	return res
+}
+
+func (s *Service) relevant(ctx context.Context, userID, query string, k int) []fact {
+	facts := s.store.list(userID)
+	if len(facts) <= k {
+		return facts
+	}
+	if query != "" {
+		if q := s.embed(ctx, query); q != nil {
+			scores := make(map[string]float64, len(facts))
+			for _, f := range facts {
+				scores[f.ID] = cosine(q, f.Embedding)
+			}
+			// 同分时保持最近更新的在前
+			slices.SortStableFunc(facts, func(a, b fact) int {
+				switch sa, sb := scores[a.ID], scores[b.ID]; {
+				case sa > sb:
+					return -1
+				case sa < sb:
+					return 1
+				}
+				return 0
+			})
+		}
+	}
+	return facts[:k]
+}
+
+// Observe 在后台从一轮对话中提取事实，不阻塞调用方
+func (s *Service) Observe(userID, message, reply string) {
+	if !s.Enabled() || userID == "" || s.ctx.Err() != nil {
+		return
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		lock, _ := s.userLocks.LoadOrStore(userID, &sync.Mutex{})
+		lock.(*sync.Mutex).Lock()
+		defer lock.(*sync.Mutex).Unlock()
+		if err := s.extract(userID, message, reply); err != nil {
+			logger.Errorf("extract memories for %s error: %s", userID, err.Error())
+		}
+	}()
+}
+
+// Close 取消进行中的提取并等待其退出
+func (s *Service) Close() {
+	if s == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+// embed 向量化一段文本，未配置向量模型或失败时返回 nil
+func (s *Service) embed(ctx context.Context, text string) []float32 {
+	if s.embedder == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, embedTimeout)
+	defer cancel()
+	vectors, err := s.embedder.Embed(ctx, []string{text})
+	if err != nil || len(vectors) == 0 {
+		if err != nil {
+			logger.Errorf("embed memory text error: %s", err.Error())
+		}
+		return nil
+	}
+	return vectors[0]
+}
+
+func toDomain(f fact) memory_domain.Fact {
+	return memory_domain.Fact{
+		ID:        f.ID,
+		UserID:    f.UserID,
+		Content:   f.Content,
+		Category:  f.Category,
+		Source:    f.Source,
+		CreatedAt: f.CreatedAt,
+		UpdatedAt: f.UpdatedAt,
+	}
+}
+
+// cosine 余弦相似度，维度不同或任一向量为空时为 0
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
@@ -0,0 +1,146 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/memory_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// topicEmbedder 按文本中出现的话题词生成向量，每个话题一个维度
+type topicEmbedder struct {
+	topics []string
+	err    error
+}
+
+func (e *topicEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	res := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, len(e.topics))
+		for j, topic := range e.topics {
+			v[j] = float32(strings.Count(text, topic))
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func (e *topicEmbedder) Dimension(context.Context) (int, error) {
+	return len(e.topics), nil
+}
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float32
+		want float64
+	}{
+		{[]float32{1, 0}, []float32{1, 0}, 1},
+		{[]float32{1, 0}, []float32{0, 1}, 0},
+		{[]float32{1, 1}, []float32{-1, -1}, -1},
+		{[]float32{3, 4}, []float32{6, 8}, 1},
+		{[]float32{1, 0}, []float32{1, 1}, 1 / math.Sqrt2},
+		{[]float32{1, 0}, []float32{1, 0, 0}, 0},
+		{nil, nil, 0},
+		{[]float32{0, 0}, []float32{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := cosine(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("cosine(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func newTestService(embedder llm.Embedder, topK int) *Service {
+	return NewService(&config.MemoryConfig{Enabled: true, TopK: topK}, nil, embedder)
+}
+
+func TestRelevantRanking(t *testing.T) {
+	embedder := &topicEmbedder{topics: []string{"猫", "工作", "音乐"}}
+	s := newTestService(embedder, 2)
+	defer s.Close()
+	for _, content := range []string{"用户养了一只猫，猫叫团子", "用户的工作是护士", "用户喜欢听音乐", "用户晚上工作后会撸猫"} {
+		if _, err := s.store.upsert("u1", "", fact{Content: content, Embedding: s.embed(context.Background(), content)}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"我的猫今天生病了", []string{"用户养了一只猫，猫叫团子", "用户晚上工作后会撸猫"}},
+		{"最近工作好累", []string{"用户的工作是护士", "用户晚上工作后会撸猫"}},
+		{"推荐点音乐", []string{"用户喜欢听音乐", "用户晚上工作后会撸猫"}},
+		// 与任何记忆都不相关时按更新时间
+		{"今天天气不错", []string{"用户晚上工作后会撸猫", "用户喜欢听音乐"}},
+	}
+	for _, tt := range tests {
+		if got := s.Relevant(context.Background(), "u1", tt.query); !slices.Equal(got, tt.want) {
+			t.Errorf("Relevant(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// 向量化失败时退回最近更新的记忆
+	embedder.err = errors.New("embedding backend down")
+	if got := s.Relevant(context.Background(), "u1", "我的猫"); !slices.Equal(got, []string{"用户晚上工作后会撸猫", "用户喜欢听音乐"}) {
+		t.Errorf("Relevant without embeddings = %v", got)
+	}
+	// 只检索该用户自己的记忆
+	if got := s.Relevant(context.Background(), "u2", "我的猫"); len(got) != 0 {
+		t.Errorf("Relevant(u2) = %v", got)
+	}
+	if got := s.Relevant(context.Background(), "", "我的猫"); got != nil {
+		t.Errorf("Relevant without user = %v", got)
+	}
+	disabled := NewService(&config.MemoryConfig{}, nil, embedder)
+	disabled.store = s.store
+	if got := disabled.Relevant(context.Background(), "u1", "我的猫"); got != nil {
+		t.Errorf("Relevant when disabled = %v", got)
+	}
+}
+
+func TestServiceUpdateAndDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestService(nil, 0)
+	defer s.Close()
+	f, _ := s.store.upsert("u1", "", fact{Content: "用户养了一只猫", Category: "pet", Source: SourceExtracted})
+
+	var validationErr *llm.ValidationError
+	if _, err := s.Update(ctx, f.ID, &memory_domain.UpdateRequest{UserID: "u1", Content: "  "}); !errors.As(err, &validationErr) {
+		t.Errorf("update with empty content: err = %v", err)
+	}
+	if _, err := s.Update(ctx, f.ID, &memory_domain.UpdateRequest{UserID: "u2", Content: "用户养了一只狗"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update other user's fact: err = %v", err)
+	}
+	updated, err := s.Update(ctx, f.ID, &memory_domain.UpdateRequest{UserID: "u1", Content: " 用户养了两只猫 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Content != "用户养了两只猫" || updated.Category != "pet" || updated.Source != SourceManual {
+		t.Errorf("updated = %+v", updated)
+	}
+	if got := s.List("u1"); len(got) != 1 || got[0].Content != "用户养了两只猫" {
+		t.Errorf("List = %+v", got)
+	}
+
+	if err := s.Delete("u2", f.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete other user's fact: err = %v", err)
+	}
+	if err := s.Delete("u1", f.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.List("u1"); len(got) != 0 {
+		t.Errorf("List after delete = %+v", got)
+	}
+}
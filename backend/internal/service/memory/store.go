@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// fact 存储中的一条记忆，Embedding 只在存储内部使用，不对外返回
+type fact struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	Content   string    `json:"content"`
+	Category  string    `json:"category"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Embedding []float32 `json:"embedding,omitempty"`
+}
+
+// store 按用户保存记忆，配置了 path 时每次修改后整体写入 JSON 文件
+type store struct {
+	mu       sync.RWMutex
+	path     string
+	maxFacts int
+	users    map[string][]*fact
+}
+
+func newStore(path string, maxFacts int) (*store, error) {
+	s := &store{path: path, maxFacts: maxFacts, users: make(map[string][]*fact)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var facts []*fact
+	if err := json.Unmarshal(data, &facts); err != nil {
+		return nil, err
+	}
+	for _, f := range facts {
+		s.users[f.UserID] = append(s.users[f.UserID], f)
+	}
+	return s, nil
+}
+
+// list 返回用户全部记忆的副本，最近更新的在前
+func (s *store) list(userID string) []fact {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make([]fact, 0, len(s.users[userID]))
+	for _, f := range s.users[userID] {
+		res = append(res, *f)
+	}
+	slices.SortFunc(res, func(a, b fact) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	return res
+}
+
+// upsert 新增一条记忆。replaces 不为空时更新该条；内容与已有记忆重复时只刷新其更新时间。
+// 超出 maxFacts 时丢弃最久未更新的记忆
+func (s *store) upsert(userID, replaces string, f fact) (fact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	facts := s.users[userID]
+	target := -1
+	if replaces != "" {
+		target = slices.IndexFunc(facts, func(old *fact) bool { return old.ID == replaces })
+	}
+	key := normalize(f.Content)
+	dup := slices.IndexFunc(facts, func(old *fact) bool { return normalize(old.Content) == key })
+	switch {
+	case dup >= 0 && dup != target:
+		// 已经记住了相同的内容，被替换的旧记忆随之删除
+		facts[dup].UpdatedAt = now
+		if target >= 0 {
+			facts = slices.Delete(facts, target, target+1)
+			if target < dup {
+				dup--
+			}
+		}
+		f = *facts[dup]
+	case target >= 0:
+		old := facts[target]
+		old.Content, old.Category, old.Source, old.Embedding = f.Content, f.Category, f.Source, f.Embedding
+		old.UpdatedAt = now
+		f = *old
+	default:
+		f.ID = uuid.NewString()
+		f.UserID = userID
+		f.CreatedAt, f.UpdatedAt = now, now
+		stored := f
+		facts = append(facts, &stored)
+		if s.maxFacts > 0 && len(facts) > s.maxFacts {
+			oldest := 0
+			for i, old := range facts {
+				if old.UpdatedAt.Before(facts[oldest].UpdatedAt) {
+					oldest = i
+				}
+			}
+			facts = slices.Delete(facts, oldest, oldest+1)
+		}
+	}
+	s.users[userID] = facts
+	return f, s.saveLocked()
+}
+
+// update 修改一条记忆的内容，不存在或不属于该用户时返回 ErrNotFound
+func (s *store) update(userID, id, content, category string, embedding []float32) (fact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.users[userID], func(f *fact) bool { return f.ID == id })
+	if i < 0 {
+		return fact{}, ErrNotFound
+	}
+	f := s.users[userID][i]
+	f.Content, f.Source, f.Embedding = content, SourceManual, embedding
+	if category != "" {
+		f.Category = category
+	}
+	f.UpdatedAt = time.Now()
+	return *f, s.saveLocked()
+}
+
+// delete 删除一条记忆，不存在或不属于该用户时返回 ErrNotFound
+func (s *store) delete(userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	facts := s.users[userID]
+	i := slices.IndexFunc(facts, func(f *fact) bool { return f.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.users[userID] = slices.Delete(facts, i, i+1)
+	return s.saveLocked()
+}
+
+// saveLocked 先写临时文件再替换，避免写入中途退出损坏已有数据
+func (s *store) saveLocked() error {
+	if s.path == "" {
+		return nil
+	}
+	facts := make([]*fact, 0)
+	for _, userFacts := range s.users {
+		facts = append(facts, userFacts...)
+	}
+	slices.SortFunc(facts, func(a, b *fact) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	data, err := json.Marshal(facts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// normalize 去掉大小写、空白与标点后比较内容是否重复
+func normalize(content string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, content)
+}
@@ -0,0 +1,149 @@
+package memory
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func contents(facts []fact) []string {
+	res := make([]string, len(facts))
+	for i, f := range facts {
+		res[i] = f.Content
+	}
+	return res
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"用户养了一只猫", "用户养了一只猫。", true},
+		{"用户 喜欢 Jazz！", "用户喜欢jazz", true},
+		{"User likes tea.", "user likes TEA", true},
+		{"用户住在3楼", "用户住在三楼", false},
+		{"用户喜欢猫", "用户喜欢狗", false},
+	}
+	for _, tt := range tests {
+		if got := normalize(tt.a) == normalize(tt.b); got != tt.same {
+			t.Errorf("normalize(%q) == normalize(%q) = %v, want %v", tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestStoreUpsert(t *testing.T) {
+	s, err := newStore("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cat, _ := s.upsert("u1", "", fact{Content: "用户养了一只猫", Category: "pet", Source: SourceExtracted})
+	job, _ := s.upsert("u1", "", fact{Content: "用户是护士", Category: "work", Source: SourceExtracted})
+	if cat.ID == "" || cat.UserID != "u1" || cat.CreatedAt.IsZero() {
+		t.Errorf("new fact = %+v", cat)
+	}
+
+	time.Sleep(time.Millisecond)
+	// 只有标点与空白不同的内容视为重复，只刷新更新时间
+	dup, _ := s.upsert("u1", "", fact{Content: "用户养了一只猫！"})
+	if dup.ID != cat.ID || dup.Content != "用户养了一只猫" || !dup.UpdatedAt.After(cat.UpdatedAt) {
+		t.Errorf("duplicate = %+v", dup)
+	}
+
+	// replaces 指向的记忆被更新，ID 与创建时间不变
+	replaced, _ := s.upsert("u1", job.ID, fact{Content: "用户是医生", Category: "work", Source: SourceExtracted})
+	if replaced.ID != job.ID || replaced.Content != "用户是医生" || !replaced.CreatedAt.Equal(job.CreatedAt) {
+		t.Errorf("replaced = %+v", replaced)
+	}
+
+	// 替换后的内容与另一条已有记忆重复时，删除被替换的记忆
+	merged, _ := s.upsert("u1", job.ID, fact{Content: "用户养了一只猫"})
+	if merged.ID != cat.ID {
+		t.Errorf("merged = %+v, want %s", merged, cat.ID)
+	}
+	if got := contents(s.list("u1")); len(got) != 1 || got[0] != "用户养了一只猫" {
+		t.Errorf("facts = %v", got)
+	}
+
+	// replaces 不存在时按新记忆保存
+	if f, _ := s.upsert("u1", "missing", fact{Content: "用户喜欢爵士乐"}); f.ID == "missing" || len(s.list("u1")) != 2 {
+		t.Errorf("upsert with unknown replaces = %+v", f)
+	}
+}
+
+func TestStoreMaxFacts(t *testing.T) {
+	s, _ := newStore("", 3)
+	var first fact
+	for i, content := range []string{"用户喜欢猫", "用户喜欢狗", "用户喜欢鱼"} {
+		f, _ := s.upsert("u1", "", fact{Content: content})
+		if i == 0 {
+			first = f
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// 刷新最早的一条后，超出上限时丢弃的是最久未更新的“喜欢狗”
+	s.upsert("u1", "", fact{Content: "用户喜欢猫"})
+	time.Sleep(time.Millisecond)
+	s.upsert("u1", "", fact{Content: "用户喜欢鸟"})
+	got := contents(s.list("u1"))
+	want := []string{"用户喜欢鸟", "用户喜欢猫", "用户喜欢鱼"}
+	if len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("facts = %v, want %v", got, want)
+	}
+	if s.list("u1")[1].ID != first.ID {
+		t.Error("refreshed fact should keep its id")
+	}
+	// 上限按用户计算
+	s.upsert("u2", "", fact{Content: "用户喜欢猫"})
+	if len(s.list("u1")) != 3 || len(s.list("u2")) != 1 {
+		t.Errorf("facts per user = %d, %d", len(s.list("u1")), len(s.list("u2")))
+	}
+}
+
+func TestStoreUserIsolation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory", "facts.json")
+	s, err := newStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mine, _ := s.upsert("u1", "", fact{Content: "用户养了一只猫"})
+	// 相同内容在不同用户之间不去重
+	theirs, _ := s.upsert("u2", "", fact{Content: "用户养了一只猫"})
+	if mine.ID == theirs.ID {
+		t.Fatal("facts of different users share an id")
+	}
+	// 不能修改或删除其他用户的记忆，也不能借 replaces 覆盖
+	if _, err := s.update("u2", mine.ID, "用户养了一只狗", "", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update other user's fact: err = %v", err)
+	}
+	if err := s.delete("u2", mine.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete other user's fact: err = %v", err)
+	}
+	if f, _ := s.upsert("u2", mine.ID, fact{Content: "用户养了一只狗"}); f.ID == mine.ID {
+		t.Error("upsert replaced other user's fact")
+	}
+	if got := contents(s.list("u1")); len(got) != 1 || got[0] != "用户养了一只猫" {
+		t.Errorf("u1 facts = %v", got)
+	}
+
+	updated, err := s.update("u1", mine.ID, "用户养了两只猫", "pet", nil)
+	if err != nil || updated.Source != SourceManual || updated.Category != "pet" {
+		t.Errorf("update = %+v, %v", updated, err)
+	}
+	if err := s.delete("u2", theirs.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新加载后保持按用户划分
+	reloaded, err := newStore(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(reloaded.list("u1")); len(got) != 1 || got[0] != "用户养了两只猫" {
+		t.Errorf("reloaded u1 facts = %v", got)
+	}
+	if got := contents(reloaded.list("u2")); len(got) != 1 || got[0] != "用户养了一只狗" {
+		t.Errorf("reloaded u2 facts = %v", got)
+	}
+}
@@ -0,0 +1,203 @@
+package recall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/infrastructure/vectorindex"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+	"github.com/google/uuid"
+)
+
+// 检索范围
+const (
+	// ScopeUser 只检索同一用户的对话
+	ScopeUser = "user"
+	// ScopeShared 在全部用户的对话中检索
+	ScopeShared = "shared"
+)
+
+// 默认参数
+const (
+	defaultTopK      = 3
+	defaultMinScore  = 0.3
+	defaultMaxTokens = 600
+	// embedTimeout 单次向量化的超时，检索超时时本轮不注入过往对话
+	embedTimeout = 5 * time.Second
+)
+
+// 记录的元数据键
+const (
+	metaConversation = "conversation"
+	metaMessage      = "message"
+)
+
+// Snippet 检索到的一段过往对话
+type Snippet struct {
+	Text  string
+	Time  time.Time
+	Score float64
+}
+
+// Service 对话回忆：每轮对话保存后在后台向量化写入本地索引，之后的请求按用户消息检索相关的过往片段
+type Service struct {
+	cfg      config.RecallConfig
+	minScore float64
+	embedder llm.Embedder
+	index    *vectorindex.Index
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewService 未启用、未配置 embedding 或索引无法打开时返回 nil，nil 的 Service 的方法均为空操作
+func NewService(cfg *config.RecallConfig, embedder llm.Embedder) *Service {
+	if !cfg.Enabled {
+		return nil
+	}
+	if embedder == nil {
+		logger.Errorf("recall requires an embedding provider, recall is disabled")
+		return nil
+	}
+	index, err := vectorindex.Open(cfg.Path)
+	if err != nil {
+		logger.Errorf("open recall index %s error: %s", cfg.Path, err.Error())
+		return nil
+	}
+	s := &Service{cfg: *cfg, minScore: defaultMinScore, embedder: embedder, index: index}
+	if cfg.MinScore != nil {
+		s.minScore = *cfg.MinScore
+	}
+	if s.cfg.TopK <= 0 {
+		s.cfg.TopK = defaultTopK
+	}
+	if s.cfg.MaxTokens <= 0 {
+		s.cfg.MaxTokens = defaultMaxTokens
+	}
+	switch s.cfg.Scope {
+	case ScopeUser, ScopeShared:
+	case "":
+		s.cfg.Scope = ScopeUser
+	default:
+		logger.Errorf("unknown recall scope %q, using %s", s.cfg.Scope, ScopeUser)
+		s.cfg.Scope = ScopeUser
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	logger.Info(fmt.Sprintf("recall index loaded with %d snippets", index.Len()))
+	return s
+}
+
+// Index 在后台把一轮对话写入用户的索引，不阻塞调用方
+func (s *Service) Index(userKey, conversationID, message, reply string) {
+	if s == nil || s.ctx.Err() != nil {
+		return
+	}
+	at := time.Now()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		text := fmt.Sprintf("用户：%s\n助手：%s", message, reply)
+		vector, err := s.embed(s.ctx, text)
+		if err != nil {
+			logger.Errorf("embed conversation %s for recall error: %s", conversationID, err.Error())
+			return
+		}
+		err = s.index.Add(vectorindex.Item{
+			ID:        uuid.NewString(),
+			Namespace: userKey,
+			Text:      text,
+			Metadata:  map[string]string{metaConversation: conversationID, metaMessage: message},
+			CreatedAt: at,
+			Vector:    vector,
+		})
+		if err != nil {
+			logger.Errorf("add conversation %s to recall index error: %s", conversationID, err.Error())
+		}
+	}()
+}
+
+// Retrieve 返回与 query 相关的过往片段，至多 TopK 条且合计不超过 MaxTokens。
+// 当前会话中仍在 history 里的轮次已经在上下文里，不会重复返回
+func (s *Service) Retrieve(ctx context.Context, userKey, conversationID, query string, history []llm.Message, counter *llm.TokenCounter) []Snippet {
+	if s == nil || query == "" {
+		return nil
+	}
+	vector, err := s.embed(ctx, query)
+	if err != nil {
+		logger.Errorf("embed query for recall error: %s", err.Error())
+		return nil
+	}
+	namespace := userKey
+	if s.cfg.Scope == ScopeShared {
+		namespace = ""
+	}
+	recent := make(map[string]bool)
+	for _, m := range history {
+		if m.Role == llm.RoleUser {
+			recent[m.Content] = true
+		}
+	}
+	// 多取一些候选，留给排除与预算裁剪
+	results := s.index.Search(namespace, vector, s.cfg.TopK+len(recent), s.minScore)
+	var snippets []Snippet
+	var tokens int
+	for _, r := range results {
+		if r.Metadata[metaConversation] == conversationID && recent[r.Metadata[metaMessage]] {
+			continue
+		}
+		n := counter.Count(r.Text)
+		if tokens+n > s.cfg.MaxTokens {
+			continue
+		}
+		tokens += n
+		snippets = append(snippets, Snippet{Text: r.Text, Time: r.CreatedAt, Score: r.Score})
+		if len(snippets) >= s.cfg.TopK {
+			break
+		}
+	}
+	return snippets
+}
+
+// Forget 从索引中删除一个会话的全部片段，用于会话被删除时
+func (s *Service) Forget(userKey, conversationID string) {
+	if s == nil {
+		return
+	}
+	n, err := s.index.DeleteFunc(userKey, func(item *vectorindex.Item) bool {
+		return item.Metadata[metaConversation] == conversationID
+	})
+	if err != nil {
+		logger.Errorf("remove conversation %s from recall index error: %s", conversationID, err.Error())
+		return
+	}
+	logger.Debug(fmt.Sprintf("removed %d recall snippets of conversation %s", n, conversationID))
+}
+
+// Close 取消进行中的写入并关闭索引
+func (s *Service) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.cancel()
+	s.wg.Wait()
+	return s.index.Close()
+}
+
+func (s *Service) embed(ctx context.Context, text string) ([]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, embedTimeout)
+	defer cancel()
+	vectors, err := s.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) == 0 {
+		return nil, errors.New("no embedding returned")
+	}
+	return vectors[0], nil
+}
@@ -0,0 +1,170 @@
+package recall
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ai-companion/backend/internal/infrastructure/llm"
+	"github.com/ai-companion/backend/internal/pkg/config"
+)
+
+// topicEmbedder 按文本中出现的话题词生成向量，每个话题一个维度，另加一个常数维度
+type topicEmbedder struct {
+	topics []string
+}
+
+func (e *topicEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	res := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := []float32{0.1}
+		for _, topic := range e.topics {
+			v = append(v, float32(strings.Count(text, topic)))
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func (e *topicEmbedder) Dimension(context.Context) (int, error) {
+	return len(e.topics) + 1, nil
+}
+
+func newTestService(t *testing.T, cfg config.RecallConfig) *Service {
+	t.Helper()
+	cfg.Enabled = true
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(t.TempDir(), "recall.bin")
+	}
+	s := NewService(&cfg, &topicEmbedder{topics: []string{"猫", "工作", "旅行"}})
+	if s == nil {
+		t.Fatal("NewService returned nil")
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+// indexSync 同步写入一轮对话
+func (s *Service) indexSync(userKey, conversationID, message, reply string) {
+	s.Index(userKey, conversationID, message, reply)
+	s.wg.Wait()
+}
+
+func snippetTexts(snippets []Snippet) []string {
+	res := make([]string, len(snippets))
+	for i, snippet := range snippets {
+		res[i] = snippet.Text
+	}
+	return res
+}
+
+func TestRetrieve(t *testing.T) {
+	ctx := context.Background()
+	counter := llm.NewTokenCounter("llama-test")
+	s := newTestService(t, config.RecallConfig{TopK: 2})
+	s.indexSync("u1", "c1", "我家的猫叫团子", "团子这个名字真可爱")
+	s.indexSync("u1", "c1", "今天工作好累", "辛苦了，早点休息")
+	s.indexSync("u1", "c2", "猫又把杯子打碎了", "猫咪总是这么调皮")
+	s.indexSync("u2", "c3", "我的猫生病了", "希望它早日康复")
+
+	tests := []struct {
+		name           string
+		userKey        string
+		conversationID string
+		query          string
+		history        []llm.Message
+		want           []string
+	}{
+		{name: "ranked by similarity", userKey: "u1", conversationID: "c9", query: "猫",
+			want: []string{"用户：我家的猫叫团子\n助手：团子这个名字真可爱", "用户：猫又把杯子打碎了\n助手：猫咪总是这么调皮"}},
+		{name: "below min score", userKey: "u1", conversationID: "c9", query: "旅行", want: nil},
+		// 当前会话中仍在上下文里的轮次不重复注入
+		{name: "skips turns in history", userKey: "u1", conversationID: "c2", query: "猫",
+			history: []llm.Message{{Role: llm.RoleUser, Content: "猫又把杯子打碎了"}, {Role: llm.RoleAssistant, Content: "猫咪总是这么调皮"}},
+			want:    []string{"用户：我家的猫叫团子\n助手：团子这个名字真可爱"}},
+		// 只检索该用户自己的对话
+		{name: "per user", userKey: "u2", conversationID: "c9", query: "猫",
+			want: []string{"用户：我的猫生病了\n助手：希望它早日康复"}},
+		{name: "unknown user", userKey: "u3", conversationID: "c9", query: "猫", want: nil},
+		{name: "empty query", userKey: "u1", conversationID: "c9", query: "", want: nil},
+	}
+	for _, tt := range tests {
+		got := snippetTexts(s.Retrieve(ctx, tt.userKey, tt.conversationID, tt.query, tt.history, counter))
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: Retrieve = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// 共享范围在全部用户的对话中检索
+	shared := newTestService(t, config.RecallConfig{TopK: 5, Scope: ScopeShared})
+	shared.indexSync("u1", "c1", "我家的猫叫团子", "好")
+	shared.indexSync("u2", "c3", "我的猫生病了", "好")
+	if got := shared.Retrieve(ctx, "u3", "c9", "猫", nil, counter); len(got) != 2 {
+		t.Errorf("shared Retrieve = %q", snippetTexts(got))
+	}
+
+	var disabled *Service
+	if got := disabled.Retrieve(ctx, "u1", "c9", "猫", nil, counter); got != nil {
+		t.Errorf("nil service Retrieve = %v", got)
+	}
+	if NewService(&config.RecallConfig{Enabled: true}, nil) != nil {
+		t.Error("recall without an embedder should be disabled")
+	}
+}
+
+func TestRetrieveTokenBudget(t *testing.T) {
+	ctx := context.Background()
+	counter := llm.NewTokenCounter("llama-test")
+	long := strings.Repeat("猫", 40)
+	s := newTestService(t, config.RecallConfig{TopK: 5, MaxTokens: 30})
+	s.indexSync("u1", "c1", long, "好")
+	s.indexSync("u1", "c1", "猫猫", "好")
+	s.indexSync("u1", "c1", "猫", "嗯")
+
+	// 放不下的片段跳过，继续尝试相似度较低但更短的片段
+	got := s.Retrieve(ctx, "u1", "c9", "猫", nil, counter)
+	var tokens int
+	for _, snippet := range got {
+		if strings.Contains(snippet.Text, long) {
+			t.Errorf("snippet over budget was returned")
+		}
+		tokens += counter.Count(snippet.Text)
+	}
+	if len(got) != 2 || tokens > 30 {
+		t.Errorf("Retrieve = %q (%d tokens)", snippetTexts(got), tokens)
+	}
+}
+
+func TestForgetAndReopen(t *testing.T) {
+	ctx := context.Background()
+	counter := llm.NewTokenCounter("llama-test")
+	path := filepath.Join(t.TempDir(), "recall.bin")
+	s := newTestService(t, config.RecallConfig{Path: path})
+	s.indexSync("u1", "c1", "我家的猫叫团子", "好")
+	s.indexSync("u1", "c2", "猫又把杯子打碎了", "好")
+	s.indexSync("u2", "c1", "我的猫生病了", "好")
+
+	// 只删除该用户的这个会话
+	s.Forget("u1", "c1")
+	if got := snippetTexts(s.Retrieve(ctx, "u1", "c9", "猫", nil, counter)); len(got) != 1 || !strings.Contains(got[0], "杯子") {
+		t.Errorf("Retrieve after Forget = %q", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := newTestService(t, config.RecallConfig{Path: path})
+	if got := reopened.Retrieve(ctx, "u1", "c9", "猫", nil, counter); len(got) != 1 {
+		t.Errorf("u1 after reopen = %q", snippetTexts(got))
+	}
+	if got := reopened.Retrieve(ctx, "u2", "c9", "猫", nil, counter); len(got) != 1 {
+		t.Errorf("u2 after reopen = %q", snippetTexts(got))
+	}
+	// 关闭后不再写入
+	reopened.Close()
+	reopened.indexSync("u1", "c3", "猫", "好")
+	if reopened.index.Len() != 2 {
+		t.Errorf("Len after Close = %d, want 2", reopened.index.Len())
+	}
+}
@@ -0,0 +1,191 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ai-companion/backend/internal/domain/conversation_domain"
+	"github.com/ai-companion/backend/internal/domain/search_domain"
+	"github.com/ai-companion/backend/internal/infrastructure/database"
+	"github.com/ai-companion/backend/internal/infrastructure/textindex"
+	"github.com/ai-companion/backend/internal/pkg/config"
+	"github.com/ai-companion/backend/internal/pkg/logger"
+)
+
+// 索引实现的配置值
+const (
+	BackendAuto   = "auto"
+	BackendFTS5   = textindex.BackendFTS5
+	BackendMemory = textindex.BackendMemory
+)
+
+const (
+	// openTimeout 启动时建立索引的超时，需要为已有的全部消息分词
+	openTimeout = 2 * time.Minute
+	// indexTimeout 单次写入索引的超时
+	indexTimeout = 5 * time.Second
+)
+
+// ErrUnavailable 未配置数据库或索引无法建立
+var ErrUnavailable = errors.New("search is not available")
+
+// Service 聊天记录的全文搜索：消息保存后写入索引，检索结果从数据库读取原文生成高亮片段
+type Service struct {
+	index         textindex.Index
+	conversations conversation_domain.ConversationRepository
+	messages      conversation_domain.MessageRepository
+	snippetLength int
+}
+
+// NewService 按配置选择索引实现，auto 时 SQLite 上优先使用 FTS5，不可用时退回内存索引。
+// 索引无法建立时返回 nil，nil 的 Service 的写入方法均为空操作
+func NewService(cfg *config.SearchConfig, db *database.DB, conversations conversation_domain.ConversationRepository,
+	messages conversation_domain.MessageRepository) *Service {
+	ctx, cancel := context.WithTimeout(context.Background(), openTimeout)
+	defer cancel()
+	index, err := openIndex(ctx, cfg.Backend, db)
+	if err != nil {
+		logger.Errorf("open search index error: %s, search is disabled", err.Error())
+		return nil
+	}
+	logger.Info("search index opened with backend " + index.Backend())
+	s := &Service{index: index, conversations: conversations, messages: messages, snippetLength: cfg.SnippetLength}
+	if s.snippetLength <= 0 {
+		s.snippetLength = textindex.DefaultSnippetLength
+	}
+	return s
+}
+
+func openIndex(ctx context.Context, backend string, db *database.DB) (textindex.Index, error) {
+	switch backend {
+	case BackendFTS5:
+		return textindex.OpenFTS(ctx, db)
+	case BackendMemory:
+		return textindex.OpenMemory(ctx, db)
+	case BackendAuto, "":
+	default:
+		logger.Errorf("unknown search backend %q, using %s", backend, BackendAuto)
+	}
+	if db.Driver == database.DriverSQLite {
+		index, err := textindex.OpenFTS(ctx, db)
+		if err == nil {
+			return index, nil
+		}
+		logger.Errorf("open fts5 search index error: %s, falling back to %s", err.Error(), BackendMemory)
+	}
+	return textindex.OpenMemory(ctx, db)
+}
+
+// Index 把已保存的消息写入索引，失败只记录日志，重启时 FTS5 索引会补上遗漏的消息
+func (s *Service) Index(userKey string, messages ...*conversation_domain.Message) {
+	if s == nil || len(messages) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+	docs := make([]*textindex.Document, 0, len(messages))
+	for _, m := range messages {
+		docs = append(docs, &textindex.Document{
+			MessageID:      m.ID,
+			ConversationID: m.ConversationID,
+			UserID:         userKey,
+			Role:           m.Role,
+			Content:        m.Content,
+			CreatedAt:      m.CreatedAt,
+		})
+	}
+	if err := s.index.Add(ctx, docs...); err != nil {
+		logger.Errorf("index messages of conversation %s error: %s", messages[0].ConversationID, err.Error())
+	}
+}
+
+// Forget 从索引中移除已删除会话的消息
+func (s *Service) Forget(conversationID string) {
+	if s == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), indexTimeout)
+	defer cancel()
+	if err := s.index.Remove(ctx, conversationID); err != nil {
+		logger.Errorf("remove conversation %s from search index error: %s", conversationID, err.Error())
+	}
+}
+
+// Search 按相关度返回用户的一页命中消息及命中总数，userKey 为消息所属的用户
+func (s *Service) Search(ctx context.Context, userKey string, req *search_domain.Request) ([]*search_domain.Result, int64, error) {
+	if s == nil {
+		return nil, 0, ErrUnavailable
+	}
+	from, to, err := req.TimeRange()
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Normalize()
+	terms := textindex.QueryTerms(req.Query)
+	hits, total, err := s.index.Search(ctx, &textindex.Query{
+		UserID:         userKey,
+		Terms:          terms,
+		ConversationID: req.ConversationID,
+		Role:           req.Role,
+		From:           from,
+		To:             to,
+		Offset:         req.Offset(),
+		Limit:          req.Size,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("search messages: %w", err)
+	}
+	results, err := s.results(ctx, hits, terms)
+	return results, total, err
+}
+
+// results 读取命中消息的原文与所在会话的标题，按命中顺序生成结果
+func (s *Service) results(ctx context.Context, hits []textindex.Hit, terms []string) ([]*search_domain.Result, error) {
+	res := make([]*search_domain.Result, 0, len(hits))
+	if len(hits) == 0 {
+		return res, nil
+	}
+	ids := make([]string, 0, len(hits))
+	for _, h := range hits {
+		ids = append(ids, h.MessageID)
+	}
+	messages, err := s.messages.GetMany(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*conversation_domain.Message, len(messages))
+	for _, m := range messages {
+		byID[m.ID] = m
+	}
+	titles := make(map[string]string)
+	for _, h := range hits {
+		m, ok := byID[h.MessageID]
+		if !ok {
+			// 检索与读取之间消息所在的会话被删除
+			continue
+		}
+		title, ok := titles[m.ConversationID]
+		if !ok {
+			conv, err := s.conversations.Get(ctx, m.ConversationID)
+			if err != nil && !errors.Is(err, conversation_domain.ErrNotFound) {
+				return nil, err
+			}
+			if conv != nil {
+				title = conv.Title
+			}
+			titles[m.ConversationID] = title
+		}
+		res = append(res, &search_domain.Result{
+			MessageID:         m.ID,
+			ConversationID:    m.ConversationID,
+			ConversationTitle: title,
+			Role:              m.Role,
+			Snippet:           textindex.Snippet(m.Content, terms, s.snippetLength),
+			Score:             h.Score,
+			CreatedAt:         m.CreatedAt,
+		})
+	}
+	return res, nil
+}